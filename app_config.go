@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultAppConfigPath is read when --config is not given; unlike an explicit --config it may be missing
+const defaultAppConfigPath = "~/.containers.yaml"
+
+// AppConfig holds flag defaults from the user's config file. Keys are flag names without dashes; global
+// applies to the top-level flags and commands to each command by its full name, e.g. "images pull".
+type AppConfig struct {
+	Global   map[string]any            `yaml:"global,omitempty"`
+	Commands map[string]map[string]any `yaml:"commands,omitempty"`
+}
+
+// appConfig is loaded from the config file before any command runs
+var appConfig AppConfig
+
+// loadAppConfig reads and strictly parses the config file. A missing file is only an error when the
+// path was given explicitly.
+func loadAppConfig(configPath string, explicit bool) (AppConfig, error) {
+	configPath, err := expandHome(configPath)
+	if err != nil {
+		return AppConfig{}, err
+	}
+
+	data, err := os.ReadFile(configPath)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return AppConfig{}, nil
+	}
+	if err != nil {
+		return AppConfig{}, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var config AppConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return AppConfig{}, fmt.Errorf("failed to parse config file %s: %w", configPath, err)
+	}
+	return config, nil
+}
+
+// validateAppConfig checks that every commands key names an existing command
+func validateAppConfig(config AppConfig, commands map[string]*cli.Command) error {
+	for name := range config.Commands {
+		if _, ok := commands[name]; !ok {
+			return fmt.Errorf("unknown command '%s' in config file", name)
+		}
+	}
+	return nil
+}
+
+// commandPaths indexes commands and their subcommands by full name, e.g. "images pull"
+func commandPaths(commands []*cli.Command, parent string, paths map[string]*cli.Command) map[string]*cli.Command {
+	for _, cmd := range commands {
+		path := strings.TrimSpace(parent + " " + cmd.Name)
+		paths[path] = cmd
+		commandPaths(cmd.Subcommands, path, paths)
+	}
+	return paths
+}
+
+// installConfigDefaults makes every command apply its config section before its own Before hook runs
+func installConfigDefaults(commands map[string]*cli.Command) {
+	for path, cmd := range commands {
+		path, cmd, before := path, cmd, cmd.Before
+		cmd.Before = func(c *cli.Context) error {
+			if err := applyConfigDefaults(c, cmd.Flags, appConfig.Commands[path], path); err != nil {
+				return err
+			}
+			if before != nil {
+				return before(c)
+			}
+			return nil
+		}
+	}
+}
+
+// applyConfigDefaults sets each flag in values that was not given on the command line or through its
+// environment variable, giving the precedence flag > env > config file > built-in default
+func applyConfigDefaults(c *cli.Context, flags []cli.Flag, values map[string]any, section string) error {
+	// Sorted so the first error is stable
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		flag := findFlag(flags, key)
+		if flag == nil {
+			return fmt.Errorf("unknown flag '%s' in the %s section of the config file", key, section)
+		}
+		name := flag.Names()[0]
+		if c.IsSet(name) {
+			continue
+		}
+
+		items, err := configFlagValues(values[key])
+		if err != nil {
+			return fmt.Errorf("invalid value for '%s' in the %s section of the config file: %w", key, section, err)
+		}
+		if _, multi := flag.(*cli.StringSliceFlag); !multi && len(items) != 1 {
+			return fmt.Errorf("invalid value for '%s' in the %s section of the config file: expected a single value", key, section)
+		}
+		for _, item := range items {
+			if err := c.Set(name, item); err != nil {
+				return fmt.Errorf("invalid value for '%s' in the %s section of the config file: %w", key, section, err)
+			}
+		}
+	}
+	return nil
+}
+
+// configFlagValue returns the config file value of a single-value flag in a command's section
+func configFlagValue(command, flag string) (string, bool) {
+	value, ok := appConfig.Commands[command][flag]
+	if !ok {
+		return "", false
+	}
+	items, err := configFlagValues(value)
+	if err != nil || len(items) != 1 {
+		return "", false
+	}
+	return items[0], true
+}
+
+// findFlag returns the flag with the given name or alias
+func findFlag(flags []cli.Flag, name string) cli.Flag {
+	for _, flag := range flags {
+		if slices.Contains(flag.Names(), name) {
+			return flag
+		}
+	}
+	return nil
+}
+
+// configFlagValues converts a YAML scalar or list into the strings passed to the flag
+func configFlagValues(value any) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, fmt.Errorf("value is empty")
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			switch item.(type) {
+			case nil, []any, map[string]any:
+				return nil, fmt.Errorf("list items must be plain values")
+			}
+			items = append(items, fmt.Sprint(item))
+		}
+		return items, nil
+	case map[string]any:
+		return nil, fmt.Errorf("expected a value or a list, not a mapping")
+	default:
+		return []string{fmt.Sprint(v)}, nil
+	}
+}
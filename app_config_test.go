@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+func TestLoadAppConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	valid := write("valid.yaml", "global:\n  pull: always\ncommands:\n  bw-backup:\n    concurrency: 4\n")
+	config, err := loadAppConfig(valid, true)
+	if err != nil {
+		t.Fatalf("loadAppConfig() error = %v", err)
+	}
+	if config.Global["pull"] != "always" || config.Commands["bw-backup"]["concurrency"] != 4 {
+		t.Errorf("loadAppConfig() = %+v", config)
+	}
+
+	if _, err := loadAppConfig(filepath.Join(dir, "missing.yaml"), false); err != nil {
+		t.Errorf("loadAppConfig() missing default file error = %v, expected none", err)
+	}
+	if _, err := loadAppConfig(filepath.Join(dir, "missing.yaml"), true); err == nil {
+		t.Error("loadAppConfig() expected an error for a missing explicit file")
+	}
+	if _, err := loadAppConfig(write("typo.yaml", "globals:\n  pull: always\n"), true); err == nil {
+		t.Error("loadAppConfig() expected an error for an unknown top-level key")
+	}
+	if _, err := loadAppConfig(write("empty.yaml", ""), true); err != nil {
+		t.Errorf("loadAppConfig() empty file error = %v, expected none", err)
+	}
+}
+
+func TestApplyConfigDefaults(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		env       string
+		values    map[string]any
+		expected  string
+		expectErr string
+	}{
+		{name: "built-in default", expected: "default [ghcr.io] 1"},
+		{name: "config file", values: map[string]any{"mode": "file", "registry": []any{"a", "b"}, "n": 3}, expected: "file [a b] 3"},
+		{name: "alias key", values: map[string]any{"m": "file"}, expected: "file [ghcr.io] 1"},
+		{name: "env beats config", env: "env", values: map[string]any{"mode": "file"}, expected: "env [ghcr.io] 1"},
+		{name: "flag beats config", args: []string{"--mode", "flag", "--registry", "c"}, env: "env", values: map[string]any{"mode": "file", "registry": []any{"a"}}, expected: "flag [c] 1"},
+		{name: "unknown flag", values: map[string]any{"mdoe": "file"}, expectErr: "unknown flag 'mdoe'"},
+		{name: "list for a single-value flag", values: map[string]any{"mode": []any{"a", "b"}}, expectErr: "expected a single value"},
+		{name: "invalid int", values: map[string]any{"n": "many"}, expectErr: "invalid value for 'n'"},
+		{name: "mapping", values: map[string]any{"mode": map[string]any{"a": 1}}, expectErr: "not a mapping"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv("TEST_APP_CONFIG_MODE", tt.env)
+			}
+			var got string
+			app := &cli.App{
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "mode", Aliases: []string{"m"}, EnvVars: []string{"TEST_APP_CONFIG_MODE"}, Value: "default"},
+					&cli.StringSliceFlag{Name: "registry", Value: cli.NewStringSlice("ghcr.io")},
+					&cli.IntFlag{Name: "n", Value: 1},
+				},
+				Before: func(c *cli.Context) error {
+					return applyConfigDefaults(c, c.App.Flags, tt.values, "global")
+				},
+				Action: func(c *cli.Context) error {
+					got = c.String("mode") + " [" + strings.Join(c.StringSlice("registry"), " ") + "] " + strconv.Itoa(c.Int("n"))
+					return nil
+				},
+			}
+			err := app.Run(append([]string{"containers"}, tt.args...))
+			if tt.expectErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectErr) {
+					t.Fatalf("applyConfigDefaults() error = %v, expected %q", err, tt.expectErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyConfigDefaults() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("resolved flags = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidateAppConfig(t *testing.T) {
+	commands := commandPaths([]*cli.Command{
+		{Name: "bw-backup"},
+		{Name: "images", Subcommands: []*cli.Command{{Name: "pull"}}},
+	}, "", make(map[string]*cli.Command))
+
+	if err := validateAppConfig(AppConfig{Commands: map[string]map[string]any{"bw-backup": {}, "images pull": {}}}, commands); err != nil {
+		t.Errorf("validateAppConfig() error = %v", err)
+	}
+	if err := validateAppConfig(AppConfig{Commands: map[string]map[string]any{"pull": {}}}, commands); err == nil {
+		t.Error("validateAppConfig() expected an error for a subcommand without its parent")
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// expandResponseFiles replaces every @file argument with the arguments listed in that file,
+// one per line. Blank lines and lines starting with # are ignored, and each line is taken
+// verbatim so values never need shell quoting. Arguments after "--" are left untouched.
+func expandResponseFiles(args []string) ([]string, error) {
+	expanded := make([]string, 0, len(args))
+	for i, arg := range args {
+		if arg == "--" {
+			return append(expanded, args[i:]...), nil
+		}
+		if i == 0 || len(arg) < 2 || arg[0] != '@' {
+			expanded = append(expanded, arg)
+			continue
+		}
+
+		data, err := os.ReadFile(arg[1:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response file: %w", err)
+		}
+		fileArgs, err := parseResponseFile(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid response file %s: %w", arg[1:], err)
+		}
+		expanded = append(expanded, fileArgs...)
+	}
+	return expanded, nil
+}
+
+// parseResponseFile splits response file contents into arguments
+func parseResponseFile(data []byte) ([]string, error) {
+	var args []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Nested response files would allow include cycles
+		if len(line) > 1 && line[0] == '@' {
+			return nil, fmt.Errorf("nested response file %s is not supported", line)
+		}
+		args = append(args, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return args, nil
+}
+
+// splitList splits comma-separated entries of a list flag whose values never contain commas,
+// so e.g. CONTAINERS_ALLOWED_REGISTRIES=ghcr.io,docker.io still yields two registries
+func splitList(values []string) []string {
+	var items []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
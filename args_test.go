@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExpandResponseFiles(t *testing.T) {
+	dir := t.TempDir()
+	flags := filepath.Join(dir, "ibgateway.flags")
+	content := "# fixed gateway config\n--mode\nlive\n\n  --port  \n4001:4003\n--env\nTZ=Europe/Berlin with spaces\n"
+	if err := os.WriteFile(flags, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := expandResponseFiles([]string{"containers", "ibgateway", "@" + flags, "--name", "gw", "--", "@literal"})
+	if err != nil {
+		t.Fatalf("expandResponseFiles() unexpected error: %v", err)
+	}
+	want := []string{"containers", "ibgateway",
+		"--mode", "live", "--port", "4001:4003", "--env", "TZ=Europe/Berlin with spaces",
+		"--name", "gw", "--", "@literal"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expandResponseFiles() = %q, want %q", got, want)
+	}
+
+	if _, err := expandResponseFiles([]string{"containers", "@" + filepath.Join(dir, "missing")}); err == nil {
+		t.Error("missing response file: expected error")
+	}
+
+	nested := filepath.Join(dir, "nested.flags")
+	if err := os.WriteFile(nested, []byte("@"+flags+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := expandResponseFiles([]string{"containers", "@" + nested}); err == nil {
+		t.Error("nested response file: expected error")
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   []string
+	}{
+		{values: nil, want: nil},
+		{values: []string{"ghcr.io"}, want: []string{"ghcr.io"}},
+		{values: []string{"ghcr.io,docker.io", "quay.io"}, want: []string{"ghcr.io", "docker.io", "quay.io"}},
+		{values: []string{" ghcr.io , ,docker.io"}, want: []string{"ghcr.io", "docker.io"}},
+	}
+	for _, tt := range tests {
+		if got := splitList(tt.values); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitList(%q) = %q, want %q", tt.values, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ansiCyan colors the profile tag of prefixed audit lines
+const ansiCyan = "\033[36m"
+
+// auditLogger writes [AUDIT] lines as single writes under a mutex so concurrent
+// profiles never interleave within a line
+type auditLogger struct {
+	mu     sync.Mutex
+	w      io.Writer
+	prefix bool // Lead each line with a [profile] tag (--prefix-logs)
+	json   bool // Write events as JSON objects instead of [AUDIT] lines (--audit-format json)
+}
+
+// audit is the logger for all bw-backup audit lines
+var audit = &auditLogger{w: os.Stderr}
+
+// Logf writes one audit line for profile
+func (l *auditLogger) Logf(profile, format string, args ...any) {
+	var line strings.Builder
+	if l.prefix {
+		tag := profile
+		if tag == "" {
+			tag = "default"
+		}
+		line.WriteString(colorize(ansiCyan, "["+tag+"]"))
+		line.WriteString(" ")
+	}
+	line.WriteString("[AUDIT] ")
+	fmt.Fprintf(&line, format, args...)
+	line.WriteString("\n")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	io.WriteString(l.w, line.String())
+}
+
+// auditEvent is one structured audit record
+type auditEvent struct {
+	Event        string    `json:"event"`
+	Profile      string    `json:"profile"`
+	Organization string    `json:"organization,omitempty"`
+	Time         time.Time `json:"time"`
+	DurationMS   *int64    `json:"duration_ms,omitempty"`
+	File         string    `json:"file,omitempty"`
+	Keep         int       `json:"keep,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// auditEventLabels is the text-format wording of each audit event
+var auditEventLabels = map[string]string{
+	"backup_started":    "Bitwarden backup started",
+	"backup_completed":  "Bitwarden backup completed",
+	"backup_failed":     "Bitwarden backup failed",
+	"backup_pruned":     "Bitwarden backup pruned",
+	"backup_encrypted":  "Bitwarden backup encrypted with age",
+	"backup_verified":   "Bitwarden backup verified",
+	"session_refresh":   "Bitwarden session refresh attempted",
+	"upload_started":    "Bitwarden backup upload started",
+	"upload_completed":  "Bitwarden backup upload completed",
+	"upload_failed":     "Bitwarden backup upload failed",
+	"restore_started":   "Bitwarden restore started",
+	"restore_completed": "Bitwarden restore completed",
+	"restore_failed":    "Bitwarden restore failed",
+}
+
+// setAuditFormat selects --audit-format: text (default) or json
+func setAuditFormat(format string) error {
+	switch format {
+	case "text":
+		audit.json = false
+	case "json":
+		audit.json = true
+	default:
+		return fmt.Errorf("invalid audit format: %s (must be 'text' or 'json')", format)
+	}
+	return nil
+}
+
+// Event writes one audit record, stamping it with the current time if unset
+func (l *auditLogger) Event(e auditEvent) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if l.json {
+		data, _ := json.Marshal(e)
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.w.Write(append(data, '\n'))
+		return
+	}
+
+	var line strings.Builder
+	label := auditEventLabels[e.Event]
+	if label == "" {
+		label = e.Event
+	}
+	fmt.Fprintf(&line, "%s: profile=%s", label, e.Profile)
+	if e.Organization != "" {
+		fmt.Fprintf(&line, " organization=%s", e.Organization)
+	}
+	if e.File != "" {
+		fmt.Fprintf(&line, " file=%s", e.File)
+	}
+	if e.Keep > 0 {
+		fmt.Fprintf(&line, " keep=%d", e.Keep)
+	}
+	if e.DurationMS != nil {
+		fmt.Fprintf(&line, " duration=%s", time.Duration(*e.DurationMS)*time.Millisecond)
+	} else if strings.HasSuffix(e.Event, "_started") {
+		fmt.Fprintf(&line, " time=%s", e.Time.Format(time.RFC3339))
+	}
+	if e.Error != "" {
+		fmt.Fprintf(&line, " error=%s", e.Error)
+	}
+	l.Logf(e.Profile, "%s", line.String())
+}
+
+// Run logs <action>_started and returns a function that logs <action>_completed, or <action>_failed
+// with the error, together with the elapsed time
+func (l *auditLogger) Run(action, profile, orgID, file string) func(error) {
+	start := time.Now()
+	l.Event(auditEvent{Event: action + "_started", Profile: profile, Organization: orgID, File: file, Time: start})
+	return func(err error) {
+		duration := time.Since(start).Milliseconds()
+		e := auditEvent{Event: action + "_completed", Profile: profile, Organization: orgID, DurationMS: &duration}
+		if err != nil {
+			e.Event = action + "_failed"
+			e.Error = err.Error()
+		}
+		l.Event(e)
+	}
+}
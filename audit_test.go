@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAuditLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := &auditLogger{w: &buf, prefix: true}
+
+	var wg sync.WaitGroup
+	for _, profile := range []string{"personal", "work", ""} {
+		wg.Add(1)
+		go func(profile string) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				logger.Logf(profile, "Bitwarden backup started: profile=%s", profile)
+			}
+		}(profile)
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 150 {
+		t.Fatalf("got %d lines, want 150", len(lines))
+	}
+	for _, line := range lines {
+		ok := line == "[personal] [AUDIT] Bitwarden backup started: profile=personal" ||
+			line == "[work] [AUDIT] Bitwarden backup started: profile=work" ||
+			line == "[default] [AUDIT] Bitwarden backup started: profile="
+		if !ok {
+			t.Fatalf("interleaved or malformed line: %q", line)
+		}
+	}
+
+	buf.Reset()
+	logger.prefix = false
+	logger.Logf("work", "done")
+	if got := buf.String(); got != "[AUDIT] done\n" {
+		t.Errorf("unprefixed line = %q", got)
+	}
+}
+
+func TestAuditRun(t *testing.T) {
+	var buf bytes.Buffer
+	logger := &auditLogger{w: &buf}
+
+	logger.Run("backup", "work", "org-1", "")(errors.New("export failed"))
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %q", len(lines), buf.String())
+	}
+	if !strings.HasPrefix(lines[0], "[AUDIT] Bitwarden backup started: profile=work organization=org-1 time=") {
+		t.Errorf("started line = %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "[AUDIT] Bitwarden backup failed: profile=work organization=org-1 duration=") ||
+		!strings.HasSuffix(lines[1], " error=export failed") {
+		t.Errorf("failed line = %q", lines[1])
+	}
+
+	buf.Reset()
+	logger.json = true
+	logger.Run("backup", "work", "", "")(nil)
+	var events []map[string]any
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		var event map[string]any
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("line is not JSON: %q", line)
+		}
+		events = append(events, event)
+	}
+	if len(events) != 2 || events[0]["event"] != "backup_started" || events[1]["event"] != "backup_completed" {
+		t.Fatalf("events = %v", events)
+	}
+	if _, ok := events[0]["duration_ms"]; ok {
+		t.Errorf("started event has duration_ms: %v", events[0])
+	}
+	if _, ok := events[1]["duration_ms"]; !ok || events[1]["profile"] != "work" || events[1]["time"] == nil {
+		t.Errorf("completed event missing fields: %v", events[1])
+	}
+	if _, ok := events[1]["organization"]; ok {
+		t.Errorf("completed event has empty organization: %v", events[1])
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ageExtension is appended to backups re-encrypted on the host with --age-recipient
+const ageExtension = ".age"
+
+// validateAgeRecipient checks --age-recipient before any credentials are requested
+func validateAgeRecipient(recipient string) error {
+	if recipient == "" {
+		return nil
+	}
+	if _, err := exec.LookPath("age"); err != nil {
+		return fmt.Errorf("--age-recipient requires the age binary on PATH: %w", err)
+	}
+	return nil
+}
+
+// ageEncryptBackup re-encrypts a vault's backup file to <file>.age
+func ageEncryptBackup(path, profile, orgID, recipient string) error {
+	if err := encryptFileWithAge(path, recipient); err != nil {
+		return err
+	}
+	audit.Event(auditEvent{Event: "backup_encrypted", Profile: profile, Organization: orgID, File: filepath.Base(path) + ageExtension})
+	return nil
+}
+
+// encryptFileWithAge streams path through age to path.age and deletes path. The plaintext is
+// deleted even when encryption fails, so a failed run never leaves it behind.
+func encryptFileWithAge(path, recipient string) (err error) {
+	defer func() {
+		if removeErr := os.Remove(path); removeErr != nil && !os.IsNotExist(removeErr) && err == nil {
+			err = fmt.Errorf("failed to delete plaintext backup: %w", removeErr)
+		}
+	}()
+
+	in, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open backup for age encryption: %w", err)
+	}
+	defer in.Close()
+
+	// Write next to the target and rename, so a partial file never looks like a finished backup
+	tmp := path + ageExtension + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create age output: %w", err)
+	}
+	defer os.Remove(tmp)
+
+	var stderr strings.Builder
+	cmd := exec.Command("age", "--encrypt", "--recipient", recipient)
+	cmd.Stdin = in
+	cmd.Stdout = out
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+	if runErr == nil {
+		runErr = out.Sync()
+	}
+	if closeErr := out.Close(); runErr == nil {
+		runErr = closeErr
+	}
+	if runErr != nil {
+		return fmt.Errorf("age encryption failed: %w: %s", runErr, strings.TrimSpace(stderr.String()))
+	}
+
+	if err := os.Rename(tmp, path+ageExtension); err != nil {
+		return fmt.Errorf("failed to write age output: %w", err)
+	}
+	// Make the rename durable before the plaintext is deleted
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeAgeScript "encrypts" stdin by prefixing it with the recipient, failing for the recipient "bad"
+const fakeAgeScript = `#!/bin/sh
+if [ "$3" = "bad" ]; then echo "malformed recipient" >&2; exit 1; fi
+echo "age:$3"
+cat
+`
+
+func TestEncryptFileWithAge(t *testing.T) {
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "age"), []byte(fakeAgeScript), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	tests := []struct {
+		name      string
+		recipient string
+		expectErr bool
+	}{
+		{name: "encrypts and deletes plaintext", recipient: "age1example"},
+		{name: "failure leaves nothing behind", recipient: "bad", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "bitwarden-backup-2026-01-01-020000.json")
+			if err := os.WriteFile(path, []byte("{}\n"), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			err := encryptFileWithAge(path, tt.recipient)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("encryptFileWithAge() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("plaintext backup still exists (stat error %v)", err)
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.expectErr {
+				if len(entries) != 0 {
+					t.Errorf("expected an empty directory after failure, found %v", entries)
+				}
+				return
+			}
+			data, err := os.ReadFile(path + ageExtension)
+			if err != nil {
+				t.Fatalf("age output missing: %v", err)
+			}
+			if want := "age:" + tt.recipient + "\n{}\n"; string(data) != want {
+				t.Errorf("age output = %q, want %q", data, want)
+			}
+			if len(entries) != 1 {
+				t.Errorf("expected only the .age file, found %v", entries)
+			}
+		})
+	}
+}
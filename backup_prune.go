@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// backupTimestampPattern matches backup.sh's TIMESTAMP and the extensions of every export format,
+// optionally re-encrypted with --age-recipient
+const backupTimestampPattern = `(\d{4}-\d{2}-\d{2}-\d{6})\.(json|encrypted\.json|csv)(\.age)?`
+
+// pruneBackups deletes all but the keep most recent backups of one profile/organization vault in dir.
+// Only files named exactly like backup.sh output for that vault are considered; keep 0 disables pruning.
+// It returns the names of the deleted files.
+func pruneBackups(dir, profile, orgID string, keep int) ([]string, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+
+	pattern := regexp.MustCompile("^" + regexp.QuoteMeta(backupFilePrefix(profile, orgID)) + backupTimestampPattern + "$")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	type backupFile struct{ name, timestamp string }
+	var backups []backupFile
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		if match := pattern.FindStringSubmatch(entry.Name()); match != nil {
+			backups = append(backups, backupFile{name: entry.Name(), timestamp: match[1]})
+		}
+	}
+	if len(backups) <= keep {
+		return nil, nil
+	}
+
+	// Newest first; the timestamp is UTC and sorts lexically
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].timestamp != backups[j].timestamp {
+			return backups[i].timestamp > backups[j].timestamp
+		}
+		return backups[i].name > backups[j].name
+	})
+
+	var deleted []string
+	for _, backup := range backups[keep:] {
+		if err := os.Remove(filepath.Join(dir, backup.name)); err != nil {
+			return deleted, fmt.Errorf("failed to delete old backup %s: %w", backup.name, err)
+		}
+		// Drop the --verify checksum with it; most backups have none
+		if err := os.Remove(filepath.Join(dir, backup.name+checksumExtension)); err != nil && !os.IsNotExist(err) {
+			return deleted, fmt.Errorf("failed to delete checksum of %s: %w", backup.name, err)
+		}
+		deleted = append(deleted, backup.name)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestPruneBackups(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{
+		"bitwarden-work-backup-2026-01-01-010000.json",
+		"bitwarden-work-backup-2026-01-02-010000.encrypted.json",
+		"bitwarden-work-backup-2026-01-03-010000.json",
+		"bitwarden-work-backup-2026-01-04-010000.csv",
+		// Other vaults and unrelated files are never touched
+		"bitwarden-work-org-abc-backup-2026-01-01-010000.json",
+		"bitwarden-home-backup-2026-01-01-010000.json",
+		"bitwarden-work-backup-notes.txt",
+		"bitwarden-work-backup-2026-01-01-010000.json.bak",
+		".bw-backup-sizes.json",
+	}
+	for _, name := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deleted, err := pruneBackups(dir, "work", "", 2)
+	if err != nil {
+		t.Fatalf("pruneBackups() unexpected error: %v", err)
+	}
+	expected := []string{
+		"bitwarden-work-backup-2026-01-02-010000.encrypted.json",
+		"bitwarden-work-backup-2026-01-01-010000.json",
+	}
+	if !reflect.DeepEqual(deleted, expected) {
+		t.Errorf("deleted = %v, expected %v", deleted, expected)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var remaining []string
+	for _, entry := range entries {
+		remaining = append(remaining, entry.Name())
+	}
+	expectedRemaining := []string{
+		".bw-backup-sizes.json",
+		"bitwarden-home-backup-2026-01-01-010000.json",
+		"bitwarden-work-backup-2026-01-01-010000.json.bak",
+		"bitwarden-work-backup-2026-01-03-010000.json",
+		"bitwarden-work-backup-2026-01-04-010000.csv",
+		"bitwarden-work-backup-notes.txt",
+		"bitwarden-work-org-abc-backup-2026-01-01-010000.json",
+	}
+	sort.Strings(remaining)
+	if !reflect.DeepEqual(remaining, expectedRemaining) {
+		t.Errorf("remaining = %v, expected %v", remaining, expectedRemaining)
+	}
+
+	// Fewer backups than --keep, or pruning disabled, deletes nothing
+	for _, keep := range []int{5, 0} {
+		deleted, err := pruneBackups(dir, "work", "", keep)
+		if err != nil || len(deleted) != 0 {
+			t.Errorf("pruneBackups(keep=%d) = %v, %v, expected nothing deleted", keep, deleted, err)
+		}
+	}
+}
+
+func TestPruneBackupsAgeAndChecksumFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"bitwarden-work-backup-2026-01-01-010000.json.age",
+		"bitwarden-work-backup-2026-01-02-010000.encrypted.json.age",
+		"bitwarden-work-backup-2026-01-03-010000.json",
+		"bitwarden-work-backup-2026-01-01-010000.json.age.sha256",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deleted, err := pruneBackups(dir, "work", "", 2)
+	if err != nil {
+		t.Fatalf("pruneBackups() unexpected error: %v", err)
+	}
+	expected := []string{"bitwarden-work-backup-2026-01-01-010000.json.age"}
+	if !reflect.DeepEqual(deleted, expected) {
+		t.Errorf("deleted = %v, expected %v", deleted, expected)
+	}
+	if _, err := os.Stat(filepath.Join(dir, expected[0]+checksumExtension)); !os.IsNotExist(err) {
+		t.Errorf("checksum of a pruned backup still exists (stat error %v)", err)
+	}
+}
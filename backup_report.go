@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	vaultStatusSuccess     = "success"
+	vaultStatusFailed      = "failed"
+	vaultStatusInterrupted = "interrupted"
+	vaultStatusUploadFail  = "upload_failed" // Backed up locally, but the upload failed
+)
+
+// backupReport is the machine-readable outcome of a batch backup written by --report
+type backupReport struct {
+	Timestamp    time.Time     `json:"timestamp" yaml:"timestamp"`
+	Successful   int           `json:"successful" yaml:"successful"`
+	Failed       int           `json:"failed" yaml:"failed"`
+	UploadFailed int           `json:"upload_failed,omitempty" yaml:"upload_failed,omitempty"`
+	Interrupted  bool          `json:"interrupted,omitempty" yaml:"interrupted,omitempty"`
+	Vaults       []vaultReport `json:"vaults" yaml:"vaults"`
+}
+
+// vaultReport records the backup of one personal or organization vault
+type vaultReport struct {
+	Profile      string `json:"profile" yaml:"profile"`
+	Organization string `json:"organization,omitempty" yaml:"organization,omitempty"` // Empty for the personal vault
+	Status       string `json:"status" yaml:"status"`
+	DurationMS   int64  `json:"duration_ms" yaml:"duration_ms"`
+	Error        string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// newVaultReport builds the report entry for a vault backup that started at start and ended with err
+func newVaultReport(profile, orgID string, start time.Time, err error) vaultReport {
+	report := vaultReport{
+		Profile:      profile,
+		Organization: orgID,
+		Status:       vaultStatusSuccess,
+		DurationMS:   time.Since(start).Milliseconds(),
+	}
+	switch {
+	case isInterrupted(err):
+		report.Status = vaultStatusInterrupted
+	case isUploadError(err):
+		report.Status = vaultStatusUploadFail
+		report.Error = err.Error()
+	case err != nil:
+		report.Status = vaultStatusFailed
+		report.Error = err.Error()
+	}
+	return report
+}
+
+// writeBackupReport writes report to path as YAML for .yaml/.yml files and JSON otherwise
+func writeBackupReport(path string, report backupReport) error {
+	for _, vault := range report.Vaults {
+		switch vault.Status {
+		case vaultStatusSuccess:
+			report.Successful++
+		case vaultStatusFailed:
+			report.Failed++
+		case vaultStatusUploadFail:
+			report.UploadFailed++
+		case vaultStatusInterrupted:
+			report.Interrupted = true
+		}
+	}
+	if report.Vaults == nil {
+		report.Vaults = []vaultReport{}
+	}
+
+	var data []byte
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		data, err = yaml.Marshal(report)
+	default:
+		data, err = json.MarshalIndent(report, "", "  ")
+		data = append(data, '\n')
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode backup report: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write backup report: %w", err)
+	}
+	return nil
+}
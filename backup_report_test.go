@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestNewVaultReport(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus string
+		wantError  string
+	}{
+		{name: "success", wantStatus: vaultStatusSuccess},
+		{name: "failure", err: errors.New("exit status 1"), wantStatus: vaultStatusFailed, wantError: "exit status 1"},
+		{name: "interrupted", err: context.Canceled, wantStatus: vaultStatusInterrupted},
+		{name: "upload failure", err: &uploadError{path: "/b/x.json", err: errors.New("denied")}, wantStatus: vaultStatusUploadFail, wantError: "backup saved to /b/x.json but upload failed: denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newVaultReport("work", "org-1", time.Now(), tt.err)
+			if got.Status != tt.wantStatus || got.Error != tt.wantError {
+				t.Errorf("newVaultReport() = %+v, want status %q error %q", got, tt.wantStatus, tt.wantError)
+			}
+			if got.Profile != "work" || got.Organization != "org-1" {
+				t.Errorf("newVaultReport() = %+v, want profile work organization org-1", got)
+			}
+		})
+	}
+}
+
+func TestWriteBackupReport(t *testing.T) {
+	report := backupReport{
+		Timestamp: time.Date(2026, 1, 1, 2, 0, 0, 0, time.UTC),
+		Vaults: []vaultReport{
+			{Profile: "work", Status: vaultStatusSuccess, DurationMS: 1200},
+			{Profile: "work", Organization: "org-1", Status: vaultStatusFailed, DurationMS: 300, Error: "exit status 1"},
+			{Profile: "home", Status: vaultStatusSuccess, DurationMS: 900},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		file      string
+		unmarshal func([]byte, any) error
+	}{
+		{name: "json", file: "report.json", unmarshal: json.Unmarshal},
+		{name: "yaml", file: "report.yaml", unmarshal: yaml.Unmarshal},
+		{name: "other extension is json", file: "report.txt", unmarshal: json.Unmarshal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := writeBackupReport(path, report); err != nil {
+				t.Fatalf("writeBackupReport() error = %v", err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got backupReport
+			if err := tt.unmarshal(data, &got); err != nil {
+				t.Fatalf("failed to decode report: %v\n%s", err, data)
+			}
+			if got.Successful != 2 || got.Failed != 1 || got.Interrupted {
+				t.Errorf("counts = %d successful, %d failed, interrupted %v; want 2, 1, false", got.Successful, got.Failed, got.Interrupted)
+			}
+			if !got.Timestamp.Equal(report.Timestamp) {
+				t.Errorf("timestamp = %v, want %v", got.Timestamp, report.Timestamp)
+			}
+			if len(got.Vaults) != 3 || got.Vaults[1] != report.Vaults[1] {
+				t.Errorf("vaults = %+v, want %+v", got.Vaults, report.Vaults)
+			}
+		})
+	}
+}
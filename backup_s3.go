@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/urfave/cli/v2"
+)
+
+// s3Target is where --s3-bucket uploads finished backups
+type s3Target struct {
+	Bucket    string
+	Prefix    string
+	Endpoint  string // S3-compatible endpoint URL; empty for AWS
+	Region    string // Empty uses the AWS configuration chain
+	PathStyle bool   // Address buckets as endpoint/bucket instead of bucket.endpoint
+}
+
+// uploadError marks a failed upload of a backup that was written locally, so summaries can tell
+// "the data is safe on disk" apart from a failed backup
+type uploadError struct {
+	path string
+	err  error
+}
+
+func (e *uploadError) Error() string {
+	return fmt.Sprintf("backup saved to %s but upload failed: %v", e.path, e.err)
+}
+
+func (e *uploadError) Unwrap() error {
+	return e.err
+}
+
+// isUploadError reports whether err is a failed upload of a backup that was written locally
+func isUploadError(err error) bool {
+	var upload *uploadError
+	return errors.As(err, &upload)
+}
+
+// s3TargetFromFlags returns the upload target, or nil when --s3-bucket is not set
+func s3TargetFromFlags(c *cli.Context) (*s3Target, error) {
+	target := &s3Target{
+		Bucket:    c.String("s3-bucket"),
+		Prefix:    c.String("s3-prefix"),
+		Endpoint:  c.String("s3-endpoint"),
+		Region:    c.String("s3-region"),
+		PathStyle: c.Bool("s3-path-style"),
+	}
+	if target.Bucket == "" {
+		for _, name := range []string{"s3-prefix", "s3-endpoint", "s3-region", "s3-path-style"} {
+			if c.IsSet(name) {
+				return nil, fmt.Errorf("--%s requires --s3-bucket", name)
+			}
+		}
+		return nil, nil
+	}
+	if target.Endpoint != "" {
+		if err := validateServerURL(target.Endpoint); err != nil {
+			return nil, err
+		}
+	}
+	return target, nil
+}
+
+// key returns the object key of a backup file under the configured prefix
+func (t *s3Target) key(path string) string {
+	prefix := strings.Trim(t.Prefix, "/")
+	if prefix == "" {
+		return filepath.Base(path)
+	}
+	return prefix + "/" + filepath.Base(path)
+}
+
+// upload puts a vault's backup file at path into the bucket, auditing the attempt. Credentials come from
+// the standard AWS chain (environment, shared config and credentials files, SSO, instance roles), never
+// from the keychain. Failures are returned as *uploadError.
+func (t *s3Target) upload(path, profile, orgID string) error {
+	finishAudit := audit.Run("upload", profile, orgID, filepath.Base(path))
+	err := t.put(path)
+	finishAudit(err)
+	if err != nil {
+		return &uploadError{path: path, err: err}
+	}
+	infof("Uploaded %s to s3://%s/%s\n", filepath.Base(path), t.Bucket, t.key(path))
+	return nil
+}
+
+// put performs the PutObject request, cancelling it on interrupt
+func (t *s3Target) put(path string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var loadOptions []func(*awsconfig.LoadOptions) error
+	if t.Region != "" {
+		loadOptions = append(loadOptions, awsconfig.WithRegion(t.Region))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, loadOptions...)
+	if err != nil {
+		return fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if t.Endpoint != "" {
+			o.BaseEndpoint = aws.String(t.Endpoint)
+		}
+		o.UsePathStyle = t.PathStyle
+	})
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat backup: %w", err)
+	}
+
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(t.Bucket),
+		Key:           aws.String(t.key(path)),
+		Body:          file,
+		ContentLength: aws.Int64(info.Size()),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload to s3://%s/%s: %w", t.Bucket, t.key(path), err)
+	}
+	return nil
+}
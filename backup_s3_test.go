@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestS3TargetKey(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{prefix: "", want: "bitwarden-backup-2026-01-01-020000.json"},
+		{prefix: "bitwarden", want: "bitwarden/bitwarden-backup-2026-01-01-020000.json"},
+		{prefix: "/backups/bitwarden/", want: "backups/bitwarden/bitwarden-backup-2026-01-01-020000.json"},
+	}
+
+	for _, tt := range tests {
+		target := &s3Target{Bucket: "vault", Prefix: tt.prefix}
+		if got := target.key("/backups/bitwarden-backup-2026-01-01-020000.json"); got != tt.want {
+			t.Errorf("key() with prefix %q = %s, want %s", tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestS3TargetUpload(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+
+	var gotPath string
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		gotPath = r.URL.Path
+		gotBody, _ = io.ReadAll(r.Body)
+		if bytes.Contains(gotBody, []byte("reject")) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bitwarden-work-backup-2026-01-01-020000.json")
+	if err := os.WriteFile(path, []byte(`{"items":[]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	target := &s3Target{Bucket: "vault", Prefix: "bitwarden", Endpoint: server.URL, Region: "us-east-1", PathStyle: true}
+
+	if err := target.upload(path, "work", ""); err != nil {
+		t.Fatalf("upload() error = %v", err)
+	}
+	if want := "/vault/bitwarden/bitwarden-work-backup-2026-01-01-020000.json"; gotPath != want {
+		t.Errorf("uploaded to %s, want %s", gotPath, want)
+	}
+	if !bytes.Contains(gotBody, []byte(`{"items":[]}`)) {
+		t.Errorf("uploaded body = %q, want the backup contents", gotBody)
+	}
+
+	// A rejected upload is reported as an upload failure, not a backup failure
+	if err := os.WriteFile(path, []byte("reject"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	err := target.upload(path, "work", "")
+	if !isUploadError(err) {
+		t.Errorf("upload() error = %v, expected an upload error", err)
+	}
+}
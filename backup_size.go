@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vupham90/containers/internal/bytesize"
+)
+
+// backupSizeStateFile records the size of the last backup of each vault, kept inside the backup directory
+const backupSizeStateFile = ".bw-backup-sizes.json"
+
+// shrinkMonitor compares each new backup with the previous one of the same vault and
+// alerts when it shrank by more than threshold percent. It is safe for concurrent use.
+type shrinkMonitor struct {
+	threshold float64 // Percentage; 0 disables the check
+	fail      bool    // Turn alerts into errors so the run exits non-zero
+
+	mu     sync.Mutex // Serializes state file updates and alerts
+	alerts []string
+}
+
+// newShrinkMonitor validates the threshold percentage
+func newShrinkMonitor(threshold float64, fail bool) (*shrinkMonitor, error) {
+	if threshold < 0 || threshold > 100 {
+		return nil, fmt.Errorf("--summary-threshold must be between 0 and 100, got %g", threshold)
+	}
+	return &shrinkMonitor{threshold: threshold, fail: fail}, nil
+}
+
+// shrinkPercent returns how much current shrank relative to previous, in percent (negative if it grew)
+func shrinkPercent(previous, current int64) float64 {
+	if previous <= 0 {
+		return 0
+	}
+	return float64(previous-current) / float64(previous) * 100
+}
+
+// check records the size of the profile/organization backup written to backupDir since the given time
+// and alerts if it shrank beyond the threshold
+func (m *shrinkMonitor) check(backupDir, profile, orgID, ext string, since time.Time) error {
+	if m == nil || m.threshold == 0 {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := backupSizeKey(profile, orgID)
+	path, size, err := newestBackupSince(backupDir, backupFilePrefix(profile, orgID), ext, since)
+	if err != nil {
+		return err
+	}
+
+	statePath := filepath.Join(backupDir, backupSizeStateFile)
+	sizes, err := loadBackupSizes(statePath)
+	if err != nil {
+		return err
+	}
+	previous, seen := sizes[key]
+	sizes[key] = size
+	if err := saveBackupSizes(statePath, sizes); err != nil {
+		return err
+	}
+
+	if !seen {
+		return nil
+	}
+	shrunk := shrinkPercent(previous, size)
+	if shrunk <= m.threshold {
+		return nil
+	}
+
+	alert := fmt.Sprintf("%s: backup shrank %.0f%% (%s -> %s) in %s",
+		key, shrunk, bytesize.FormatSize(previous), bytesize.FormatSize(size), filepath.Base(path))
+	m.alerts = append(m.alerts, alert)
+	fmt.Fprintln(os.Stderr, colorWarning("WARNING:"), alert)
+	if m.fail {
+		return fmt.Errorf("backup shrank by more than %g%%: %s", m.threshold, alert)
+	}
+	return nil
+}
+
+// newestBackupSince returns the newest file in dir with the given prefix and extension modified at or after since
+func newestBackupSince(dir, prefix, ext string, since time.Time) (string, int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	var newest string
+	var newestInfo os.FileInfo
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), prefix) || !strings.HasSuffix(entry.Name(), "."+ext) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		// Filesystem timestamps may be coarser than the clock; allow a second of slack
+		if info.ModTime().Before(since.Add(-time.Second)) {
+			continue
+		}
+		if newestInfo == nil || info.ModTime().After(newestInfo.ModTime()) {
+			newest, newestInfo = entry.Name(), info
+		}
+	}
+	if newestInfo == nil {
+		return "", 0, fmt.Errorf("no new .%s backup found in %s", ext, dir)
+	}
+	return filepath.Join(dir, newest), newestInfo.Size(), nil
+}
+
+// loadBackupSizes reads the size state file; a missing file means no history
+func loadBackupSizes(path string) (map[string]int64, error) {
+	sizes := make(map[string]int64)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return sizes, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup size state: %w", err)
+	}
+	if err := json.Unmarshal(data, &sizes); err != nil {
+		return nil, fmt.Errorf("failed to parse backup size state %s: %w", path, err)
+	}
+	return sizes, nil
+}
+
+// saveBackupSizes writes the size state atomically
+func saveBackupSizes(path string, sizes map[string]int64) error {
+	data, err := json.MarshalIndent(sizes, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode backup size state: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write backup size state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write backup size state: %w", err)
+	}
+	return nil
+}
+
+// backupFilePrefix mirrors backup.sh's file naming, so concurrent backups of other vaults into the
+// same directory are never mistaken for this one
+func backupFilePrefix(profile, orgID string) string {
+	switch {
+	case profile != "" && orgID != "":
+		return "bitwarden-" + profile + "-org-" + orgID + "-backup-"
+	case orgID != "":
+		return "bitwarden-org-" + orgID + "-backup-"
+	case profile != "":
+		return "bitwarden-" + profile + "-backup-"
+	default:
+		return "bitwarden-backup-"
+	}
+}
+
+// backupSizeKey identifies a vault in the size state
+func backupSizeKey(profile, orgID string) string {
+	if profile == "" {
+		profile = "default"
+	}
+	if orgID == "" {
+		return profile + "/personal"
+	}
+	return profile + "/org-" + orgID
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShrinkMonitor(t *testing.T) {
+	dir := t.TempDir()
+	monitor, err := newShrinkMonitor(50, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(name string, size int) time.Time {
+		t.Helper()
+		start := time.Now()
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, size), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return start
+	}
+
+	// First run only records the size
+	since := write("bitwarden-backup-1.json", 1000)
+	if err := monitor.check(dir, "", "", "json", since); err != nil {
+		t.Fatalf("first backup: unexpected error %v", err)
+	}
+
+	// Shrinking within the threshold passes
+	time.Sleep(10 * time.Millisecond)
+	since = write("bitwarden-backup-2.json", 600)
+	if err := monitor.check(dir, "", "", "json", since); err != nil {
+		t.Fatalf("40%% shrink: unexpected error %v", err)
+	}
+
+	// Shrinking beyond it fails and is recorded for the summary
+	time.Sleep(10 * time.Millisecond)
+	since = write("bitwarden-backup-3.json", 100)
+	if err := monitor.check(dir, "", "", "json", since); err == nil {
+		t.Fatal("83% shrink: expected error")
+	}
+	if len(monitor.alerts) != 1 {
+		t.Errorf("alerts = %v, want 1 alert", monitor.alerts)
+	}
+
+	if _, err := newShrinkMonitor(150, false); err == nil {
+		t.Error("threshold above 100: expected error")
+	}
+}
+
+func TestShrinkPercent(t *testing.T) {
+	if got := shrinkPercent(200, 50); got != 75 {
+		t.Errorf("shrinkPercent(200, 50) = %v, want 75", got)
+	}
+	if got := shrinkPercent(100, 150); got != -50 {
+		t.Errorf("shrinkPercent(100, 150) = %v, want -50", got)
+	}
+	if got := shrinkPercent(0, 10); got != 0 {
+		t.Errorf("shrinkPercent(0, 10) = %v, want 0", got)
+	}
+}
+
+func TestBackupFilePrefix(t *testing.T) {
+	tests := []struct {
+		profile, orgID, expected string
+	}{
+		{"", "", "bitwarden-backup-"},
+		{"work", "", "bitwarden-work-backup-"},
+		{"", "abc", "bitwarden-org-abc-backup-"},
+		{"work", "abc", "bitwarden-work-org-abc-backup-"},
+	}
+	for _, tt := range tests {
+		if got := backupFilePrefix(tt.profile, tt.orgID); got != tt.expected {
+			t.Errorf("backupFilePrefix(%q, %q) = %q, expected %q", tt.profile, tt.orgID, got, tt.expected)
+		}
+	}
+}
+
+func TestShrinkMonitorConcurrentProfiles(t *testing.T) {
+	dir := t.TempDir()
+	monitor, err := newShrinkMonitor(50, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	since := time.Now()
+	profiles := []string{"alpha", "beta", "gamma", "delta"}
+	for i, profile := range profiles {
+		name := "bitwarden-" + profile + "-backup-1.json"
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, 100*(i+1)), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, profile := range profiles {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := monitor.check(dir, profile, "", "json", since); err != nil {
+				t.Errorf("check(%s) unexpected error: %v", profile, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Every profile's own file is recorded, none lost to concurrent state updates
+	sizes, err := loadBackupSizes(filepath.Join(dir, backupSizeStateFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, profile := range profiles {
+		if size := sizes[profile+"/personal"]; size != int64(100*(i+1)) {
+			t.Errorf("recorded size for %s = %d, expected %d", profile, size, 100*(i+1))
+		}
+	}
+}
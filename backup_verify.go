@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// checksumExtension is appended to a backup's name for its --verify SHA-256 sidecar
+const checksumExtension = ".sha256"
+
+// ageMagic is the first line of every age-encrypted file
+var ageMagic = []byte("age-encryption.org/v1\n")
+
+// backupFilename is the name the backup container is told to write (BW_BACKUP_FILENAME), matching
+// backup.sh's own naming so pruning and size tracking treat both alike
+func backupFilename(profile, orgID string, format backupFormat, t time.Time) string {
+	return backupFilePrefix(profile, orgID) + t.UTC().Format("2006-01-02-150405") + "." + format.Extension()
+}
+
+// verifyVaultBackup checks the SHA-256 sidecars of earlier backups of the vault, then checks the new
+// backup at path and records its own sidecar
+func verifyVaultBackup(path, profile, orgID string, format backupFormat) error {
+	if err := checkChecksumSidecars(filepath.Dir(path), profile, orgID); err != nil {
+		return err
+	}
+	if err := verifyBackupFile(path, format); err != nil {
+		return err
+	}
+	if err := writeChecksumSidecar(path); err != nil {
+		return err
+	}
+	audit.Event(auditEvent{Event: "backup_verified", Profile: profile, Organization: orgID, File: filepath.Base(path)})
+	return nil
+}
+
+// verifyBackupFile checks that a backup is non-empty and looks like its format: age files must start with
+// the age header, JSON exports must parse, and encrypted_json exports must be marked password protected
+func verifyBackupFile(path string, format backupFormat) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read backup for verification: %w", err)
+	}
+	name := filepath.Base(path)
+	if len(data) == 0 {
+		return fmt.Errorf("backup verification failed: %s is empty", name)
+	}
+
+	if strings.HasSuffix(path, ageExtension) {
+		if !bytes.HasPrefix(data, ageMagic) {
+			return fmt.Errorf("backup verification failed: %s has no age header", name)
+		}
+		return nil
+	}
+
+	switch format {
+	case formatCSV:
+		return nil
+	case formatEncryptedJSON:
+		var header struct {
+			Encrypted         bool `json:"encrypted"`
+			PasswordProtected bool `json:"passwordProtected"`
+		}
+		if err := json.Unmarshal(data, &header); err != nil {
+			return fmt.Errorf("backup verification failed: %s is not valid JSON (truncated?): %w", name, err)
+		}
+		if !header.Encrypted || !header.PasswordProtected {
+			return fmt.Errorf("backup verification failed: %s is not a password-protected export", name)
+		}
+		return nil
+	default:
+		if !json.Valid(data) {
+			return fmt.Errorf("backup verification failed: %s is not valid JSON (truncated?)", name)
+		}
+		return nil
+	}
+}
+
+// writeChecksumSidecar writes path.sha256 in sha256sum format, so `sha256sum -c` can check it too
+func writeChecksumSidecar(path string) error {
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return fmt.Errorf("failed to hash backup: %w", err)
+	}
+	line := fmt.Sprintf("%s  %s\n", sum, filepath.Base(path))
+	if err := os.WriteFile(path+checksumExtension, []byte(line), 0600); err != nil {
+		return fmt.Errorf("failed to write checksum: %w", err)
+	}
+	return nil
+}
+
+// checkChecksumSidecars re-hashes every backup of the vault in dir that has a sidecar and fails on the
+// first mismatch. Sidecars whose backup was deleted are ignored.
+func checkChecksumSidecars(dir, profile, orgID string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read backup directory: %w", err)
+	}
+	// Match whole backup names: a bare prefix would also cover vaults whose name extends this one's
+	pattern := regexp.MustCompile("^" + regexp.QuoteMeta(backupFilePrefix(profile, orgID)) + backupTimestampPattern +
+		regexp.QuoteMeta(checksumExtension) + "$")
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !pattern.MatchString(name) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return fmt.Errorf("failed to read checksum: %w", err)
+		}
+		fields := strings.Fields(string(data))
+		if len(fields) == 0 {
+			return fmt.Errorf("backup verification failed: %s is empty", name)
+		}
+
+		backup := strings.TrimSuffix(name, checksumExtension)
+		sum, err := fileSHA256(filepath.Join(dir, backup))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to hash backup: %w", err)
+		}
+		if sum != fields[0] {
+			return fmt.Errorf("backup verification failed: %s does not match its checksum (modified or corrupted)", backup)
+		}
+	}
+	return nil
+}
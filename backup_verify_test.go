@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackupFilename(t *testing.T) {
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))
+	got := backupFilename("work", "abc", formatEncryptedJSON, at)
+	if want := "bitwarden-work-org-abc-backup-2026-01-02-020405.encrypted.json"; got != want {
+		t.Errorf("backupFilename() = %s, want %s", got, want)
+	}
+	if !strings.HasPrefix(got, backupFilePrefix("work", "abc")) {
+		t.Errorf("backupFilename() = %s does not start with the vault prefix", got)
+	}
+}
+
+func TestVerifyBackupFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		content   string
+		format    backupFormat
+		expectErr bool
+	}{
+		{name: "json", file: "b.json", content: `{"items":[]}`, format: formatJSON},
+		{name: "truncated json", file: "b.json", content: `{"items":[`, format: formatJSON, expectErr: true},
+		{name: "empty", file: "b.csv", content: "", format: formatCSV, expectErr: true},
+		{name: "csv", file: "b.csv", content: "folder,name\n", format: formatCSV},
+		{name: "encrypted json", file: "b.encrypted.json", content: `{"encrypted":true,"passwordProtected":true,"data":"x"}`, format: formatEncryptedJSON},
+		{name: "unprotected encrypted json", file: "b.encrypted.json", content: `{"encrypted":false,"items":[]}`, format: formatEncryptedJSON, expectErr: true},
+		{name: "age", file: "b.json.age", content: "age-encryption.org/v1\n-> X25519 abc\n", format: formatJSON},
+		{name: "age without header", file: "b.json.age", content: `{"items":[]}`, format: formatJSON, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := verifyBackupFile(path, tt.format); (err != nil) != tt.expectErr {
+				t.Errorf("verifyBackupFile() error = %v, expectErr %v", err, tt.expectErr)
+			}
+		})
+	}
+}
+
+func TestVerifyVaultBackupChecksSidecars(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	first := write("bitwarden-work-backup-2026-01-01-010000.json", `{"items":[]}`)
+	if err := verifyVaultBackup(first, "work", "", formatJSON); err != nil {
+		t.Fatalf("verifyVaultBackup() first run error = %v", err)
+	}
+	sidecar, err := os.ReadFile(first + checksumExtension)
+	if err != nil {
+		t.Fatalf("sidecar missing: %v", err)
+	}
+	if !strings.HasSuffix(string(sidecar), "  bitwarden-work-backup-2026-01-01-010000.json\n") {
+		t.Errorf("sidecar = %q, want sha256sum format", sidecar)
+	}
+
+	// Another vault's corrupted backup does not fail this one
+	other := write("bitwarden-home-backup-2026-01-01-010000.json", `{"items":[]}`)
+	if err := writeChecksumSidecar(other); err != nil {
+		t.Fatal(err)
+	}
+	write(filepath.Base(other), `{"items":[1]}`)
+	// ...nor does one of a profile whose name starts with this one's backup prefix
+	extended := write("bitwarden-work-backup-archive-backup-2026-01-01-010000.json", `{"items":[]}`)
+	if err := writeChecksumSidecar(extended); err != nil {
+		t.Fatal(err)
+	}
+	write(filepath.Base(extended), `{"items":[1]}`)
+
+	second := write("bitwarden-work-backup-2026-01-02-010000.json", `{"items":[]}`)
+	if err := verifyVaultBackup(second, "work", "", formatJSON); err != nil {
+		t.Fatalf("verifyVaultBackup() second run error = %v", err)
+	}
+
+	// A modified earlier backup is caught on the next run
+	write(filepath.Base(first), `{"items":[1]}`)
+	third := write("bitwarden-work-backup-2026-01-03-010000.json", `{"items":[]}`)
+	if err := verifyVaultBackup(third, "work", "", formatJSON); err == nil {
+		t.Error("verifyVaultBackup() expected an error for a modified earlier backup")
+	}
+}
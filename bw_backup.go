@@ -1,47 +1,197 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+	"unicode"
 
 	"github.com/urfave/cli/v2"
+	"github.com/vupham90/containers/internal/bytesize"
+	"github.com/vupham90/containers/internal/logging"
 	"github.com/vupham90/containers/keychain"
 	"gopkg.in/yaml.v3"
 )
 
+// bwKeychainService is the keychain service holding all bw-backup credentials
+const bwKeychainService = "containers-bw-backup"
+
 // BackupProfile represents a single backup profile configuration
 type BackupProfile struct {
 	Name          string   `yaml:"name"`
 	BackupDir     string   `yaml:"backup_dir"`
 	Organizations []string `yaml:"organizations,omitempty"`
+	Server        string   `yaml:"server,omitempty"` // Self-hosted server URL; overrides --server
+
+	// BackupPasswordAccount is the keychain account of this profile's own backup password;
+	// when empty the batch-wide password from --encrypt/--backup-password is used
+	BackupPasswordAccount string `yaml:"backup_password_account,omitempty"`
 }
 
 // BackupConfig represents the YAML configuration for batch backups
 type BackupConfig struct {
-	Profiles []BackupProfile `yaml:"profiles"`
+	KeychainAccountPrefix string          `yaml:"keychain_account_prefix,omitempty"`
+	Profiles              []BackupProfile `yaml:"profiles"`
 }
 
-// getCredential retrieves a credential from CLI flag or macOS Keychain
-func getCredential(flagValue, keychainAccount, profile string, reset bool) (string, error) {
-	if flagValue != "" {
-		return flagValue, nil
+// backupFormat is a Bitwarden export format
+type backupFormat string
+
+const (
+	formatJSON          backupFormat = "json"
+	formatEncryptedJSON backupFormat = "encrypted_json"
+	formatCSV           backupFormat = "csv"
+)
+
+// Extension returns the file extension the backup container uses for this format
+func (f backupFormat) Extension() string {
+	switch f {
+	case formatEncryptedJSON:
+		return "encrypted.json"
+	case formatCSV:
+		return "csv"
+	default:
+		return "json"
+	}
+}
+
+// resolveBackupFormat validates --format against whether a backup password is set.
+// An empty format keeps the historical default: encrypted_json with a password, json without.
+func resolveBackupFormat(format string, encrypted bool) (backupFormat, error) {
+	switch backupFormat(format) {
+	case "":
+		if encrypted {
+			return formatEncryptedJSON, nil
+		}
+		return formatJSON, nil
+	case formatEncryptedJSON:
+		if !encrypted {
+			return "", fmt.Errorf("--format encrypted_json requires --encrypt or --backup-password")
+		}
+		return formatEncryptedJSON, nil
+	case formatJSON, formatCSV:
+		if encrypted {
+			return "", fmt.Errorf("--format %s is unencrypted; use --format encrypted_json with --encrypt/--backup-password", format)
+		}
+		return backupFormat(format), nil
+	default:
+		return "", fmt.Errorf("invalid format: %s (must be json, encrypted_json or csv)", format)
 	}
+}
 
-	// Build keychain account name with profile suffix if provided
-	account := keychainAccount
+// validateServerURL checks that a Bitwarden server URL is an absolute http(s) URL
+func validateServerURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid server URL %q: %w", raw, err)
+	}
+	if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("invalid server URL %q: must be an absolute http(s) URL", raw)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("invalid server URL %q: must not contain a query or fragment", raw)
+	}
+	return nil
+}
+
+// resettableCredentials lists the credential names accepted by --reset-only
+var resettableCredentials = []string{"client-id", "client-secret", "password", "backup-password"}
+
+// credentialResets decides which stored credentials are deleted and re-prompted
+type credentialResets struct {
+	all  bool
+	only map[string]bool
+}
+
+// newCredentialResets builds the reset policy from --reset and --reset-only
+func newCredentialResets(c *cli.Context) (credentialResets, error) {
+	resets := credentialResets{all: c.Bool("reset"), only: make(map[string]bool)}
+	for _, name := range c.StringSlice("reset-only") {
+		if !slices.Contains(resettableCredentials, name) {
+			return credentialResets{}, fmt.Errorf("invalid --reset-only credential: %s (must be one of: %s)",
+				name, strings.Join(resettableCredentials, ", "))
+		}
+		resets.only[name] = true
+	}
+	return resets, nil
+}
+
+// reset reports whether the named credential should be reset
+func (r credentialResets) reset(name string) bool {
+	return r.all || r.only[name]
+}
+
+// keychainAccountName builds the keychain account name from an optional namespace prefix,
+// the base account name and an optional profile suffix (e.g. work_bitwarden_client_id_personal)
+func keychainAccountName(prefix, baseAccount, profile string) string {
+	account := baseAccount
+	if prefix != "" {
+		account = fmt.Sprintf("%s_%s", prefix, account)
+	}
 	if profile != "" {
-		account = fmt.Sprintf("%s_%s", keychainAccount, profile)
+		account = fmt.Sprintf("%s_%s", account, profile)
+	}
+	return account
+}
+
+// dryRunCredential stands in for keychain credentials under --dry-run, which never reads or prompts for them
+const dryRunCredential = "dry-run-placeholder"
+
+// getCredential retrieves a credential from CLI flag or the platform keychain
+func getCredential(flagValue, keychainAccount, prefix, profile string, reset bool) (string, error) {
+	if flagValue != "" {
+		logging.Debugf("credential %s: taken from its flag", keychainAccount)
+		return flagValue, nil
+	}
+	if runtimeSettings.DryRun {
+		return dryRunCredential, nil
 	}
 
+	// Build keychain account name with prefix and profile suffix if provided
+	account := keychainAccountName(prefix, keychainAccount, profile)
+	logging.Debugf("credential %s: keychain service=%s account=%s", keychainAccount, bwKeychainService, account)
+
 	// Use keychain with reset flag
-	serviceName := "containers-bw-backup"
-	return keychain.GetOrSetPassword(serviceName, account, reset)
+	return keychain.GetOrSetPassword(bwKeychainService, account, reset)
+}
+
+// minBackupPasswordLength is the length below which a new backup password draws a warning
+const minBackupPasswordLength = 12
+
+// backupPasswordWarning returns a warning for an easily guessed backup encryption password, or "" if it looks fine.
+// Losing or mistyping this password makes every backup undecryptable, so it is only a warning.
+func backupPasswordWarning(password string) string {
+	if len([]rune(password)) < minBackupPasswordLength {
+		return fmt.Sprintf("backup password is shorter than %d characters", minBackupPasswordLength)
+	}
+	var classes int
+	for _, class := range []func(rune) bool{unicode.IsLower, unicode.IsUpper, unicode.IsDigit, isSymbol} {
+		if strings.IndexFunc(password, class) >= 0 {
+			classes++
+		}
+	}
+	if classes < 2 {
+		return "backup password uses only one kind of character; mix letters, digits or symbols"
+	}
+	return ""
+}
+
+// isSymbol reports whether r is neither a letter nor a digit
+func isSymbol(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
 }
 
 // getBackupPassword retrieves the backup password with Option 2 logic
-func getBackupPassword(c *cli.Context, reset bool) (string, error) {
+func getBackupPassword(c *cli.Context, prefix string, reset bool) (string, error) {
 	// If explicit password provided, use it
 	if c.IsSet("backup-password") {
 		return c.String("backup-password"), nil
@@ -49,48 +199,99 @@ func getBackupPassword(c *cli.Context, reset bool) (string, error) {
 
 	// If --encrypt flag set, get from keychain
 	if c.Bool("encrypt") {
-		serviceName := "containers-bw-backup"
-		return keychain.GetOrSetPassword(serviceName, "bitwarden_backup_password", reset)
+		return getCheckedBackupPassword("bitwarden_backup_password", prefix, reset)
 	}
 
 	// No encryption
 	return "", nil
 }
 
+// getCheckedBackupPassword retrieves a backup password from the keychain account (--encrypt's, or a
+// profile's backup_password_account), warning when a newly entered one looks weak
+func getCheckedBackupPassword(keychainAccount, prefix string, reset bool) (string, error) {
+	if runtimeSettings.DryRun {
+		return dryRunCredential, nil
+	}
+	account := keychainAccountName(prefix, keychainAccount, "")
+	logging.Debugf("backup password: keychain service=%s account=%s", bwKeychainService, account)
+	return keychain.GetOrSetPasswordChecked(bwKeychainService, account, reset, backupPasswordWarning)
+}
+
 // runBwBackup executes the Bitwarden backup command
 func runBwBackup(c *cli.Context) error {
+	audit.prefix = c.Bool("prefix-logs")
+	if err := setAuditFormat(c.String("audit-format")); err != nil {
+		return err
+	}
+
+	// Reject bad limits and digests before prompting for credentials
+	if _, err := resourceLimitsFromFlags(c); err != nil {
+		return err
+	}
+	if _, err := backupImage(c); err != nil {
+		return err
+	}
+	if c.Int("keep") < 0 {
+		return fmt.Errorf("--keep must not be negative")
+	}
+	if err := validateAgeRecipient(c.String("age-recipient")); err != nil {
+		return err
+	}
+	target, err := s3TargetFromFlags(c)
+	if err != nil {
+		return err
+	}
+
+	// Validate a batch config without running anything
+	if preflightPath := c.String("preflight"); preflightPath != "" {
+		return runBackupPreflight(c, preflightPath)
+	}
+
 	// Check if batch mode (profiles YAML file provided)
 	profilesPath := c.String("profiles")
 	if profilesPath != "" {
-		return runBatchBackup(c, profilesPath)
+		return runBatchBackup(c, profilesPath, target)
 	}
 
 	// Single backup mode
-	return runSingleBackup(c)
+	return runSingleBackup(c, target)
 }
 
-// runSingleBackup handles single profile/organization backup
-func runSingleBackup(c *cli.Context) error {
-	reset := c.Bool("reset")
+// runSingleBackup handles single profile/organization backup, uploading it to target unless nil
+func runSingleBackup(c *cli.Context, target *s3Target) error {
+	resets, err := newCredentialResets(c)
+	if err != nil {
+		return err
+	}
 	profile := c.String("profile")
 	orgID := c.String("organization-id")
+	prefix := c.String("keychain-account-prefix")
+	monitor, err := newShrinkMonitor(c.Float64("summary-threshold"), c.Bool("summary-threshold-fail"))
+	if err != nil {
+		return err
+	}
 
 	// Get credentials (flags or Keychain with reset option and profile support)
-	clientID, err := getCredential(c.String("client-id"), "bitwarden_client_id", profile, reset)
+	clientID, err := getCredential(c.String("client-id"), "bitwarden_client_id", prefix, profile, resets.reset("client-id"))
 	if err != nil {
 		return err
 	}
-	clientSecret, err := getCredential(c.String("client-secret"), "bitwarden_client_secret", profile, reset)
+	clientSecret, err := getCredential(c.String("client-secret"), "bitwarden_client_secret", prefix, profile, resets.reset("client-secret"))
 	if err != nil {
 		return err
 	}
-	password, err := getCredential(c.String("password"), "bitwarden_password", profile, reset)
+	password, err := getCredential(c.String("password"), "bitwarden_password", prefix, profile, resets.reset("password"))
 	if err != nil {
 		return err
 	}
 
 	// Get backup password (optional, global)
-	backupPassword, err := getBackupPassword(c, reset)
+	backupPassword, err := getBackupPassword(c, prefix, resets.reset("backup-password"))
+	if err != nil {
+		return err
+	}
+
+	format, err := resolveBackupFormat(c.String("format"), backupPassword != "")
 	if err != nil {
 		return err
 	}
@@ -119,6 +320,15 @@ func runSingleBackup(c *cli.Context) error {
 		env["BW_BACKUP_PASSWORD"] = EnvVar{Value: backupPassword, Sensitive: true}
 	}
 
+	env["BW_EXPORT_FORMAT"] = EnvVar{Value: string(format), Sensitive: false}
+
+	if server := c.String("server"); server != "" {
+		if err := validateServerURL(server); err != nil {
+			return err
+		}
+		env["BW_SERVER"] = EnvVar{Value: server, Sensitive: false}
+	}
+
 	// Add profile name if provided
 	if profile != "" {
 		env["BW_PROFILE"] = EnvVar{Value: profile, Sensitive: false}
@@ -129,159 +339,376 @@ func runSingleBackup(c *cli.Context) error {
 		env["BW_ORGANIZATIONID"] = EnvVar{Value: orgID, Sensitive: false}
 	}
 
-	// Add comprehensive tmpfs mounts for security - prevents all disk writes
-	// Note: /home/node/.config is excluded as it's mounted persistently for session data
-	tmpfsMounts := map[string]string{
-		"/tmp":              "rw,noexec,nosuid,size=100m",
-		"/home/node/.cache": "rw,noexec,nosuid,size=50m",
-		"/home/node/.local": "rw,noexec,nosuid,size=50m",
-	}
-
-	var tmpfs []string
-	for path, opts := range tmpfsMounts {
-		tmpfs = append(tmpfs, fmt.Sprintf("%s:%s", path, opts))
-	}
+	// The host picks the file name so the finished backup can be found deterministically
+	startTime := time.Now()
+	backupPath := filepath.Join(absBackupDir, backupFilename(profile, orgID, format, startTime))
+	env["BW_BACKUP_FILENAME"] = EnvVar{Value: filepath.Base(backupPath), Sensitive: false}
+	logging.Verbosef("backup file: %s", backupPath)
 
 	// Audit logging
-	startTime := time.Now()
-	fmt.Fprintf(os.Stderr, "[AUDIT] Bitwarden backup started: profile=%s time=%s\n",
-		profile, startTime.Format(time.RFC3339))
+	finishAudit := audit.Run("backup", profile, orgID, "")
 
-	// Create profile-specific config directory for persistent Bitwarden CLI sessions
-	configDir := filepath.Join(os.Getenv("HOME"), ".config", "Bitwarden CLI")
-	if profile != "" {
-		configDir = filepath.Join(os.Getenv("HOME"), ".config", fmt.Sprintf("Bitwarden CLI-%s", profile))
-	}
-	if err := os.MkdirAll(configDir, 0700); err != nil {
-		return fmt.Errorf("failed to create config dir: %w", err)
+	volumeMounts, err := sessionConfigMounts(profile)
+	if err != nil {
+		return err
 	}
 
-	// Mount config directory for persistent sessions (container runs as node user)
-	volumeMounts := []string{fmt.Sprintf("%s:/home/node/.config/Bitwarden CLI", configDir)}
-
 	// Execute backup container
-	image := "ghcr.io/vupham90/containers-bw-backup:latest"
-	fmt.Println("Starting Bitwarden backup...")
-	err = RunContainer(image, absBackupDir, []string{}, env, tmpfs, volumeMounts, true)
-
-	// Log completion
+	infof("Starting Bitwarden backup...\n")
+	err = runBackupWithSessionRefresh(c, absBackupDir, env, volumeMounts, "")
+	if runtimeSettings.DryRun {
+		return err
+	}
 	if err == nil {
-		fmt.Fprintf(os.Stderr, "[AUDIT] Bitwarden backup completed: profile=%s duration=%s\n",
-			profile, time.Since(startTime))
-	} else {
-		fmt.Fprintf(os.Stderr, "[AUDIT] Bitwarden backup failed: profile=%s duration=%s error=%v\n",
-			profile, time.Since(startTime), err)
+		backupPath, err = finishVaultBackup(c, backupPath, profile, orgID, format, startTime, monitor)
 	}
 
+	finishAudit(err)
+	if err == nil && target != nil {
+		err = target.upload(backupPath, profile, orgID)
+	}
 	return err
 }
 
-// runBatchBackup handles batch backup from YAML config
-func runBatchBackup(c *cli.Context, configPath string) error {
-	// Expand home directory if needed
-	if len(configPath) > 0 && configPath[0] == '~' {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return fmt.Errorf("failed to get home directory: %w", err)
-		}
-		configPath = filepath.Join(home, configPath[1:])
+// expandHome replaces a leading ~ with the user's home directory
+func expandHome(path string) (string, error) {
+	if len(path) == 0 || path[0] != '~' {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, path[1:]), nil
+}
+
+// loadBackupConfig reads and parses a batch backup YAML config
+func loadBackupConfig(configPath string) (BackupConfig, error) {
+	configPath, err := expandHome(configPath)
+	if err != nil {
+		return BackupConfig{}, err
 	}
 
 	// Read config file
 	data, err := os.ReadFile(configPath)
 	if err != nil {
-		return fmt.Errorf("failed to read config file: %w", err)
+		return BackupConfig{}, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	// Parse YAML config
+	// Parse YAML config strictly so a misspelled key fails instead of being left empty
 	var config BackupConfig
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return fmt.Errorf("failed to parse YAML config: %w", err)
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return BackupConfig{}, fmt.Errorf("failed to parse YAML config: %w", err)
 	}
 
 	if len(config.Profiles) == 0 {
-		return fmt.Errorf("no profiles found in config file")
+		return BackupConfig{}, fmt.Errorf("no profiles found in config file")
+	}
+	return config, nil
+}
+
+// validateBackupConfig checks every profile before a batch runs, naming the first invalid one by position
+func validateBackupConfig(config BackupConfig) error {
+	seen := make(map[string]bool)
+	for i, profile := range config.Profiles {
+		if err := validateBackupProfile(profile, seen); err != nil {
+			if profile.Name != "" {
+				return fmt.Errorf("invalid profile %d ('%s') in config: %w", i+1, profile.Name, err)
+			}
+			return fmt.Errorf("invalid profile %d in config: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// runBatchBackup handles batch backup from YAML config, uploading each backup to target unless nil
+func runBatchBackup(c *cli.Context, configPath string, target *s3Target) error {
+	config, err := loadBackupConfig(configPath)
+	if err != nil {
+		return err
+	}
+	if err := validateBackupConfig(config); err != nil {
+		return err
 	}
 
-	fmt.Printf("Starting batch backup for %d profile(s)...\n\n", len(config.Profiles))
+	infof("Starting batch backup for %d profile(s)...\n\n", len(config.Profiles))
 
-	var errors []string
+	var errors, uploadErrors []string
 	successCount := 0
-	reset := c.Bool("reset")
+	resets, err := newCredentialResets(c)
+	if err != nil {
+		return err
+	}
+	monitor, err := newShrinkMonitor(c.Float64("summary-threshold"), c.Bool("summary-threshold-fail"))
+	if err != nil {
+		return err
+	}
+
+	// Flag takes precedence over the config file prefix
+	prefix := config.KeychainAccountPrefix
+	if c.IsSet("keychain-account-prefix") {
+		prefix = c.String("keychain-account-prefix")
+	}
 
 	// Get backup password once for all profiles (global)
-	backupPassword, err := getBackupPassword(c, reset)
+	backupPassword, err := getBackupPassword(c, prefix, resets.reset("backup-password"))
 	if err != nil {
 		return err
 	}
 
-	// Process each profile sequentially
-	for i, profile := range config.Profiles {
-		fmt.Printf("[%d/%d] Processing profile: %s\n", i+1, len(config.Profiles), profile.Name)
+	concurrency := c.Int("concurrency")
+	if concurrency < 1 {
+		return fmt.Errorf("--concurrency must be at least 1")
+	}
+	reportPath := c.String("report")
 
-		// Backup personal vault
-		if err := backupVault(c, profile, "", reset, backupPassword); err != nil {
-			errors = append(errors, fmt.Sprintf("Profile '%s' personal vault: %v", profile.Name, err))
-			fmt.Printf("  ✗ Personal vault backup failed: %v\n", err)
-		} else {
-			successCount++
-			fmt.Printf("  ✓ Personal vault backup completed\n")
+	events.Emit(eventStarted, "bw-backup", configPath, fmt.Sprintf("%d profile(s)", len(config.Profiles)))
+
+	// Run up to concurrency profiles at once; results are merged under mu
+	var (
+		mu          sync.Mutex
+		wg          sync.WaitGroup
+		interrupted error
+	)
+	report := backupReport{Timestamp: time.Now().UTC()}
+	profileVaults := make([][]vaultReport, len(config.Profiles)) // Indexed by profile so the report keeps config order
+	slots := make(chan struct{}, concurrency)
+	for i, profile := range config.Profiles {
+		slots <- struct{}{}
+		mu.Lock()
+		stop := interrupted != nil
+		mu.Unlock()
+		if stop {
+			<-slots
+			break
 		}
 
-		// Backup each organization
-		for _, orgID := range profile.Organizations {
-			fmt.Printf("  → Backing up organization: %s\n", orgID)
-			if err := backupVault(c, profile, orgID, reset, backupPassword); err != nil {
-				errors = append(errors, fmt.Sprintf("Profile '%s' org '%s': %v", profile.Name, orgID, err))
-				fmt.Printf("    ✗ Organization backup failed: %v\n", err)
-			} else {
-				successCount++
-				fmt.Printf("    ✓ Organization backup completed\n")
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			// Grouped lines keep interleaved output attributable
+			linePrefix := ""
+			if concurrency > 1 {
+				linePrefix = "[" + profile.Name + "] "
+			}
+			result := backupBatchProfile(c, profile, fmt.Sprintf("[%d/%d]", i+1, len(config.Profiles)), linePrefix, prefix, resets, backupPassword, monitor, target)
+
+			mu.Lock()
+			defer mu.Unlock()
+			successCount += result.successes
+			errors = append(errors, result.errors...)
+			uploadErrors = append(uploadErrors, result.uploadErrors...)
+			profileVaults[i] = result.vaults
+			if result.interrupted != nil && interrupted == nil {
+				interrupted = result.interrupted
+			}
+		}()
+	}
+	wg.Wait()
+	for _, vaults := range profileVaults {
+		report.Vaults = append(report.Vaults, vaults...)
+	}
+	if interrupted != nil {
+		if reportPath != "" {
+			if err := writeBackupReport(reportPath, report); err != nil {
+				fmt.Fprintln(os.Stderr, colorWarning("WARNING:"), err)
 			}
 		}
-
-		fmt.Println()
+		return interrupted
 	}
 
 	// Print summary
-	fmt.Printf("Batch backup completed: %d successful, %d failed\n", successCount, len(errors))
+	failedSummary := fmt.Sprintf("%d failed", len(errors))
+	if len(errors) > 0 {
+		failedSummary = colorFailure(failedSummary)
+	}
+	if c.String("s3-bucket") != "" {
+		uploadSummary := fmt.Sprintf("%d upload(s) failed", len(uploadErrors))
+		if len(uploadErrors) > 0 {
+			uploadSummary = colorWarning(uploadSummary)
+		}
+		failedSummary += ", " + uploadSummary
+	}
+	fmt.Printf("Batch backup completed: %s, %s\n", colorSuccess(fmt.Sprintf("%d successful", successCount)), failedSummary)
+	if len(monitor.alerts) > 0 {
+		fmt.Printf("\n%s\n", colorWarning("Size alerts:"))
+		for _, alert := range monitor.alerts {
+			fmt.Printf("  - %s\n", alert)
+		}
+	}
+	if reportPath != "" {
+		if err := writeBackupReport(reportPath, report); err != nil {
+			return err
+		}
+		fmt.Printf("Report written to %s\n", reportPath)
+	}
+	if len(uploadErrors) > 0 {
+		fmt.Println("\nUpload errors (backups are saved locally):")
+		for _, errMsg := range uploadErrors {
+			fmt.Printf("  - %s\n", errMsg)
+		}
+	}
 	if len(errors) > 0 {
 		fmt.Println("\nErrors:")
 		for _, errMsg := range errors {
 			fmt.Printf("  - %s\n", errMsg)
 		}
+	}
+	switch {
+	case len(errors) > 0 && len(uploadErrors) > 0:
+		return fmt.Errorf("batch backup completed with %d error(s) and %d failed upload(s)", len(errors), len(uploadErrors))
+	case len(errors) > 0:
 		return fmt.Errorf("batch backup completed with %d error(s)", len(errors))
+	case len(uploadErrors) > 0:
+		return fmt.Errorf("batch backup completed with %d failed upload(s)", len(uploadErrors))
 	}
 
 	return nil
 }
 
+// batchProfileResult is the outcome of backing up one profile's vaults in batch mode
+type batchProfileResult struct {
+	successes    int
+	errors       []string
+	uploadErrors []string // Vaults backed up locally whose upload failed
+	vaults       []vaultReport
+	interrupted  error // Set when a run was cancelled; the batch stops
+}
+
+// backupBatchProfile backs up a profile's personal vault and organizations, printing each line with linePrefix
+func backupBatchProfile(c *cli.Context, profile BackupProfile, position, linePrefix, prefix string, resets credentialResets, backupPassword string, monitor *shrinkMonitor, target *s3Target) batchProfileResult {
+	var result batchProfileResult
+	infof("%s%s Processing profile: %s\n", linePrefix, position, profile.Name)
+
+	// Backup personal vault
+	start := time.Now()
+	err := backupVault(c, profile, "", prefix, resets, backupPassword, monitor, target)
+	result.vaults = append(result.vaults, newVaultReport(profile.Name, "", start, err))
+	if isInterrupted(err) {
+		result.interrupted = err
+		return result
+	} else if isUploadError(err) {
+		result.uploadErrors = append(result.uploadErrors, fmt.Sprintf("Profile '%s' personal vault: %v", profile.Name, err))
+		fmt.Printf("%s  %s Personal vault %v\n", linePrefix, colorWarning("!"), err)
+		events.Emit(eventError, "bw-backup", profile.Name, err.Error())
+	} else if err != nil {
+		result.errors = append(result.errors, fmt.Sprintf("Profile '%s' personal vault: %v", profile.Name, err))
+		fmt.Printf("%s  %s Personal vault backup failed: %v\n", linePrefix, markFail(), err)
+		events.Emit(eventError, "bw-backup", profile.Name, err.Error())
+	} else {
+		result.successes++
+		infof("%s  %s Personal vault backup completed\n", linePrefix, markOK())
+		events.Emit(eventBackupDone, "bw-backup", profile.Name, "personal vault")
+	}
+
+	// Backup each organization
+	for _, orgID := range profile.Organizations {
+		infof("%s  → Backing up organization: %s\n", linePrefix, orgID)
+		start := time.Now()
+		err := backupVault(c, profile, orgID, prefix, resets, backupPassword, monitor, target)
+		result.vaults = append(result.vaults, newVaultReport(profile.Name, orgID, start, err))
+		if isInterrupted(err) {
+			result.interrupted = err
+			return result
+		} else if isUploadError(err) {
+			result.uploadErrors = append(result.uploadErrors, fmt.Sprintf("Profile '%s' org '%s': %v", profile.Name, orgID, err))
+			fmt.Printf("%s    %s Organization %v\n", linePrefix, colorWarning("!"), err)
+			events.Emit(eventError, "bw-backup", profile.Name, fmt.Sprintf("organization %s: %v", orgID, err))
+		} else if err != nil {
+			result.errors = append(result.errors, fmt.Sprintf("Profile '%s' org '%s': %v", profile.Name, orgID, err))
+			fmt.Printf("%s    %s Organization backup failed: %v\n", linePrefix, markFail(), err)
+			events.Emit(eventError, "bw-backup", profile.Name, fmt.Sprintf("organization %s: %v", orgID, err))
+		} else {
+			result.successes++
+			infof("%s    %s Organization backup completed\n", linePrefix, markOK())
+			events.Emit(eventBackupDone, "bw-backup", profile.Name, "organization "+orgID)
+		}
+	}
+
+	if linePrefix == "" {
+		infof("\n")
+	}
+	return result
+}
+
+// finishVaultBackup runs the host-side steps after the vault backup at path, written since startTime,
+// succeeded: the size check, age re-encryption, --verify and pruning. It returns the path of the
+// finished backup, which ends in .age after re-encryption.
+func finishVaultBackup(c *cli.Context, path, profile, orgID string, format backupFormat, startTime time.Time, monitor *shrinkMonitor) (string, error) {
+	backupDir := filepath.Dir(path)
+	// Images older than BW_BACKUP_FILENAME pick their own timestamped name
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		written, _, err := newestBackupSince(backupDir, backupFilePrefix(profile, orgID), format.Extension(), startTime)
+		if err != nil {
+			return "", fmt.Errorf("backup container did not write %s (the image may be too old, pull a newer one): %w", filepath.Base(path), err)
+		}
+		logging.Verbosef("backup image ignored BW_BACKUP_FILENAME, using %s", written)
+		path = written
+	}
+	if err := monitor.check(backupDir, profile, orgID, format.Extension(), startTime); err != nil {
+		return "", err
+	}
+	if recipient := c.String("age-recipient"); recipient != "" {
+		if err := ageEncryptBackup(path, profile, orgID, recipient); err != nil {
+			return "", err
+		}
+		path += ageExtension
+	}
+	if c.Bool("verify") {
+		if err := verifyVaultBackup(path, profile, orgID, format); err != nil {
+			return "", err
+		}
+	}
+	return path, pruneVaultBackups(backupDir, profile, orgID, c.Int("keep"))
+}
+
+// pruneVaultBackups applies --keep to a vault after a successful backup, auditing each deletion
+func pruneVaultBackups(backupDir, profile, orgID string, keep int) error {
+	deleted, err := pruneBackups(backupDir, profile, orgID, keep)
+	for _, name := range deleted {
+		audit.Event(auditEvent{Event: "backup_pruned", Profile: profile, Organization: orgID, File: name, Keep: keep})
+	}
+	return err
+}
+
 // backupVault performs a single vault backup (personal or organization)
-func backupVault(_ *cli.Context, profile BackupProfile, orgID string, reset bool, backupPassword string) error {
+func backupVault(c *cli.Context, profile BackupProfile, orgID, prefix string, resets credentialResets, backupPassword string, monitor *shrinkMonitor, target *s3Target) error {
 	// Get credentials from keychain using profile name suffix
-	clientID, err := getCredential("", "bitwarden_client_id", profile.Name, reset)
+	clientID, err := getCredential("", "bitwarden_client_id", prefix, profile.Name, resets.reset("client-id"))
 	if err != nil {
 		return fmt.Errorf("failed to get client ID: %w", err)
 	}
 
-	clientSecret, err := getCredential("", "bitwarden_client_secret", profile.Name, reset)
+	clientSecret, err := getCredential("", "bitwarden_client_secret", prefix, profile.Name, resets.reset("client-secret"))
 	if err != nil {
 		return fmt.Errorf("failed to get client secret: %w", err)
 	}
 
-	password, err := getCredential("", "bitwarden_password", profile.Name, reset)
+	password, err := getCredential("", "bitwarden_password", prefix, profile.Name, resets.reset("password"))
 	if err != nil {
 		return fmt.Errorf("failed to get password: %w", err)
 	}
 
-	// Expand backup directory (handle ~/)
-	backupDir := profile.BackupDir
-	if len(backupDir) > 0 && backupDir[0] == '~' {
-		home, err := os.UserHomeDir()
+	if profile.BackupPasswordAccount != "" {
+		backupPassword, err = getCheckedBackupPassword(profile.BackupPasswordAccount, prefix, resets.reset("backup-password"))
 		if err != nil {
-			return fmt.Errorf("failed to get home directory: %w", err)
+			return fmt.Errorf("failed to get backup password: %w", err)
 		}
-		backupDir = filepath.Join(home, backupDir[1:])
+	}
+
+	format, err := resolveBackupFormat(c.String("format"), backupPassword != "")
+	if err != nil {
+		return err
+	}
+
+	// Expand backup directory (handle ~/)
+	backupDir, err := expandHome(profile.BackupDir)
+	if err != nil {
+		return err
 	}
 
 	// Resolve to absolute path
@@ -290,17 +717,20 @@ func backupVault(_ *cli.Context, profile BackupProfile, orgID string, reset bool
 		return fmt.Errorf("failed to resolve backup directory: %w", err)
 	}
 
-	// Create backup directory if it doesn't exist
-	if err := os.MkdirAll(absBackupDir, 0755); err != nil {
-		return fmt.Errorf("failed to create backup directory: %w", err)
+	// Create backup directory if it doesn't exist; a dry run leaves the filesystem alone
+	if !runtimeSettings.DryRun {
+		if err := os.MkdirAll(absBackupDir, 0755); err != nil {
+			return fmt.Errorf("failed to create backup directory: %w", err)
+		}
 	}
 
 	// Build environment variables
 	env := map[string]EnvVar{
-		"BW_CLIENTID":     {Value: clientID, Sensitive: true},
-		"BW_CLIENTSECRET": {Value: clientSecret, Sensitive: true},
-		"BW_PASSWORD":     {Value: password, Sensitive: true},
-		"BW_PROFILE":      {Value: profile.Name, Sensitive: false},
+		"BW_CLIENTID":      {Value: clientID, Sensitive: true},
+		"BW_CLIENTSECRET":  {Value: clientSecret, Sensitive: true},
+		"BW_PASSWORD":      {Value: password, Sensitive: true},
+		"BW_PROFILE":       {Value: profile.Name, Sensitive: false},
+		"BW_EXPORT_FORMAT": {Value: string(format), Sensitive: false},
 	}
 
 	// Add backup password if provided
@@ -308,53 +738,251 @@ func backupVault(_ *cli.Context, profile BackupProfile, orgID string, reset bool
 		env["BW_BACKUP_PASSWORD"] = EnvVar{Value: backupPassword, Sensitive: true}
 	}
 
+	// Per-profile server wins over the --server default
+	server := c.String("server")
+	if profile.Server != "" {
+		server = profile.Server
+	}
+	if server != "" {
+		if err := validateServerURL(server); err != nil {
+			return err
+		}
+		env["BW_SERVER"] = EnvVar{Value: server, Sensitive: false}
+	}
+
 	// Add organization ID if provided
 	if orgID != "" {
 		env["BW_ORGANIZATIONID"] = EnvVar{Value: orgID, Sensitive: false}
 	}
 
-	// Add comprehensive tmpfs mounts for security - prevents all disk writes
-	// Note: /home/node/.config is excluded as it's mounted persistently for session data
-	tmpfsMounts := map[string]string{
-		"/tmp":              "rw,noexec,nosuid,size=100m",
-		"/home/node/.cache": "rw,noexec,nosuid,size=50m",
-		"/home/node/.local": "rw,noexec,nosuid,size=50m",
+	// The host picks the file name so the finished backup can be found deterministically
+	startTime := time.Now()
+	backupPath := filepath.Join(absBackupDir, backupFilename(profile.Name, orgID, format, startTime))
+	env["BW_BACKUP_FILENAME"] = EnvVar{Value: filepath.Base(backupPath), Sensitive: false}
+	logging.Verbosef("backup file: %s", backupPath)
+
+	// Audit logging
+	finishAudit := audit.Run("backup", profile.Name, orgID, "")
+
+	volumeMounts, err := sessionConfigMounts(profile.Name)
+	if err != nil {
+		return err
 	}
 
-	var tmpfs []string
-	for path, opts := range tmpfsMounts {
-		tmpfs = append(tmpfs, fmt.Sprintf("%s:%s", path, opts))
+	// Execute backup container
+	err = runBackupWithSessionRefresh(c, absBackupDir, env, volumeMounts, "")
+	if runtimeSettings.DryRun {
+		return err
+	}
+	if err == nil {
+		backupPath, err = finishVaultBackup(c, backupPath, profile.Name, orgID, format, startTime, monitor)
 	}
 
-	// Audit logging
-	startTime := time.Now()
-	fmt.Fprintf(os.Stderr, "[AUDIT] Bitwarden backup started: profile=%s organization=%s time=%s\n",
-		profile.Name, orgID, startTime.Format(time.RFC3339))
+	finishAudit(err)
+	if err == nil && target != nil {
+		err = target.upload(backupPath, profile.Name, orgID)
+	}
+	return err
+}
 
-	// Create profile-specific config directory for persistent Bitwarden CLI sessions
+// backupContainerHome is the home directory of the backup image's node user
+const backupContainerHome = "/home/node"
+
+// sessionConfigMounts creates the profile-specific Bitwarden CLI config directory and returns its mount,
+// so sessions persist between runs (the container runs as the node user)
+func sessionConfigMounts(profile string) ([]string, error) {
 	configDir := filepath.Join(os.Getenv("HOME"), ".config", "Bitwarden CLI")
-	if profile.Name != "" {
-		configDir = filepath.Join(os.Getenv("HOME"), ".config", fmt.Sprintf("Bitwarden CLI-%s", profile.Name))
+	if profile != "" {
+		configDir = filepath.Join(os.Getenv("HOME"), ".config", fmt.Sprintf("Bitwarden CLI-%s", profile))
+	}
+	if !runtimeSettings.DryRun {
+		if err := os.MkdirAll(configDir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create config dir: %w", err)
+		}
 	}
-	if err := os.MkdirAll(configDir, 0700); err != nil {
-		return fmt.Errorf("failed to create config dir: %w", err)
+	logging.Verbosef("Bitwarden CLI session directory: %s", configDir)
+	return []string{fmt.Sprintf("%s:%s/.config/Bitwarden CLI", configDir, backupContainerHome)}, nil
+}
+
+// backupTmpfsMounts returns the comprehensive tmpfs mounts for security - prevents all disk writes.
+// Sizes come from --tmp-size, --cache-size and --local-size.
+// Note: /home/node/.config is excluded as it's mounted persistently for session data.
+// mode=1777 keeps the mounts writable when the container runs as a mapped host user.
+func backupTmpfsMounts(c *cli.Context) (map[string]string, error) {
+	sizes := map[string]string{
+		"/tmp":                          "tmp-size",
+		backupContainerHome + "/.cache": "cache-size",
+		backupContainerHome + "/.local": "local-size",
+	}
+
+	mounts := make(map[string]string, len(sizes))
+	for path, flagName := range sizes {
+		// The engine only understands plain k/m/g suffixes, so pass the parsed byte count instead of e.g. "50MiB"
+		size, err := bytesize.ParseSize(c.String(flagName))
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s: %w", flagName, err)
+		}
+		mounts[path] = "rw,noexec,nosuid,mode=1777,size=" + strconv.FormatInt(size, 10)
 	}
+	return mounts, nil
+}
 
-	// Mount config directory for persistent sessions (container runs as node user)
-	volumeMounts := []string{fmt.Sprintf("%s:/home/node/.config/Bitwarden CLI", configDir)}
+// backupExitSessionExpired is backup.sh's exit code for a failure with a cached (likely expired) session
+const backupExitSessionExpired = 3
 
-	// Execute backup container
-	image := "ghcr.io/vupham90/containers-bw-backup:latest"
-	err = RunContainer(image, absBackupDir, []string{}, env, tmpfs, volumeMounts, true)
+// isSessionExpiredError reports whether the backup container failed because its cached session was unusable
+func isSessionExpiredError(err error) bool {
+	var runErr *RunError
+	return errors.As(err, &runErr) && runErr.ExitCode() == backupExitSessionExpired
+}
 
-	// Log completion
-	if err == nil {
-		fmt.Fprintf(os.Stderr, "[AUDIT] Bitwarden backup completed: profile=%s organization=%s duration=%s\n",
-			profile.Name, orgID, time.Since(startTime))
-	} else {
-		fmt.Fprintf(os.Stderr, "[AUDIT] Bitwarden backup failed: profile=%s organization=%s duration=%s error=%v\n",
-			profile.Name, orgID, time.Since(startTime), err)
+// runBackupWithSessionRefresh runs the backup container and, if it failed on an expired session,
+// retries exactly once with BW_FORCE_LOGIN so the container discards the session and logs in again.
+// A non-empty entrypoint runs another script of the backup image (e.g. restore.sh).
+func runBackupWithSessionRefresh(c *cli.Context, backupDir string, env map[string]EnvVar, volumeMounts []string, entrypoint string) error {
+	err := runBackupContainer(c, backupDir, env, volumeMounts, entrypoint)
+	if !isSessionExpiredError(err) {
+		return err
 	}
 
-	return err
+	fmt.Fprintln(os.Stderr, colorWarning("WARNING:"), "Bitwarden session appears expired; retrying once with a fresh login")
+	audit.Event(auditEvent{Event: "session_refresh", Profile: env["BW_PROFILE"].Value, Organization: env["BW_ORGANIZATIONID"].Value})
+
+	refreshed := make(map[string]EnvVar, len(env)+1)
+	for key, envVar := range env {
+		refreshed[key] = envVar
+	}
+	refreshed["BW_FORCE_LOGIN"] = EnvVar{Value: "1", Sensitive: false}
+	return runBackupContainer(c, backupDir, refreshed, volumeMounts, entrypoint)
+}
+
+// backupUserNS resolves --userns for the backup container. auto picks keep-id on rootless Podman,
+// so the container's root maps to the invoking unprivileged user, and leaves Docker's default alone.
+func backupUserNS(mode string, engine engineInfo) (string, error) {
+	switch mode {
+	case "auto":
+		return hostUserNS(engine), nil
+	case "none":
+		return "", nil
+	case "":
+		return "", fmt.Errorf("--userns must not be empty (use 'none' to disable)")
+	}
+	if strings.ContainsAny(mode, " \t") {
+		return "", fmt.Errorf("invalid --userns mode: %q", mode)
+	}
+	if !engine.Podman && (mode == "keep-id" || strings.HasPrefix(mode, "keep-id:")) {
+		return "", fmt.Errorf("--userns %s requires Podman; Docker only supports --userns host with daemon-level userns-remap", mode)
+	}
+	return mode, nil
+}
+
+// backupImage returns the backup image, pinned to --image-digest if given
+func backupImage(c *cli.Context) (string, error) {
+	return pinnedBackupImage(c.String("image-digest"))
+}
+
+// pinnedBackupImage returns the backup image pinned to digest, or the :latest image for an empty digest
+func pinnedBackupImage(digest string) (string, error) {
+	if digest == "" {
+		return bwBackupImage, nil
+	}
+	image, err := withDigest(bwBackupImage, digest)
+	if err != nil {
+		return "", fmt.Errorf("invalid --image-digest: %w", err)
+	}
+	return image, nil
+}
+
+// runBackupContainer runs the backup image with tmpfs hardening. With --tmpfs-fallback, an engine that
+// rejects the tmpfs options (e.g. rootless Podman) is retried with bare tmpfs mounts and then without tmpfs.
+func runBackupContainer(c *cli.Context, backupDir string, env map[string]EnvVar, volumeMounts []string, entrypoint string) error {
+	tmpfsMounts, err := backupTmpfsMounts(c)
+	if err != nil {
+		return err
+	}
+
+	var hardened, minimal []string
+	for path, opts := range tmpfsMounts {
+		hardened = append(hardened, fmt.Sprintf("%s:%s", path, opts))
+		minimal = append(minimal, path)
+	}
+
+	// Extra variables from env files never override the ones the backup itself sets
+	extraEnv, err := envFromFiles(c)
+	if err != nil {
+		return err
+	}
+	for key, envVar := range env {
+		extraEnv[key] = envVar
+	}
+	env = extraEnv
+
+	opts := ContainerOptions{
+		Env:        env,
+		Tmpfs:      hardened,
+		Volumes:    volumeMounts,
+		Remove:     true,
+		Entrypoint: entrypoint,
+		NoNewPrivs: true,
+		CapDrop:    []string{"ALL"}, // bw needs no capabilities; --cap-add re-adds any the image turns out to need
+		CapAdd:     c.StringSlice("cap-add"),
+		Labels:     toolLabels(c.Command.Name),
+	}
+	// The tmpfs mounts are what the backup writes to, so a read-only root is only possible with them
+	if c.Bool("read-only") {
+		if c.Bool("no-tmpfs") || c.Bool("tmpfs-fallback") {
+			return fmt.Errorf("--read-only cannot be combined with --no-tmpfs or --tmpfs-fallback")
+		}
+		opts.ReadOnly = true
+	}
+	if c.Bool("no-tmpfs") {
+		fmt.Fprintln(os.Stderr, colorWarning("WARNING:"), "--no-tmpfs set; tmpfs hardening DISABLED - temporary files may be written to the container's disk layer")
+		opts.Tmpfs = nil
+	}
+
+	// Run as the host user (or --user) so backup files aren't owned by the image's uid. HOME is pinned
+	// because an unknown uid has no passwd entry and would otherwise get HOME=/, and the tmpfs and
+	// session mounts all live under backupContainerHome.
+	user, err := containerUserFromFlags(c)
+	if err != nil {
+		return err
+	}
+	if user != "" {
+		opts.User = user
+		opts.Env = make(map[string]EnvVar, len(env)+1)
+		for key, envVar := range env {
+			opts.Env[key] = envVar
+		}
+		opts.Env["HOME"] = EnvVar{Value: backupContainerHome, Sensitive: false}
+	}
+
+	if opts.Limits, err = resourceLimitsFromFlags(c); err != nil {
+		return err
+	}
+
+	opts.UserNS, err = backupUserNS(c.String("userns"), detectEngine())
+	if err != nil {
+		return err
+	}
+
+	image, err := backupImage(c)
+	if err != nil {
+		return err
+	}
+
+	err = RunContainer(c.Context, image, backupDir, []string{}, opts)
+	if err == nil || !c.Bool("tmpfs-fallback") || !isTmpfsError(err) {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, colorWarning("WARNING:"), "container engine rejected tmpfs options; retrying with minimal tmpfs mounts (noexec/nosuid/size limits DISABLED)")
+	opts.Tmpfs = minimal
+	err = RunContainer(c.Context, image, backupDir, []string{}, opts)
+	if err == nil || !isTmpfsError(err) {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, colorWarning("WARNING:"), "container engine rejected tmpfs mounts; retrying WITHOUT tmpfs - temporary files may be written to the container's disk layer")
+	opts.Tmpfs = nil
+	return RunContainer(c.Context, image, backupDir, []string{}, opts)
 }
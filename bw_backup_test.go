@@ -0,0 +1,395 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+func TestResolveBackupFormat(t *testing.T) {
+	tests := []struct {
+		format    string
+		encrypted bool
+		want      backupFormat
+		wantExt   string
+		wantErr   bool
+	}{
+		{"", false, formatJSON, "json", false},
+		{"", true, formatEncryptedJSON, "encrypted.json", false},
+		{"csv", false, formatCSV, "csv", false},
+		{"json", false, formatJSON, "json", false},
+		{"encrypted_json", true, formatEncryptedJSON, "encrypted.json", false},
+		{"encrypted_json", false, "", "", true},
+		{"csv", true, "", "", true},
+		{"xml", false, "", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := resolveBackupFormat(tt.format, tt.encrypted)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("resolveBackupFormat(%q, %v) error = %v, wantErr %v", tt.format, tt.encrypted, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if got != tt.want || got.Extension() != tt.wantExt {
+			t.Errorf("resolveBackupFormat(%q, %v) = %q (%s), want %q (%s)", tt.format, tt.encrypted, got, got.Extension(), tt.want, tt.wantExt)
+		}
+	}
+}
+
+func TestValidateBackupProfile(t *testing.T) {
+	seen := make(map[string]bool)
+	if err := validateBackupProfile(BackupProfile{Name: "personal", BackupDir: "~/b"}, seen); err != nil {
+		t.Fatalf("valid profile: unexpected error %v", err)
+	}
+
+	invalid := []BackupProfile{
+		{Name: "", BackupDir: "~/b"},
+		{Name: "personal", BackupDir: "~/b"}, // duplicate
+		{Name: "work", BackupDir: ""},
+		{Name: "org", BackupDir: "~/b", Organizations: []string{""}},
+	}
+	for _, profile := range invalid {
+		if err := validateBackupProfile(profile, seen); err == nil {
+			t.Errorf("validateBackupProfile(%+v) expected error", profile)
+		}
+	}
+}
+
+func TestCheckDirWritable(t *testing.T) {
+	dir := t.TempDir()
+	if err := checkDirWritable(dir); err != nil {
+		t.Errorf("existing dir: unexpected error %v", err)
+	}
+	if err := checkDirWritable(filepath.Join(dir, "not", "yet", "created")); err != nil {
+		t.Errorf("missing dir under writable parent: unexpected error %v", err)
+	}
+
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkDirWritable(file); err == nil {
+		t.Error("regular file: expected error")
+	}
+}
+
+func TestBackupUserNS(t *testing.T) {
+	docker := engineInfo{}
+	rootlessPodman := engineInfo{Podman: true, Rootless: true}
+
+	tests := []struct {
+		mode    string
+		engine  engineInfo
+		want    string
+		wantErr bool
+	}{
+		{"auto", docker, "", false},
+		{"auto", rootlessPodman, "keep-id", false},
+		{"auto", engineInfo{Podman: true}, "", false},
+		{"none", rootlessPodman, "", false},
+		{"host", docker, "host", false},
+		{"keep-id", rootlessPodman, "keep-id", false},
+		{"keep-id", docker, "", true},
+		{"", docker, "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := backupUserNS(tt.mode, tt.engine)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("backupUserNS(%q, %+v) error = %v, wantErr %v", tt.mode, tt.engine, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("backupUserNS(%q, %+v) = %q, want %q", tt.mode, tt.engine, got, tt.want)
+		}
+	}
+}
+
+func TestValidateServerURL(t *testing.T) {
+	valid := []string{"https://vault.example.com", "https://example.com/bitwarden", "http://localhost:8080"}
+	for _, raw := range valid {
+		if err := validateServerURL(raw); err != nil {
+			t.Errorf("validateServerURL(%q) unexpected error: %v", raw, err)
+		}
+	}
+
+	invalid := []string{"vault.example.com", "ftp://vault.example.com", "https://", "https://v.example.com/?x=1", "://bad"}
+	for _, raw := range invalid {
+		if err := validateServerURL(raw); err == nil {
+			t.Errorf("validateServerURL(%q) expected error", raw)
+		}
+	}
+}
+
+func TestIsSessionExpiredError(t *testing.T) {
+	sessionFailure := exec.Command("sh", "-c", "exit 3").Run()
+	authFailure := exec.Command("sh", "-c", "exit 1").Run()
+
+	if !isSessionExpiredError(&RunError{Err: sessionFailure}) {
+		t.Error("exit code 3 should be a session failure")
+	}
+	if isSessionExpiredError(&RunError{Err: authFailure}) {
+		t.Error("exit code 1 should not be a session failure")
+	}
+	if isSessionExpiredError(nil) {
+		t.Error("nil should not be a session failure")
+	}
+}
+
+func TestBackupPasswordWarning(t *testing.T) {
+	tests := []struct {
+		password string
+		warns    bool
+	}{
+		{password: "short1!", warns: true},
+		{password: "alllowercaseletters", warns: true},
+		{password: "123456789012345", warns: true},
+		{password: "correct horse battery", warns: false},
+		{password: "Tr0ub4dor&3xyz", warns: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			if warning := backupPasswordWarning(tt.password); (warning != "") != tt.warns {
+				t.Errorf("backupPasswordWarning(%q) = %q, expected warning: %v", tt.password, warning, tt.warns)
+			}
+		})
+	}
+}
+
+func TestLoadBackupConfigBackupPasswordAccount(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `profiles:
+  - name: personal
+    backup_dir: ~/backups/personal
+  - name: finance
+    backup_dir: ~/backups/finance
+    backup_password_account: finance_backup_password
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := loadBackupConfig(path)
+	if err != nil {
+		t.Fatalf("loadBackupConfig() error = %v", err)
+	}
+	if got := config.Profiles[0].BackupPasswordAccount; got != "" {
+		t.Errorf("personal BackupPasswordAccount = %q, want empty", got)
+	}
+	if got := config.Profiles[1].BackupPasswordAccount; got != "finance_backup_password" {
+		t.Errorf("finance BackupPasswordAccount = %q, want finance_backup_password", got)
+	}
+}
+
+func TestLoadBackupConfigStrict(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{name: "valid", data: "profiles:\n  - name: personal\n    backup_dir: ~/b\n"},
+		{name: "misspelled key", data: "profiles:\n  - name: personal\n    backupdir: ~/b\n", wantErr: "line 3: field backupdir not found"},
+		{name: "empty file", data: "", wantErr: "no profiles found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := loadBackupConfig(path)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("loadBackupConfig() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("loadBackupConfig() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateBackupConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		profiles []BackupProfile
+		wantErr  string
+	}{
+		{name: "valid", profiles: []BackupProfile{{Name: "personal", BackupDir: "~/b"}, {Name: "work", BackupDir: "~/w"}}},
+		{name: "missing name", profiles: []BackupProfile{{Name: "personal", BackupDir: "~/b"}, {BackupDir: "~/w"}}, wantErr: "invalid profile 2 in config: name is required"},
+		{name: "missing backup dir", profiles: []BackupProfile{{Name: "work"}}, wantErr: "invalid profile 1 ('work') in config: backup_dir is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBackupConfig(BackupConfig{Profiles: tt.profiles})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateBackupConfig() error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("validateBackupConfig() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBackupTmpfsMounts(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			want: map[string]string{"/tmp": "size=104857600", backupContainerHome + "/.cache": "size=52428800"},
+		},
+		{
+			name: "mixed case and IEC units",
+			args: []string{"--tmp-size", "1.5g", "--cache-size", "50mb", "--local-size", "4KiB"},
+			want: map[string]string{
+				"/tmp":                          "size=1610612736",
+				backupContainerHome + "/.cache": "size=52428800",
+				backupContainerHome + "/.local": "size=4096",
+			},
+		},
+		{name: "invalid", args: []string{"--tmp-size", "lots"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mounts map[string]string
+			var mountErr error
+			app := &cli.App{
+				Flags: bitwardenContainerFlags(),
+				Action: func(c *cli.Context) error {
+					mounts, mountErr = backupTmpfsMounts(c)
+					return nil
+				},
+			}
+			if err := app.Run(append([]string{"bw-backup"}, tt.args...)); err != nil {
+				t.Fatal(err)
+			}
+			if (mountErr != nil) != tt.wantErr {
+				t.Fatalf("backupTmpfsMounts() error = %v, wantErr %v", mountErr, tt.wantErr)
+			}
+			for path, size := range tt.want {
+				if !strings.HasSuffix(mounts[path], ","+size) {
+					t.Errorf("%s options = %q, want %s", path, mounts[path], size)
+				}
+			}
+		})
+	}
+}
+
+// fakeOldBackupImage ignores BW_BACKUP_FILENAME and writes its own timestamped name into /workspace,
+// like backup images built before the CLI picked the file name; FAKE_WRITE=0 writes nothing
+const fakeOldBackupImage = `#!/bin/sh
+[ "$1" = "run" ] || exit 0
+while [ $# -gt 0 ]; do
+	case "$2" in *:/workspace) workspace="${2%:/workspace}" ;; esac
+	shift
+done
+[ "$FAKE_WRITE" = 0 ] || echo '{"items":[]}' > "$workspace/bitwarden-backup-$(date -u +%Y-%m-%d-%H%M%S)-old.json"
+`
+
+func TestFinishVaultBackupOldImage(t *testing.T) {
+	stubEngineProbe(t, "")
+	fakeDocker(t, fakeOldBackupImage)
+
+	tests := []struct {
+		name    string
+		write   string
+		wantErr string
+	}{
+		{name: "falls back to the newest backup", write: "1"},
+		{name: "nothing written", write: "0", wantErr: "pull a newer one"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("FAKE_WRITE", tt.write)
+			dir := t.TempDir()
+			startTime := time.Now()
+			predicted := filepath.Join(dir, backupFilename("", "", formatJSON, startTime))
+			env := map[string]EnvVar{"BW_BACKUP_FILENAME": {Value: filepath.Base(predicted)}}
+
+			var finished string
+			app := &cli.App{
+				Flags: append(bitwardenContainerFlags(),
+					&cli.StringFlag{Name: "age-recipient"},
+					&cli.BoolFlag{Name: "verify"},
+					&cli.IntFlag{Name: "keep"},
+				),
+				Action: func(c *cli.Context) error {
+					if err := runBackupContainer(c, dir, env, nil, ""); err != nil {
+						return err
+					}
+					var err error
+					finished, err = finishVaultBackup(c, predicted, "", "", formatJSON, startTime, nil)
+					return err
+				},
+			}
+			err := app.Run([]string{"bw-backup"})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.HasSuffix(finished, "-old.json") {
+				t.Errorf("finished backup = %s, want the file the image wrote", finished)
+			}
+		})
+	}
+}
+
+func TestBatchBackupDryRunTouchesNothing(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	backupDir := filepath.Join(home, "backups", "finance")
+	profiles := filepath.Join(home, "profiles.yaml")
+	data := "profiles:\n  - name: finance\n    backup_dir: " + backupDir + "\n    backup_password_account: finance_backup_password\n"
+	if err := os.WriteFile(profiles, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runAppCommand(t, "bw-backup", []string{"--dry-run", "bw-backup", "--profiles", profiles}, nil); err != nil {
+		t.Fatalf("dry run error = %v", err)
+	}
+	for _, dir := range []string{backupDir, filepath.Join(home, ".config", "Bitwarden CLI-finance")} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("dry run created %s (stat error %v)", dir, err)
+		}
+	}
+}
+
+func TestGetCredentialDryRun(t *testing.T) {
+	runtimeSettings.DryRun = true
+	defer func() { runtimeSettings.DryRun = false }()
+
+	if got, err := getCredential("", "bitwarden_password", "", "work", true); err != nil || got != dryRunCredential {
+		t.Errorf("getCredential() = %q, %v; want the placeholder without touching the keychain", got, err)
+	}
+	if got, _ := getCredential("from-flag", "bitwarden_password", "", "work", false); got != "from-flag" {
+		t.Errorf("getCredential() with a flag value = %q, want from-flag", got)
+	}
+}
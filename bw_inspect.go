@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"github.com/vupham90/containers/keychain"
+	"golang.org/x/crypto/argon2"
+)
+
+// Bitwarden KDF types used by password-protected exports
+const (
+	bitwardenKdfPBKDF2   = 0
+	bitwardenKdfArgon2id = 1
+)
+
+// bitwardenExport is the subset of a Bitwarden JSON export needed to report organizations.
+// Password-protected exports carry the KDF parameters and the encrypted plain export in Data.
+type bitwardenExport struct {
+	Encrypted         bool   `json:"encrypted"`
+	PasswordProtected bool   `json:"passwordProtected"`
+	Salt              string `json:"salt"`
+	KdfType           int    `json:"kdfType"`
+	KdfIterations     int    `json:"kdfIterations"`
+	KdfMemory         int    `json:"kdfMemory"`      // MiB, Argon2id only
+	KdfParallelism    int    `json:"kdfParallelism"` // Argon2id only
+	EncKeyValidation  string `json:"encKeyValidation_DO_NOT_EDIT"`
+	Data              string `json:"data"`
+
+	Collections []struct {
+		ID             string `json:"id"`
+		OrganizationID string `json:"organizationId"`
+		Name           string `json:"name"`
+	} `json:"collections"`
+	Items []struct {
+		OrganizationID string `json:"organizationId"`
+	} `json:"items"`
+}
+
+// backupOrganization summarizes one organization found in an export
+type backupOrganization struct {
+	ID          string
+	Items       int
+	Collections []string
+}
+
+// runListBackupOrgs prints the organizations contained in a Bitwarden backup file
+func runListBackupOrgs(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("expected 1 argument: backup-file")
+	}
+	path := c.Args().Get(0)
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return fmt.Errorf("CSV exports do not contain organization data; inspect a JSON export instead")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read backup file: %w", err)
+	}
+
+	export, err := parseBitwardenExport(data)
+	if err != nil {
+		return err
+	}
+	if export.Encrypted {
+		if !export.PasswordProtected {
+			return fmt.Errorf("backup is encrypted with the account key and cannot be inspected offline")
+		}
+		password, err := keychain.PromptPassword("Enter backup password: ")
+		if err != nil {
+			return err
+		}
+		if export, err = decryptBitwardenExport(export, password); err != nil {
+			return err
+		}
+	}
+
+	orgs, personal := summarizeBackupOrgs(export)
+	fmt.Printf("Organizations in %s:\n", filepath.Base(path))
+	if len(orgs) == 0 {
+		fmt.Println("  (none)")
+	}
+	found := make(map[string]bool, len(orgs))
+	for _, org := range orgs {
+		found[org.ID] = true
+		fmt.Printf("  %s: %d item(s), %d collection(s)", org.ID, org.Items, len(org.Collections))
+		if len(org.Collections) > 0 {
+			fmt.Printf(" [%s]", strings.Join(org.Collections, ", "))
+		}
+		fmt.Println()
+	}
+	fmt.Printf("Personal items: %d\n", personal)
+
+	// Compare with the organizations the config expects to be backed up
+	var missing []string
+	for _, id := range c.StringSlice("expect") {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("expected organization(s) not in backup: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// parseBitwardenExport decodes a Bitwarden JSON export
+func parseBitwardenExport(data []byte) (bitwardenExport, error) {
+	var export bitwardenExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return bitwardenExport{}, fmt.Errorf("not a Bitwarden JSON export: %w", err)
+	}
+	return export, nil
+}
+
+// summarizeBackupOrgs groups items and collections by organization, sorted by ID,
+// and counts items that belong to no organization
+func summarizeBackupOrgs(export bitwardenExport) ([]backupOrganization, int) {
+	byID := make(map[string]*backupOrganization)
+	org := func(id string) *backupOrganization {
+		if byID[id] == nil {
+			byID[id] = &backupOrganization{ID: id}
+		}
+		return byID[id]
+	}
+
+	personal := 0
+	for _, item := range export.Items {
+		if item.OrganizationID == "" {
+			personal++
+			continue
+		}
+		org(item.OrganizationID).Items++
+	}
+	for _, collection := range export.Collections {
+		if collection.OrganizationID != "" {
+			o := org(collection.OrganizationID)
+			o.Collections = append(o.Collections, collection.Name)
+		}
+	}
+
+	orgs := make([]backupOrganization, 0, len(byID))
+	for _, o := range byID {
+		sort.Strings(o.Collections)
+		orgs = append(orgs, *o)
+	}
+	sort.Slice(orgs, func(i, j int) bool { return orgs[i].ID < orgs[j].ID })
+	return orgs, personal
+}
+
+// decryptBitwardenExport derives the export key from the password and decrypts the embedded plain export
+func decryptBitwardenExport(export bitwardenExport, password string) (bitwardenExport, error) {
+	encKey, macKey, err := bitwardenExportKeys(export, password)
+	if err != nil {
+		return bitwardenExport{}, err
+	}
+
+	// The validation string only decrypts (MAC verifies) with the right password
+	if _, err := decryptEncString(export.EncKeyValidation, encKey, macKey); err != nil {
+		return bitwardenExport{}, fmt.Errorf("failed to decrypt backup (wrong password?)")
+	}
+
+	plaintext, err := decryptEncString(export.Data, encKey, macKey)
+	if err != nil {
+		return bitwardenExport{}, fmt.Errorf("failed to decrypt backup data: %w", err)
+	}
+	return parseBitwardenExport(plaintext)
+}
+
+// bitwardenExportKeys derives the master key with the export's KDF and stretches it into enc and mac keys
+func bitwardenExportKeys(export bitwardenExport, password string) ([]byte, []byte, error) {
+	var key []byte
+	switch export.KdfType {
+	case bitwardenKdfPBKDF2:
+		var err error
+		key, err = pbkdf2.Key(sha256.New, password, []byte(export.Salt), export.KdfIterations, 32)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to derive key: %w", err)
+		}
+	case bitwardenKdfArgon2id:
+		if export.KdfIterations < 1 || export.KdfMemory < 1 || export.KdfParallelism < 1 {
+			return nil, nil, fmt.Errorf("invalid Argon2id parameters in backup")
+		}
+		salt := sha256.Sum256([]byte(export.Salt))
+		key = argon2.IDKey([]byte(password), salt[:], uint32(export.KdfIterations),
+			uint32(export.KdfMemory)*1024, uint8(export.KdfParallelism), 32)
+	default:
+		return nil, nil, fmt.Errorf("unsupported KDF type %d in backup", export.KdfType)
+	}
+
+	encKey, err := hkdf.Expand(sha256.New, key, "enc", 32)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	macKey, err := hkdf.Expand(sha256.New, key, "mac", 32)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	return encKey, macKey, nil
+}
+
+// decryptEncString decrypts a Bitwarden type 2 EncString ("2.iv|ciphertext|mac", AES-256-CBC + HMAC-SHA256)
+func decryptEncString(encString string, encKey, macKey []byte) ([]byte, error) {
+	encType, rest, ok := strings.Cut(encString, ".")
+	if !ok || encType != "2" {
+		return nil, fmt.Errorf("unsupported encryption type")
+	}
+	parts := strings.Split(rest, "|")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed encrypted value")
+	}
+	var decoded [3][]byte
+	for i, part := range parts {
+		b, err := base64.StdEncoding.DecodeString(part)
+		if err != nil {
+			return nil, fmt.Errorf("malformed encrypted value: %w", err)
+		}
+		decoded[i] = b
+	}
+	iv, ciphertext, mac := decoded[0], decoded[1], decoded[2]
+
+	h := hmac.New(sha256.New, macKey)
+	h.Write(iv)
+	h.Write(ciphertext)
+	if !hmac.Equal(h.Sum(nil), mac) {
+		return nil, fmt.Errorf("MAC verification failed")
+	}
+
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	if len(iv) != aes.BlockSize || len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("malformed encrypted value")
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+
+	// Strip PKCS#7 padding
+	pad := int(plaintext[len(plaintext)-1])
+	if pad < 1 || pad > aes.BlockSize || !bytes.Equal(plaintext[len(plaintext)-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		return nil, fmt.Errorf("invalid padding")
+	}
+	return plaintext[:len(plaintext)-pad], nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/urfave/cli/v2"
+	"github.com/vupham90/containers/keychain"
+)
+
+// profileCredentialAccounts lists the per-profile keychain base accounts batch mode reads
+var profileCredentialAccounts = []string{"bitwarden_client_id", "bitwarden_client_secret", "bitwarden_password"}
+
+// preflightReport prints check results and counts failures
+type preflightReport struct {
+	failures int
+}
+
+// check prints one ✓/✗ line for a named check
+func (r *preflightReport) check(indent, name string, err error) bool {
+	if err != nil {
+		r.failures++
+		fmt.Printf("%s%s %s: %v\n", indent, markFail(), name, err)
+		return false
+	}
+	fmt.Printf("%s%s %s\n", indent, markOK(), name)
+	return true
+}
+
+// runBackupPreflight validates a batch config and everything a batch run depends on without running containers
+func runBackupPreflight(c *cli.Context, configPath string) error {
+	fmt.Printf("Preflight for %s\n", configPath)
+	var report preflightReport
+
+	config, err := loadBackupConfig(configPath)
+	if !report.check("  ", "config parses", err) {
+		return fmt.Errorf("preflight failed: config is not usable")
+	}
+
+	prefix := config.KeychainAccountPrefix
+	if c.IsSet("keychain-account-prefix") {
+		prefix = c.String("keychain-account-prefix")
+	}
+
+	image, err := backupImage(c)
+	if err != nil {
+		return err
+	}
+	report.check("  ", "image available: "+image, checkBackupImage(image))
+
+	encrypted := c.IsSet("backup-password") || c.Bool("encrypt")
+	_, err = resolveBackupFormat(c.String("format"), encrypted)
+	report.check("  ", "export format", err)
+	if c.Bool("encrypt") && !c.IsSet("backup-password") {
+		report.check("  ", "keychain entry: backup password", checkKeychainEntry(keychainAccountName(prefix, "bitwarden_backup_password", "")))
+	}
+
+	ready := 0
+	seen := make(map[string]bool)
+	for _, profile := range config.Profiles {
+		fmt.Printf("\nProfile '%s':\n", profile.Name)
+		before := report.failures
+
+		report.check("    ", "profile definition", validateBackupProfile(profile, seen))
+		for _, base := range profileCredentialAccounts {
+			account := keychainAccountName(prefix, base, profile.Name)
+			report.check("    ", "keychain entry: "+account, checkKeychainEntry(account))
+		}
+		if profile.BackupPasswordAccount != "" {
+			account := keychainAccountName(prefix, profile.BackupPasswordAccount, "")
+			report.check("    ", "keychain entry: "+account, checkKeychainEntry(account))
+		}
+		if profile.BackupDir != "" {
+			report.check("    ", "backup dir writable: "+profile.BackupDir, checkDirWritable(profile.BackupDir))
+		}
+
+		if report.failures == before {
+			ready++
+		}
+	}
+
+	fmt.Printf("\n%d of %d profile(s) ready\n", ready, len(config.Profiles))
+	if report.failures > 0 {
+		return fmt.Errorf("preflight failed with %d problem(s)", report.failures)
+	}
+	fmt.Println(colorSuccess("Preflight passed"))
+	return nil
+}
+
+// validateBackupProfile checks the required fields of a profile and that its name is unique
+func validateBackupProfile(profile BackupProfile, seen map[string]bool) error {
+	switch {
+	case profile.Name == "":
+		return fmt.Errorf("name is required")
+	case seen[profile.Name]:
+		return fmt.Errorf("duplicate profile name")
+	case profile.BackupDir == "":
+		return fmt.Errorf("backup_dir is required")
+	}
+	if profile.Server != "" {
+		if err := validateServerURL(profile.Server); err != nil {
+			return err
+		}
+	}
+	seen[profile.Name] = true
+	for _, orgID := range profile.Organizations {
+		if orgID == "" {
+			return fmt.Errorf("organization ID must not be empty")
+		}
+	}
+	return nil
+}
+
+// checkKeychainEntry fails if the account has no stored credential (batch mode would prompt for it)
+func checkKeychainEntry(account string) error {
+	if !keychain.HasPassword(bwKeychainService, account) {
+		return fmt.Errorf("not found in keychain (run a single backup with --profile to store it)")
+	}
+	return nil
+}
+
+// checkBackupImage verifies the backup image is allowed and present locally
+func checkBackupImage(image string) error {
+	if err := checkImageAllowed(image, runtimeSettings.AllowedRegistries); err != nil {
+		return err
+	}
+	if err := dockerCommand("image", "inspect", image).Run(); err != nil {
+		return fmt.Errorf("not present locally (run 'containers images pull'): %w", err)
+	}
+	return nil
+}
+
+// checkDirWritable verifies dir, or the nearest existing ancestor it would be created under, accepts new files
+func checkDirWritable(dir string) error {
+	expanded, err := expandHome(dir)
+	if err != nil {
+		return err
+	}
+	candidate, err := filepath.Abs(expanded)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	for {
+		info, err := os.Stat(candidate)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", candidate)
+			}
+			break
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		parent := filepath.Dir(candidate)
+		if parent == candidate {
+			return fmt.Errorf("no existing parent directory")
+		}
+		candidate = parent
+	}
+
+	probe, err := os.CreateTemp(candidate, ".containers-preflight-")
+	if err != nil {
+		return fmt.Errorf("cannot write to %s: %w", candidate, err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// restoreEntrypoint is the bw-backup image script that imports a backup instead of exporting one
+const restoreEntrypoint = "/app/restore.sh"
+
+// restoreSource describes a backup file to import
+type restoreSource struct {
+	Format            string // bw import format: bitwardenjson or bitwardencsv
+	PasswordProtected bool   // Needs the backup password to decrypt
+}
+
+// inspectRestoreFile confirms the backup file is readable and works out how bw import should read it
+func inspectRestoreFile(path string) (restoreSource, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return restoreSource{}, fmt.Errorf("failed to access backup file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return restoreSource{}, fmt.Errorf("backup file is not a regular file: %s", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return restoreSource{}, fmt.Errorf("failed to read backup file: %w", err)
+	}
+	if len(data) == 0 {
+		return restoreSource{}, fmt.Errorf("backup file is empty: %s", path)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return restoreSource{Format: "bitwardencsv"}, nil
+	}
+	export, err := parseBitwardenExport(data)
+	if err != nil {
+		return restoreSource{}, err
+	}
+	return restoreSource{Format: "bitwardenjson", PasswordProtected: export.PasswordProtected}, nil
+}
+
+// runBwRestore imports a backup file into a Bitwarden vault using the bw-backup image's restore script
+func runBwRestore(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("expected 1 argument: backup-file")
+	}
+	if err := setAuditFormat(c.String("audit-format")); err != nil {
+		return err
+	}
+
+	// Reject bad limits and digests before prompting for credentials
+	if _, err := resourceLimitsFromFlags(c); err != nil {
+		return err
+	}
+	if _, err := backupImage(c); err != nil {
+		return err
+	}
+	resets, err := newCredentialResets(c)
+	if err != nil {
+		return err
+	}
+	profile := c.String("profile")
+	orgID := c.String("organization-id")
+	prefix := c.String("keychain-account-prefix")
+
+	backupFile, err := expandHome(c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	absBackupFile, err := filepath.Abs(backupFile)
+	if err != nil {
+		return fmt.Errorf("failed to resolve backup file: %w", err)
+	}
+	source, err := inspectRestoreFile(absBackupFile)
+	if err != nil {
+		return err
+	}
+
+	// Password-protected exports need the password they were written with
+	var backupPassword string
+	if source.PasswordProtected {
+		backupPassword, err = getBackupPassword(c, prefix, resets.reset("backup-password"))
+		if err != nil {
+			return err
+		}
+		if backupPassword == "" {
+			return fmt.Errorf("backup is password protected; use --encrypt (keychain) or --backup-password")
+		}
+	}
+
+	// Get credentials (flags or keychain with reset option and profile support)
+	clientID, err := getCredential(c.String("client-id"), "bitwarden_client_id", prefix, profile, resets.reset("client-id"))
+	if err != nil {
+		return err
+	}
+	clientSecret, err := getCredential(c.String("client-secret"), "bitwarden_client_secret", prefix, profile, resets.reset("client-secret"))
+	if err != nil {
+		return err
+	}
+	password, err := getCredential(c.String("password"), "bitwarden_password", prefix, profile, resets.reset("password"))
+	if err != nil {
+		return err
+	}
+
+	// The backup's directory is mounted to /workspace; the script finds the file by name
+	env := map[string]EnvVar{
+		"BW_CLIENTID":       {Value: clientID, Sensitive: true},
+		"BW_CLIENTSECRET":   {Value: clientSecret, Sensitive: true},
+		"BW_PASSWORD":       {Value: password, Sensitive: true},
+		"BW_RESTORE_FILE":   {Value: filepath.Base(absBackupFile), Sensitive: false},
+		"BW_RESTORE_FORMAT": {Value: source.Format, Sensitive: false},
+	}
+	if backupPassword != "" {
+		env["BW_BACKUP_PASSWORD"] = EnvVar{Value: backupPassword, Sensitive: true}
+	}
+	if server := c.String("server"); server != "" {
+		if err := validateServerURL(server); err != nil {
+			return err
+		}
+		env["BW_SERVER"] = EnvVar{Value: server, Sensitive: false}
+	}
+	if profile != "" {
+		env["BW_PROFILE"] = EnvVar{Value: profile, Sensitive: false}
+	}
+	if orgID != "" {
+		env["BW_ORGANIZATIONID"] = EnvVar{Value: orgID, Sensitive: false}
+	}
+
+	volumeMounts, err := sessionConfigMounts(profile)
+	if err != nil {
+		return err
+	}
+
+	finishAudit := audit.Run("restore", profile, orgID, absBackupFile)
+
+	infof("Restoring %s...\n", filepath.Base(absBackupFile))
+	err = runBackupWithSessionRefresh(c, filepath.Dir(absBackupFile), env, volumeMounts, restoreEntrypoint)
+	if runtimeSettings.DryRun {
+		return err
+	}
+	finishAudit(err)
+	return err
+}
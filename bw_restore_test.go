@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInspectRestoreFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    restoreSource
+		wantErr bool
+	}{
+		{
+			name: "plain json",
+			path: write("plain.json", plainExportFixture),
+			want: restoreSource{Format: "bitwardenjson"},
+		},
+		{
+			name: "password protected json",
+			path: write("protected.encrypted.json", `{"encrypted": true, "passwordProtected": true, "data": "2.x"}`),
+			want: restoreSource{Format: "bitwardenjson", PasswordProtected: true},
+		},
+		{
+			name: "account key encrypted json",
+			path: write("account.json", `{"encrypted": true, "items": []}`),
+			want: restoreSource{Format: "bitwardenjson"},
+		},
+		{
+			name: "csv",
+			path: write("vault.csv", "folder,favorite,type,name\n"),
+			want: restoreSource{Format: "bitwardencsv"},
+		},
+		{name: "not json", path: write("broken.json", "not json"), wantErr: true},
+		{name: "empty", path: write("empty.json", ""), wantErr: true},
+		{name: "missing", path: filepath.Join(dir, "missing.json"), wantErr: true},
+		{name: "directory", path: dir, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := inspectRestoreFile(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("inspectRestoreFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("inspectRestoreFile() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
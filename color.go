@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// ANSI escape sequences used for status colors
+const (
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiReset  = "\033[0m"
+)
+
+// colorOutput is set from the global --color flag before any command runs
+var colorOutput = false
+
+// resolveColor decides whether to colorize output for a --color mode.
+// auto colors only on a terminal and honours NO_COLOR (https://no-color.org).
+func resolveColor(mode string, isTerminal, noColor bool) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		return isTerminal && !noColor, nil
+	default:
+		return false, fmt.Errorf("invalid color mode: %s (must be 'auto', 'always' or 'never')", mode)
+	}
+}
+
+// outputIsTerminal reports whether both stdout and stderr are attached to a terminal. Status marks and
+// warnings go to either stream, so color is only safe when neither is redirected to a file or pipe.
+func outputIsTerminal() bool {
+	return term.IsTerminal(int(os.Stdout.Fd())) && term.IsTerminal(int(os.Stderr.Fd()))
+}
+
+// colorize wraps s in the given ANSI color when color output is enabled
+func colorize(color, s string) string {
+	if !colorOutput {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+// colorSuccess colors s green
+func colorSuccess(s string) string { return colorize(ansiGreen, s) }
+
+// colorFailure colors s red
+func colorFailure(s string) string { return colorize(ansiRed, s) }
+
+// colorWarning colors s yellow
+func colorWarning(s string) string { return colorize(ansiYellow, s) }
+
+// markOK returns the success check mark
+func markOK() string { return colorSuccess("✓") }
+
+// markFail returns the failure cross
+func markFail() string { return colorFailure("✗") }
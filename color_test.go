@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestResolveColor(t *testing.T) {
+	tests := []struct {
+		mode       string
+		isTerminal bool
+		noColor    bool
+		want       bool
+		wantErr    bool
+	}{
+		{"auto", true, false, true, false},
+		{"auto", false, false, false, false},
+		{"auto", true, true, false, false},
+		{"always", false, true, true, false},
+		{"never", true, false, false, false},
+		{"rainbow", true, false, false, true},
+	}
+
+	for _, tt := range tests {
+		got, err := resolveColor(tt.mode, tt.isTerminal, tt.noColor)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("resolveColor(%q, %v, %v) error = %v, wantErr %v", tt.mode, tt.isTerminal, tt.noColor, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("resolveColor(%q, %v, %v) = %v, want %v", tt.mode, tt.isTerminal, tt.noColor, got, tt.want)
+		}
+	}
+}
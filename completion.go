@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// completionFlag is the hidden flag urfave/cli answers with completion candidates
+const completionFlag = "--generate-bash-completion"
+
+// bashCompletionScript asks the binary for candidates and falls back to file names when it has none
+const bashCompletionScript = `# bash completion for containers
+_containers_completion() {
+  local cur words
+  COMPREPLY=()
+  cur="${COMP_WORDS[COMP_CWORD]}"
+  words=("${COMP_WORDS[@]:0:$COMP_CWORD}")
+  local opts
+  if [[ "$cur" == -* ]]; then
+    opts=$("${words[@]}" "$cur" ` + completionFlag + ` 2>/dev/null)
+  else
+    opts=$("${words[@]}" ` + completionFlag + ` 2>/dev/null)
+  fi
+  COMPREPLY=($(compgen -W "${opts}" -- "${cur}"))
+}
+complete -o bashdefault -o default -F _containers_completion containers
+`
+
+// zshCompletionScript is the zsh equivalent of bashCompletionScript
+const zshCompletionScript = `#compdef containers
+
+_containers() {
+  local -a opts
+  local cur=${words[-1]}
+  if [[ "$cur" == -* ]]; then
+    opts=("${(@f)$(${words[@]:0:#words[@]-1} ${cur} ` + completionFlag + ` 2>/dev/null)}")
+  else
+    opts=("${(@f)$(${words[@]:0:#words[@]-1} ` + completionFlag + ` 2>/dev/null)}")
+  fi
+  if [[ "${opts[1]}" != "" ]]; then
+    _describe 'values' opts
+  else
+    _files
+  fi
+}
+
+compdef _containers containers
+`
+
+// runCompletion prints the completion script for the named shell
+func runCompletion(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("expected 1 argument: bash, zsh or fish")
+	}
+	switch shell := c.Args().First(); shell {
+	case "bash":
+		fmt.Fprint(c.App.Writer, bashCompletionScript)
+	case "zsh":
+		fmt.Fprint(c.App.Writer, zshCompletionScript)
+	case "fish":
+		script, err := c.App.ToFishCompletion()
+		if err != nil {
+			return fmt.Errorf("failed to generate fish completion: %w", err)
+		}
+		fmt.Fprint(c.App.Writer, script)
+	default:
+		return fmt.Errorf("unsupported shell: %s (must be bash, zsh or fish)", shell)
+	}
+	return nil
+}
+
+// flagValueCompletions lists the values offered after a flag, by command name and alias
+var flagValueCompletions = map[string]map[string][]string{
+	"pdf-compress": {"--quality": sortedKeys(validQualities), "-Q": sortedKeys(validQualities)},
+	"pdfc":         {"--quality": sortedKeys(validQualities), "-Q": sortedKeys(validQualities)},
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// completeFlags answers a completion request whose previous word is a flag: the flag's known values if it
+// has any, otherwise the flags of the command being completed. urfave/cli cannot do this itself: a partial
+// or value-less flag fails to parse and its completion panics.
+func completeFlags(args []string, app *cli.App) ([]string, bool) {
+	if len(args) < 3 || args[len(args)-1] != completionFlag {
+		return nil, false
+	}
+	flag := args[len(args)-2]
+	if !strings.HasPrefix(flag, "-") {
+		return nil, false
+	}
+	words := args[1 : len(args)-2]
+	for _, word := range words {
+		if values, ok := flagValueCompletions[word][flag]; ok {
+			return values, true
+		}
+	}
+
+	flags := app.Flags
+	subcommands := app.Commands
+	for _, word := range words {
+		if strings.HasPrefix(word, "-") {
+			continue
+		}
+		for _, cmd := range subcommands {
+			if cmd.HasName(word) {
+				flags, subcommands = cmd.Flags, cmd.Subcommands
+				break
+			}
+		}
+	}
+
+	var names []string
+	for _, f := range flags {
+		for _, name := range f.Names() {
+			if len(name) == 1 {
+				name = "-" + name
+			} else {
+				name = "--" + name
+			}
+			if strings.HasPrefix(name, flag) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names, true
+}
+
+// installLeafCompletion makes commands without subcommands offer no candidates, so the shell falls back
+// to file names for arguments such as <file-path>; their flags are completed by completeFlags
+func installLeafCompletion(commands map[string]*cli.Command) {
+	for _, cmd := range commands {
+		if len(cmd.Subcommands) == 0 && cmd.BashComplete == nil {
+			cmd.BashComplete = func(*cli.Context) {}
+		}
+	}
+}
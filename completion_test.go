@@ -0,0 +1,49 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+func TestCompleteFlags(t *testing.T) {
+	app := &cli.App{
+		Flags: []cli.Flag{&cli.BoolFlag{Name: "dry-run"}, &cli.StringFlag{Name: "config"}},
+		Commands: []*cli.Command{
+			{
+				Name:    "pdf-compress",
+				Aliases: []string{"pdfc"},
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "quality", Aliases: []string{"Q"}},
+					&cli.StringFlag{Name: "output", Aliases: []string{"o"}},
+				},
+			},
+		},
+	}
+	qualities := sortedKeys(validQualities)
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+		handled  bool
+	}{
+		{name: "quality values", args: []string{"containers", "pdf-compress", "--quality", completionFlag}, expected: qualities, handled: true},
+		{name: "alias and short flag", args: []string{"containers", "pdfc", "-Q", completionFlag}, expected: qualities, handled: true},
+		{name: "partial flag", args: []string{"containers", "pdf-compress", "--q", completionFlag}, expected: []string{"--quality"}, handled: true},
+		{name: "all command flags", args: []string{"containers", "--dry-run", "pdfc", "-", completionFlag}, expected: []string{"--quality", "-Q", "--output", "-o"}, handled: true},
+		{name: "global flags", args: []string{"containers", "--c", completionFlag}, expected: []string{"--config"}, handled: true},
+		{name: "argument", args: []string{"containers", "pdf-compress", completionFlag}},
+		{name: "not a completion request", args: []string{"containers", "pdf-compress", "--quality", "ebook"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, handled := completeFlags(tt.args, app)
+			if handled != tt.handled || !slices.Equal(got, tt.expected) {
+				t.Errorf("completeFlags() = %v, %v, expected %v, %v", got, handled, tt.expected, tt.handled)
+			}
+		})
+	}
+}
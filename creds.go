@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/urfave/cli/v2"
+	"github.com/vupham90/containers/keychain"
+)
+
+const (
+	// credsFileMagic identifies an encrypted credential export file (format version 1)
+	credsFileMagic = "CNTCRED1"
+
+	credsSaltSize       = 16
+	credsKeySize        = 32
+	credsKDFIterations  = 600000
+	credsExportFileMode = 0600
+)
+
+// credsBundle is the plaintext payload of an export file; it only ever exists in memory
+type credsBundle struct {
+	Service  string            `json:"service"`
+	Accounts map[string]string `json:"accounts"`
+}
+
+// runCredsExport writes every keychain entry of the bw-backup service to an encrypted file
+func runCredsExport(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("expected 1 argument: file-path")
+	}
+	filePath := c.Args().Get(0)
+
+	if _, err := os.Stat(filePath); err == nil && !c.Bool("force") {
+		return fmt.Errorf("file already exists: %s (use --force to overwrite)", filePath)
+	}
+
+	accounts, err := keychain.ListAccounts(bwKeychainService)
+	if err != nil {
+		return err
+	}
+	if len(accounts) == 0 {
+		return fmt.Errorf("no credentials found in Keychain for service %s", bwKeychainService)
+	}
+
+	bundle := credsBundle{Service: bwKeychainService, Accounts: make(map[string]string, len(accounts))}
+	for _, account := range accounts {
+		password, err := keychain.GetPassword(bwKeychainService, account)
+		if err != nil {
+			return err
+		}
+		bundle.Accounts[account] = password
+	}
+
+	passphrase, err := keychain.PromptPassword("Enter export passphrase: ")
+	if err != nil {
+		return err
+	}
+	confirm, err := keychain.PromptPassword("Confirm export passphrase: ")
+	if err != nil {
+		return err
+	}
+	if passphrase != confirm {
+		return fmt.Errorf("passphrases do not match")
+	}
+	if passphrase == "" {
+		return fmt.Errorf("passphrase must not be empty")
+	}
+
+	plaintext, err := json.Marshal(bundle)
+	if err != nil {
+		return fmt.Errorf("failed to encode credentials: %w", err)
+	}
+
+	data, err := encryptCreds(plaintext, passphrase)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filePath, data, credsExportFileMode); err != nil {
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+
+	fmt.Printf("Exported %d credential(s) to %s\n", len(bundle.Accounts), filePath)
+	return nil
+}
+
+// runCredsImport reads an encrypted export file and stores its entries in the keychain
+func runCredsImport(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("expected 1 argument: file-path")
+	}
+
+	data, err := os.ReadFile(c.Args().Get(0))
+	if err != nil {
+		return fmt.Errorf("failed to read export file: %w", err)
+	}
+
+	passphrase, err := keychain.PromptPassword("Enter export passphrase: ")
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := decryptCreds(data, passphrase)
+	if err != nil {
+		return err
+	}
+
+	bundle, err := decodeCredsBundle(plaintext)
+	if err != nil {
+		return err
+	}
+
+	accounts := make([]string, 0, len(bundle.Accounts))
+	for account := range bundle.Accounts {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+
+	for _, account := range accounts {
+		if err := keychain.SetPassword(bwKeychainService, account, bundle.Accounts[account]); err != nil {
+			return err
+		}
+		fmt.Printf("  %s %s\n", markOK(), account)
+	}
+
+	fmt.Printf("Imported %d credential(s) into service %s\n", len(accounts), bwKeychainService)
+	return nil
+}
+
+// decodeCredsBundle parses a decrypted export, rejecting bundles for any service other than
+// bw-backup's so an import cannot overwrite unrelated keychain entries
+func decodeCredsBundle(plaintext []byte) (credsBundle, error) {
+	var bundle credsBundle
+	if err := json.Unmarshal(plaintext, &bundle); err != nil {
+		return credsBundle{}, fmt.Errorf("failed to decode credentials: %w", err)
+	}
+	if bundle.Service != bwKeychainService {
+		return credsBundle{}, fmt.Errorf("export file is for keychain service %q, expected %s", bundle.Service, bwKeychainService)
+	}
+	return bundle, nil
+}
+
+// encryptCreds seals plaintext with AES-256-GCM using a PBKDF2-derived key.
+// Layout: magic | salt | nonce | ciphertext
+func encryptCreds(plaintext []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, credsSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	gcm, err := credsCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(credsFileMagic)
+	buf.Write(salt)
+	buf.Write(nonce)
+	buf.Write(gcm.Seal(nil, nonce, plaintext, []byte(credsFileMagic)))
+	return buf.Bytes(), nil
+}
+
+// decryptCreds reverses encryptCreds, failing on a wrong passphrase or tampered file
+func decryptCreds(data []byte, passphrase string) ([]byte, error) {
+	if len(data) < len(credsFileMagic)+credsSaltSize || string(data[:len(credsFileMagic)]) != credsFileMagic {
+		return nil, fmt.Errorf("not a credential export file")
+	}
+	data = data[len(credsFileMagic):]
+	salt, data := data[:credsSaltSize], data[credsSaltSize:]
+
+	gcm, err := credsCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("credential export file is truncated")
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, []byte(credsFileMagic))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt credentials (wrong passphrase?)")
+	}
+	return plaintext, nil
+}
+
+// credsCipher derives the AES key from the passphrase and returns a GCM AEAD
+func credsCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, credsKDFIterations, credsKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return gcm, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestEncryptDecryptCreds(t *testing.T) {
+	bundle := credsBundle{Service: bwKeychainService, Accounts: map[string]string{
+		"default-client-id":     "user.1234",
+		"default-client-secret": "s3cr3t, with \"quotes\"",
+	}}
+	plaintext, err := json.Marshal(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := encryptCreds(plaintext, "correct horse")
+	if err != nil {
+		t.Fatalf("encryptCreds() error = %v", err)
+	}
+	if bytes.Contains(data, []byte("s3cr3t")) {
+		t.Fatal("export file contains a plaintext secret")
+	}
+
+	// tamper flips one bit at offset (negative offsets count from the end)
+	tamper := func(offset int) []byte {
+		copied := bytes.Clone(data)
+		if offset < 0 {
+			offset += len(copied)
+		}
+		copied[offset] ^= 0x01
+		return copied
+	}
+	saltOffset := len(credsFileMagic)
+
+	tests := []struct {
+		name       string
+		data       []byte
+		passphrase string
+		expectErr  bool
+	}{
+		{name: "round trip", data: data, passphrase: "correct horse"},
+		{name: "wrong passphrase", data: data, passphrase: "wrong horse", expectErr: true},
+		{name: "tampered ciphertext", data: tamper(-1), passphrase: "correct horse", expectErr: true},
+		{name: "tampered salt", data: tamper(saltOffset), passphrase: "correct horse", expectErr: true},
+		{name: "bad magic", data: tamper(0), passphrase: "correct horse", expectErr: true},
+		{name: "truncated", data: data[:saltOffset+credsSaltSize+4], passphrase: "correct horse", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decryptCreds(tt.data, tt.passphrase)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("decryptCreds() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if tt.expectErr {
+				return
+			}
+			decoded, err := decodeCredsBundle(got)
+			if err != nil {
+				t.Fatalf("decodeCredsBundle() error = %v", err)
+			}
+			if !reflect.DeepEqual(decoded, bundle) {
+				t.Errorf("decrypted bundle = %+v, want %+v", decoded, bundle)
+			}
+		})
+	}
+}
+
+func TestEncryptCredsUsesFreshSalt(t *testing.T) {
+	first, err := encryptCreds([]byte("{}"), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := encryptCreds([]byte("{}"), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(first, second) {
+		t.Error("two exports with the same passphrase are identical; salt and nonce must be random")
+	}
+}
+
+func TestDecodeCredsBundle(t *testing.T) {
+	tests := []struct {
+		name      string
+		plaintext string
+		expectErr bool
+	}{
+		{name: "bw-backup service", plaintext: `{"service":"` + bwKeychainService + `","accounts":{"a":"b"}}`},
+		{name: "other service", plaintext: `{"service":"com.apple.account","accounts":{"a":"b"}}`, expectErr: true},
+		{name: "missing service", plaintext: `{"accounts":{"a":"b"}}`, expectErr: true},
+		{name: "not json", plaintext: `accounts`, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeCredsBundle([]byte(tt.plaintext))
+			if (err != nil) != tt.expectErr {
+				t.Errorf("decodeCredsBundle() error = %v, expectErr %v", err, tt.expectErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// daemonWaitInterval is how often waitForDaemon polls the container
+const daemonWaitInterval = time.Second
+
+// inspectStateFormat prints a container's state followed by its health status, if it has a healthcheck
+const inspectStateFormat = "{{.State.Status}} {{if .State.Health}}{{.State.Health.Status}}{{end}}"
+
+// daemonReadiness decides from `docker inspect` state output whether a daemon is ready.
+// A running container is ready once its healthcheck (if any) reports healthy and hostPort (if probed)
+// accepts connections. A container that has stopped can never become ready, so that is an error.
+func daemonReadiness(state string, portOpen bool) (bool, error) {
+	status, health, _ := strings.Cut(strings.TrimSpace(state), " ")
+	switch status {
+	case "running":
+		return (health == "" || health == "healthy") && portOpen, nil
+	case "created", "restarting":
+		return false, nil
+	default:
+		return false, fmt.Errorf("container is %s", status)
+	}
+}
+
+// hostPortOpen reports whether something accepts TCP connections on the local host port
+func hostPortOpen(hostPort string) bool {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", hostPort), 2*time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// waitForDaemon polls the named container until it is ready (see daemonReadiness) or timeout passes.
+// An empty hostPort skips the TCP probe and relies on the container state and healthcheck alone.
+func waitForDaemon(name, hostPort string, timeout time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	target := "healthcheck"
+	if hostPort != "" {
+		target = "127.0.0.1:" + hostPort
+	}
+	infof("Waiting up to %s for '%s' to become ready (%s)...\n", timeout, name, target)
+
+	var last string
+	ticker := time.NewTicker(daemonWaitInterval)
+	defer ticker.Stop()
+	for {
+		output, err := dockerCommand("inspect", "--format", inspectStateFormat, name).Output()
+		if err != nil {
+			return fmt.Errorf("failed to inspect container %s: %w", name, err)
+		}
+		last = strings.TrimSpace(string(output))
+		ready, err := daemonReadiness(last, hostPort == "" || hostPortOpen(hostPort))
+		if err != nil {
+			return fmt.Errorf("'%s' did not become ready: %w (see `containers logs %s`)", name, err, name)
+		}
+		if ready {
+			fmt.Printf("'%s' is ready\n", name)
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return fmt.Errorf("'%s' not ready after %s (last state: %s)", name, timeout, last)
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestDaemonReadiness(t *testing.T) {
+	tests := []struct {
+		name      string
+		state     string
+		portOpen  bool
+		wantReady bool
+		expectErr bool
+	}{
+		{name: "running without healthcheck", state: "running ", portOpen: true, wantReady: true},
+		{name: "running but port closed", state: "running ", portOpen: false},
+		{name: "health starting", state: "running starting", portOpen: true},
+		{name: "healthy", state: "running healthy\n", portOpen: true, wantReady: true},
+		{name: "unhealthy keeps waiting", state: "running unhealthy", portOpen: true},
+		{name: "restarting", state: "restarting ", portOpen: false},
+		{name: "created", state: "created ", portOpen: false},
+		{name: "exited", state: "exited ", portOpen: false, expectErr: true},
+		{name: "dead", state: "dead ", portOpen: false, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ready, err := daemonReadiness(tt.state, tt.portOpen)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("daemonReadiness() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if ready != tt.wantReady {
+				t.Errorf("daemonReadiness() = %v, want %v", ready, tt.wantReady)
+			}
+		})
+	}
+}
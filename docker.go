@@ -1,11 +1,28 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"github.com/vupham90/containers/internal/bytesize"
+	"github.com/vupham90/containers/internal/logging"
+	"golang.org/x/term"
 )
 
 // EnvVar represents an environment variable with sensitivity metadata
@@ -14,41 +31,334 @@ type EnvVar struct {
 	Sensitive bool // If true, value will be redacted in logs
 }
 
+// RuntimeSettings holds global options applied to every container invocation
+type RuntimeSettings struct {
+	AllowedRegistries []string          // Registries images may be pulled from
+	KeepContainer     bool              // Keep one-shot containers after exit instead of passing --rm
+	ShowChanges       bool              // Print `docker diff` of kept containers after they exit
+	EngineArgs        []string          // Top-level engine flags inserted before every subcommand
+	Quiet             bool              // Suppress informational output such as the executed engine command
+	DryRun            bool              // Print container invocations instead of running them
+	PullPolicy        string            // Image pull policy passed as --pull: always, missing or never (empty keeps the engine default)
+	Timeout           time.Duration     // Abort container runs that take longer than this (zero means no limit)
+	Retries           int               // Extra `docker run` attempts after a transient engine failure such as a pull error
+	Labels            map[string]string // Extra labels from --label added to every container
+}
+
+// managedLabel marks containers started by this tool so management commands never touch others
+const managedLabel = "containers.managed=true"
+
+// runtimeSettings is populated from global flags before any command runs
+var runtimeSettings = RuntimeSettings{
+	AllowedRegistries: []string{"ghcr.io"},
+}
+
+// validPullPolicies lists the values accepted by --pull
+var validPullPolicies = []string{"always", "missing", "never"}
+
+// validatePullPolicy checks a --pull value
+func validatePullPolicy(policy string) error {
+	if !slices.Contains(validPullPolicies, policy) {
+		return fmt.Errorf("invalid pull policy: %s (must be always, missing or never)", policy)
+	}
+	return nil
+}
+
+// pullArgs returns the --pull flag for the configured policy. With never, the image must already
+// be present locally, which is checked up front so offline runs fail with a clear message.
+func pullArgs(image string) ([]string, error) {
+	policy := runtimeSettings.PullPolicy
+	if policy == "" {
+		return nil, nil
+	}
+	if policy == "never" && !runtimeSettings.DryRun {
+		if err := dockerCommand("image", "inspect", "--format", "{{.Id}}", image).Run(); err != nil {
+			return nil, fmt.Errorf("image %s is not available locally and --pull never forbids pulling it (run `containers images pull` while online)", image)
+		}
+	}
+	return []string{"--pull", policy}, nil
+}
+
+// sensitiveKeyMarkers lists substrings that mark an environment variable name as holding a secret
+var sensitiveKeyMarkers = []string{"PASSWORD", "PASSWD", "SECRET", "TOKEN", "APIKEY", "API_KEY", "PRIVATE", "CREDENTIAL", "USERID"}
+
+// isSensitiveKey reports whether an environment variable name matches the sensitive-key registry
+func isSensitiveKey(key string) bool {
+	upper := strings.ToUpper(key)
+	for _, marker := range sensitiveKeyMarkers {
+		if strings.Contains(upper, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseEnvAssignments converts KEY=VALUE strings into env entries, marking sensitive keys for redaction
+func parseEnvAssignments(assignments []string) (map[string]EnvVar, error) {
+	env := make(map[string]EnvVar, len(assignments))
+	for _, assignment := range assignments {
+		key, value, ok := strings.Cut(assignment, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid environment variable: %s (expected KEY=VALUE)", assignment)
+		}
+		env[key] = EnvVar{Value: value, Sensitive: isSensitiveKey(key)}
+	}
+	return env, nil
+}
+
+// hostEnvWithPrefix collects host environment variables whose names start with any of the prefixes,
+// marking sensitive keys for redaction
+func hostEnvWithPrefix(prefixes []string) map[string]EnvVar {
+	env := make(map[string]EnvVar)
+	for _, pair := range os.Environ() {
+		key, value, _ := strings.Cut(pair, "=")
+		for _, prefix := range prefixes {
+			if prefix != "" && strings.HasPrefix(key, prefix) {
+				env[key] = EnvVar{Value: value, Sensitive: isSensitiveKey(key)}
+				break
+			}
+		}
+	}
+	return env
+}
+
+// ContainerOptions configures a one-shot container run
+type ContainerOptions struct {
+	Env        map[string]EnvVar // Environment variables, sensitive values are redacted in logs
+	Tmpfs      []string          // tmpfs mounts in path[:options] form
+	Volumes    []string          // Additional volume mounts in host:container form
+	Remove     bool              // Remove the container when it exits (--rm)
+	User       string            // uid:gid to run as (empty keeps the image default)
+	Capture    io.Writer         // If set, also receives the container's stdout and stderr
+	UserNS     string            // User namespace mode passed as --userns (empty keeps the engine default)
+	Limits     ResourceLimits    // Optional CPU and memory limits
+	Entrypoint string            // Overrides the image's entrypoint (empty keeps the image default)
+	ReadOnly   bool              // Mount the container's root filesystem read-only (--read-only); only tmpfs and volumes stay writable
+	NoNewPrivs bool              // Block privilege escalation through setuid binaries (--security-opt no-new-privileges)
+	CapDrop    []string          // Linux capabilities to drop (--cap-drop), e.g. ALL
+	CapAdd     []string          // Linux capabilities to add back after dropping (--cap-add)
+	Network    string            // Network mode passed as --network: none, bridge, host or a named network (empty keeps the engine default)
+	Labels     map[string]string // Labels passed as --label, usually toolLabels(command)
+}
+
+// validateNetwork checks a --network value: a network name, or container:<name> to share another container's
+func validateNetwork(network string) error {
+	name := strings.TrimPrefix(network, "container:")
+	if name == "" || strings.HasPrefix(name, "-") || strings.ContainsAny(name, " \t=,") {
+		return fmt.Errorf("invalid network mode: %q (e.g. none, bridge, host or a network name)", network)
+	}
+	return nil
+}
+
+// linuxCapabilities lists the capability names docker accepts, without the CAP_ prefix
+var linuxCapabilities = []string{
+	"AUDIT_CONTROL", "AUDIT_READ", "AUDIT_WRITE", "BLOCK_SUSPEND", "BPF", "CHECKPOINT_RESTORE", "CHOWN",
+	"DAC_OVERRIDE", "DAC_READ_SEARCH", "FOWNER", "FSETID", "IPC_LOCK", "IPC_OWNER", "KILL", "LEASE",
+	"LINUX_IMMUTABLE", "MAC_ADMIN", "MAC_OVERRIDE", "MKNOD", "NET_ADMIN", "NET_BIND_SERVICE", "NET_BROADCAST",
+	"NET_RAW", "PERFMON", "SETFCAP", "SETGID", "SETPCAP", "SETUID", "SYS_ADMIN", "SYS_BOOT", "SYS_CHROOT",
+	"SYS_MODULE", "SYS_NICE", "SYS_PACCT", "SYS_PTRACE", "SYS_RAWIO", "SYS_RESOURCE", "SYS_TIME",
+	"SYS_TTY_CONFIG", "SYSLOG", "WAKE_ALARM",
+}
+
+// capabilityArgs validates the capability names and returns the --cap-drop flags followed by the --cap-add
+// flags, the order in which docker applies them. Names are case-insensitive, with or without CAP_.
+func capabilityArgs(drop, add []string) ([]string, error) {
+	var args []string
+	for _, set := range []struct {
+		flag string
+		caps []string
+	}{{"--cap-drop", drop}, {"--cap-add", add}} {
+		for _, capability := range set.caps {
+			name := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(capability)), "CAP_")
+			if name != "ALL" && !slices.Contains(linuxCapabilities, name) {
+				return nil, fmt.Errorf("invalid %s capability: %q (e.g. ALL, CHOWN, NET_BIND_SERVICE)", set.flag, capability)
+			}
+			args = append(args, set.flag, name)
+		}
+	}
+	return args, nil
+}
+
+// DaemonOptions holds optional settings for RunDaemon
+type DaemonOptions struct {
+	Volumes []string          // Volume mounts in host:container form
+	Limits  ResourceLimits    // Optional CPU and memory limits
+	Labels  map[string]string // Labels passed as --label, usually toolLabels(command)
+}
+
+// ResourceLimits caps a container's resources; empty fields omit the corresponding flag
+type ResourceLimits struct {
+	Memory string // Memory limit passed as --memory, e.g. 512m or 2g
+	CPUs   string // CPU limit passed as --cpus, e.g. 1.5
+}
+
+// resourceLimitsFromFlags reads and validates a command's --memory and --cpus flags
+func resourceLimitsFromFlags(c *cli.Context) (ResourceLimits, error) {
+	limits := ResourceLimits{Memory: c.String("memory"), CPUs: c.String("cpus")}
+	return limits, limits.Validate()
+}
+
+// minContainerMemory is the smallest --memory docker accepts
+const minContainerMemory = 6 * bytesize.MiB
+
+// Validate rejects limits docker would refuse, so errors surface before the engine is invoked
+func (l ResourceLimits) Validate() error {
+	if l.Memory != "" {
+		size, err := bytesize.ParseSize(l.Memory)
+		if err != nil {
+			return fmt.Errorf("invalid --memory: %w", err)
+		}
+		if size < minContainerMemory {
+			return fmt.Errorf("invalid --memory: %s is below the 6m minimum", l.Memory)
+		}
+	}
+	if l.CPUs != "" {
+		cpus, err := strconv.ParseFloat(l.CPUs, 64)
+		if err != nil || cpus <= 0 || math.IsInf(cpus, 0) {
+			return fmt.Errorf("invalid --cpus: %q (must be a positive number, e.g. 1.5)", l.CPUs)
+		}
+	}
+	return nil
+}
+
+// args returns the docker run flags for the configured limits
+func (l ResourceLimits) args() []string {
+	var args []string
+	if l.Memory != "" {
+		args = append(args, "--memory", l.Memory)
+	}
+	if l.CPUs != "" {
+		args = append(args, "--cpus", l.CPUs)
+	}
+	return args
+}
+
 // RunContainer executes a Docker container with the specified image, working directory, and arguments.
 // The working directory is mounted as /workspace in the container.
-// Optional environment variables, tmpfs mounts, and additional volume mounts can be provided.
-func RunContainer(image, workDir string, args []string, env map[string]EnvVar, tmpfs []string, volumeMounts []string, removeContainer bool) error {
+// Optional environment variables, tmpfs mounts, and additional volume mounts are set through opts.
+// Cancelling ctx, or SIGINT/SIGTERM while the container runs, stops the container instead of leaving it behind.
+func RunContainer(ctx context.Context, image, workDir string, args []string, opts ContainerOptions) error {
+	if err := checkImageAllowed(image, runtimeSettings.AllowedRegistries); err != nil {
+		return err
+	}
+
+	for _, mount := range opts.Volumes {
+		if err := validateMount(mount); err != nil {
+			return err
+		}
+	}
+	if err := opts.Limits.Validate(); err != nil {
+		return err
+	}
+	capArgs, err := capabilityArgs(opts.CapDrop, opts.CapAdd)
+	if err != nil {
+		return err
+	}
+	if opts.User != "" {
+		if err := validateUserSpec(opts.User); err != nil {
+			return err
+		}
+	}
+	if opts.Network != "" {
+		if err := validateNetwork(opts.Network); err != nil {
+			return err
+		}
+	}
+
 	// Resolve absolute path for volume mount
 	absWorkDir, err := filepath.Abs(workDir)
 	if err != nil {
 		return fmt.Errorf("failed to resolve work directory: %w", err)
 	}
 
-	// Verify directory exists
-	if _, err := os.Stat(absWorkDir); os.IsNotExist(err) {
+	// Verify directory exists; a dry run may show a directory the real run would create first
+	if _, err := os.Stat(absWorkDir); os.IsNotExist(err) && !runtimeSettings.DryRun {
 		return fmt.Errorf("work directory does not exist: %s", absWorkDir)
 	}
+	logging.Verbosef("work directory: %s (mounted at /workspace), image: %s", absWorkDir, image)
+
+	if !runtimeSettings.DryRun {
+		if err := checkEngine(); err != nil {
+			return err
+		}
+	}
+
+	pull, err := pullArgs(image)
+	if err != nil {
+		return err
+	}
+	if err := pullMissingImage(ctx, image); err != nil {
+		return err
+	}
+	if err := verifyImageDigest(image); err != nil {
+		return err
+	}
 
 	// Build docker run command
-	dockerArgs := []string{"run"}
+	dockerArgs := []string{"run", "--label", managedLabel}
+	dockerArgs = append(dockerArgs, labelArgs(opts.Labels)...)
+	dockerArgs = append(dockerArgs, pull...)
 
 	// Add --rm flag if requested
-	if removeContainer {
+	if opts.Remove && !runtimeSettings.KeepContainer {
 		dockerArgs = append(dockerArgs, "--rm")
 	}
 
+	// Record the container ID so an interrupted run can be stopped and kept containers inspected
+	cidDir, err := os.MkdirTemp("", "containers-cid-")
+	if err != nil {
+		return fmt.Errorf("failed to create container ID directory: %w", err)
+	}
+	defer os.RemoveAll(cidDir)
+	cidFile := filepath.Join(cidDir, "cid")
+	dockerArgs = append(dockerArgs, "--cidfile", cidFile)
+
+	// Run as a specific user if requested
+	if opts.User != "" {
+		dockerArgs = append(dockerArgs, "--user", opts.User)
+	}
+
+	// Remap the container's users into an unprivileged host range if requested
+	if opts.UserNS != "" {
+		dockerArgs = append(dockerArgs, "--userns", opts.UserNS)
+	}
+
+	// Cap CPU and memory if requested
+	dockerArgs = append(dockerArgs, opts.Limits.args()...)
+
+	// Harden the container if requested
+	if opts.ReadOnly {
+		dockerArgs = append(dockerArgs, "--read-only")
+	}
+	if opts.NoNewPrivs {
+		dockerArgs = append(dockerArgs, "--security-opt", "no-new-privileges")
+	}
+	dockerArgs = append(dockerArgs, capArgs...)
+
+	// Attach to the requested network; empty keeps the engine's default bridge
+	if opts.Network != "" {
+		dockerArgs = append(dockerArgs, "--network", opts.Network)
+	}
+
+	// Run a different script from the same image if requested
+	if opts.Entrypoint != "" {
+		dockerArgs = append(dockerArgs, "--entrypoint", opts.Entrypoint)
+	}
+
 	// Add tmpfs mounts
-	for _, mount := range tmpfs {
+	for _, mount := range opts.Tmpfs {
+		logging.Debugf("tmpfs mount: %s", mount)
 		dockerArgs = append(dockerArgs, "--tmpfs", mount)
 	}
 
 	// Add environment variables
-	for key, envVar := range env {
+	for key, envVar := range opts.Env {
 		dockerArgs = append(dockerArgs, "-e", fmt.Sprintf("%s=%s", key, envVar.Value))
 	}
 
 	// Add custom volume mounts
-	for _, mount := range volumeMounts {
+	for _, mount := range opts.Volumes {
+		logging.Debugf("volume mount: %s", mount)
 		dockerArgs = append(dockerArgs, "-v", mount)
 	}
 
@@ -63,49 +373,300 @@ func RunContainer(image, workDir string, args []string, env map[string]EnvVar, t
 	dockerArgs = append(dockerArgs, args...)
 
 	// Debug: Print the exact command being executed with sensitive values redacted
-	sanitizedArgs := sanitizeDockerArgs(dockerArgs, env)
-	fmt.Printf("Executing: docker %s\n", strings.Join(sanitizedArgs, " "))
+	printEngineCommand(dockerArgs, opts.Env)
+	if runtimeSettings.DryRun {
+		return nil
+	}
 
-	// Execute docker command
-	cmd := exec.Command("docker", dockerArgs...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
+	// Execute docker command, retrying transient engine failures up to --retries times
+	ctx, cancel := withRunTimeout(ctx)
+	defer cancel()
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var runErr error
+	for attempt := 1; ; attempt++ {
+		// Keep the tail of stderr so callers can classify failures
+		stderrTail := &tailBuffer{limit: 64 * 1024}
+		cmd := dockerCommandContext(ctx, dockerArgs...)
+		// Killing the client alone leaves the container running; stop it first (--rm then removes it)
+		cmd.Cancel = func() error {
+			stopContainer(cidFile)
+			return cmd.Process.Kill()
+		}
+		cmd.WaitDelay = 5 * time.Second
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = io.MultiWriter(os.Stderr, stderrTail)
+		if opts.Capture != nil {
+			cmd.Stdout = io.MultiWriter(cmd.Stdout, opts.Capture)
+			cmd.Stderr = io.MultiWriter(cmd.Stderr, opts.Capture)
+		}
+		cmd.Stdin = os.Stdin
 
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("docker run failed: %w", err)
+		runErr = nil
+		if err := cmd.Run(); err != nil {
+			runErr = &RunError{Err: err, Stderr: stderrTail.String()}
+		}
+		if runErr == nil || ctx.Err() != nil || attempt > runtimeSettings.Retries || !isTransientRunError(runErr) {
+			break
+		}
+
+		delay := retryBackoff(attempt)
+		fmt.Fprintf(os.Stderr, "Transient engine failure (attempt %d of %d), retrying in %s...\n", attempt, runtimeSettings.Retries+1, delay)
+		// docker run refuses to overwrite an existing --cidfile
+		os.Remove(cidFile)
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
+	}
+
+	if runtimeSettings.KeepContainer {
+		reportKeptContainer(cidFile)
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		// Stopping a --rm container also removes it; anything else would be left behind
+		if !opts.Remove && !runtimeSettings.KeepContainer {
+			if containerID := readContainerID(cidFile); containerID != "" {
+				removeContainer(containerID)
+			}
+		}
+		return timeoutError()
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("container run interrupted: %w", ctx.Err())
+	}
+	if runErr != nil {
+		return runErr
 	}
 
 	return nil
 }
 
-// RunDaemon runs a Docker container in detached mode with the specified configuration.
-// It first removes any existing container with the same name to ensure idempotency.
-func RunDaemon(name, image string, ports map[string]string, env map[string]EnvVar) error {
-	// Remove existing container if it exists
-	removeCmd := exec.Command("docker", "ps", "-a", "--format", "{{.Names}}")
-	output, err := removeCmd.Output()
+// RunError is returned when `docker run` fails; it carries the tail of stderr for failure classification
+type RunError struct {
+	Err    error
+	Stderr string
+}
+
+func (e *RunError) Error() string {
+	return fmt.Sprintf("docker run failed: %v", e.Err)
+}
+
+func (e *RunError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns the exit code of the docker CLI, or -1 if it did not exit normally
+func (e *RunError) ExitCode() int {
+	var exitErr *exec.ExitError
+	if errors.As(e.Err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// isTmpfsError reports whether a run failed because the engine rejected a tmpfs mount.
+// Engine-level failures exit with 125 before the container starts.
+func isTmpfsError(err error) bool {
+	var runErr *RunError
+	if !errors.As(err, &runErr) || runErr.ExitCode() != 125 {
+		return false
+	}
+	return strings.Contains(strings.ToLower(runErr.Stderr), "tmpfs")
+}
+
+// transientRunErrors are lower-cased stderr fragments of registry and network failures worth retrying
+var transientRunErrors = []string{
+	"error pulling image",
+	"toomanyrequests",
+	"i/o timeout",
+	"tls handshake timeout",
+	"connection reset by peer",
+	"connection refused",
+	"temporary failure in name resolution",
+	"unexpected eof",
+	"503 service unavailable",
+	"502 bad gateway",
+	"504 gateway timeout",
+}
+
+// isTransientRunError reports whether a run failed in the engine (exit 125, before the container
+// started) for a reason that may go away on its own. A non-zero exit of the container itself never is.
+func isTransientRunError(err error) bool {
+	var runErr *RunError
+	if !errors.As(err, &runErr) || runErr.ExitCode() != 125 {
+		return false
+	}
+	return isTransientEngineOutput(runErr.Stderr)
+}
+
+// isTransientEngineOutput reports whether engine stderr shows one of the transientRunErrors
+func isTransientEngineOutput(stderr string) bool {
+	stderr = strings.ToLower(stderr)
+	for _, fragment := range transientRunErrors {
+		if strings.Contains(stderr, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryBaseDelay is the wait before the first retry; it doubles per attempt up to retryMaxDelay
+var retryBaseDelay = 2 * time.Second
+
+// retryMaxDelay caps the backoff between retries
+const retryMaxDelay = 30 * time.Second
+
+// retryBackoff returns the wait before retrying after the given failed attempt (1-based)
+func retryBackoff(attempt int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempt && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, retryMaxDelay)
+}
+
+// tailBuffer is an io.Writer that keeps only the last limit bytes written to it
+type tailBuffer struct {
+	limit int
+	data  []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.data = append(b.data, p...)
+	if len(b.data) > b.limit {
+		b.data = b.data[len(b.data)-b.limit:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	return string(b.data)
+}
+
+// reportKeptContainer prints the ID of a kept container and, if requested, its filesystem changes
+func reportKeptContainer(cidFile string) {
+	data, err := os.ReadFile(cidFile)
 	if err != nil {
-		return fmt.Errorf("failed to list containers: %w", err)
+		fmt.Fprintf(os.Stderr, "Warning: failed to read container ID: %v\n", err)
+		return
 	}
+	containerID := strings.TrimSpace(string(data))
+	fmt.Printf("Kept container %s (remove with: docker rm %s)\n", containerID, containerID)
 
-	// Check if container exists
-	containerExists := false
-	for _, line := range []string{string(output)} {
-		if line == name {
-			containerExists = true
-			break
+	if !runtimeSettings.ShowChanges {
+		return
+	}
+
+	output, err := dockerCommand("diff", containerID).Output()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: docker diff failed: %v\n", err)
+		return
+	}
+
+	changes := parseContainerDiff(string(output))
+	if len(changes) == 0 {
+		fmt.Println("Filesystem changes: none")
+		return
+	}
+
+	fmt.Println("Filesystem changes:")
+	for _, kind := range []string{"Added", "Changed", "Deleted"} {
+		paths := changes[kind]
+		if len(paths) == 0 {
+			continue
+		}
+		fmt.Printf("  %s (%d):\n", kind, len(paths))
+		for _, path := range paths {
+			fmt.Printf("    %s\n", path)
 		}
 	}
+}
 
-	if containerExists {
-		rmCmd := exec.Command("docker", "rm", "-f", name)
-		rmCmd.Stdout = os.Stdout
-		rmCmd.Stderr = os.Stderr
-		if err := rmCmd.Run(); err != nil {
-			return fmt.Errorf("failed to remove existing container: %w", err)
+// parseContainerDiff groups `docker diff` output lines ("A /path", "C /path", "D /path") by kind
+func parseContainerDiff(output string) map[string][]string {
+	kinds := map[string]string{"A": "Added", "C": "Changed", "D": "Deleted"}
+	changes := make(map[string][]string)
+	for _, line := range strings.Split(output, "\n") {
+		code, path, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		if kind, known := kinds[code]; known {
+			changes[kind] = append(changes[kind], path)
 		}
 	}
+	return changes
+}
+
+// hostUserSpec returns the current host user as uid:gid, or "" where uids don't apply (Windows)
+func hostUserSpec() string {
+	uid, gid := os.Getuid(), os.Getgid()
+	if uid < 0 || gid < 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d", uid, gid)
+}
+
+// validateUserSpec checks a --user value: a user name or uid, optionally followed by :group or :gid
+func validateUserSpec(user string) error {
+	name, group, hasGroup := strings.Cut(user, ":")
+	if name == "" || (hasGroup && group == "") || strings.HasPrefix(user, "-") || strings.ContainsAny(user, " \t") {
+		return fmt.Errorf("invalid --user: %q (expected uid[:gid] or name[:group])", user)
+	}
+	return nil
+}
+
+// hostUserNS returns the --userns mode that makes a host uid:gid mean the same user inside the container:
+// keep-id under rootless Podman (where uids are otherwise shifted into a subordinate range), else the default
+func hostUserNS(engine engineInfo) string {
+	if engine.Podman && engine.Rootless {
+		return "keep-id"
+	}
+	return ""
+}
+
+// containerUserFromFlags resolves the user a command's container runs as: --user if given, the image's
+// own user with --no-user-mapping, and otherwise the current host user so files it writes are yours
+func containerUserFromFlags(c *cli.Context) (string, error) {
+	if user := c.String("user"); user != "" {
+		if c.Bool("no-user-mapping") {
+			return "", fmt.Errorf("--user cannot be combined with --no-user-mapping")
+		}
+		return user, validateUserSpec(user)
+	}
+	if c.Bool("no-user-mapping") {
+		return "", nil
+	}
+	return hostUserSpec(), nil
+}
+
+// RunDaemon runs a Docker container in detached mode with the specified configuration.
+// It first removes any existing container with the same name to ensure idempotency.
+// Volumes and resource limits are set through opts.
+func RunDaemon(name, image string, ports map[string]string, env map[string]EnvVar, opts DaemonOptions) error {
+	if err := checkImageAllowed(image, runtimeSettings.AllowedRegistries); err != nil {
+		return err
+	}
+	if err := opts.Limits.Validate(); err != nil {
+		return err
+	}
+	if !runtimeSettings.DryRun {
+		if err := checkEngine(); err != nil {
+			return err
+		}
+	}
+	pull, err := pullArgs(image)
+	if err != nil {
+		return err
+	}
+	if err := pullMissingImage(context.Background(), image); err != nil {
+		return err
+	}
+	if err := verifyImageDigest(image); err != nil {
+		return err
+	}
 
 	// Build docker run command
 	dockerArgs := []string{
@@ -113,7 +674,13 @@ func RunDaemon(name, image string, ports map[string]string, env map[string]EnvVa
 		"-d",
 		"--name", name,
 		"--restart", "unless-stopped",
+		"--label", managedLabel,
 	}
+	dockerArgs = append(dockerArgs, labelArgs(opts.Labels)...)
+	dockerArgs = append(dockerArgs, pull...)
+
+	// Cap CPU and memory if requested
+	dockerArgs = append(dockerArgs, opts.Limits.args()...)
 
 	// Add port mappings
 	for hostPort, containerPort := range ports {
@@ -125,38 +692,545 @@ func RunDaemon(name, image string, ports map[string]string, env map[string]EnvVa
 		dockerArgs = append(dockerArgs, "-e", fmt.Sprintf("%s=%s", key, envVar.Value))
 	}
 
+	// Add volume mounts
+	for _, volume := range opts.Volumes {
+		logging.Debugf("volume mount: %s", volume)
+		dockerArgs = append(dockerArgs, "-v", volume)
+	}
+
 	// Add image
 	dockerArgs = append(dockerArgs, image)
 
-	// Execute docker command
-	cmd := exec.Command("docker", dockerArgs...)
+	printEngineCommand(dockerArgs, env)
+	if runtimeSettings.DryRun {
+		return nil
+	}
+
+	// Remove existing container if it exists
+	exists, err := containerExists(name)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		rmCmd := dockerCommand("rm", "-f", name)
+		rmCmd.Stdout = os.Stdout
+		rmCmd.Stderr = os.Stderr
+		if err := rmCmd.Run(); err != nil {
+			return fmt.Errorf("failed to remove existing container: %w", err)
+		}
+	}
+
+	// Execute docker command; a pull that hangs past --timeout is killed and the container removed
+	ctx, cancel := withRunTimeout(context.Background())
+	defer cancel()
+	cmd := dockerCommandContext(ctx, dockerArgs...)
+	cmd.WaitDelay = 5 * time.Second
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
 	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			removeContainer(name)
+			return timeoutError()
+		}
 		return fmt.Errorf("docker run failed: %w", err)
 	}
 
 	return nil
 }
 
-// sanitizeDockerArgs redacts sensitive environment variable values from docker arguments for logging
+// containerInspect holds the subset of `docker inspect` output used for drift detection
+type containerInspect struct {
+	Config struct {
+		Image string   `json:"Image"`
+		Env   []string `json:"Env"`
+	} `json:"Config"`
+	HostConfig struct {
+		PortBindings map[string][]struct {
+			HostPort string `json:"HostPort"`
+		} `json:"PortBindings"`
+	} `json:"HostConfig"`
+}
+
+// DiffDaemon compares an existing daemon container against the desired configuration.
+// It returns exists=false when there is no container to compare against.
+func DiffDaemon(name, image string, ports map[string]string, env map[string]EnvVar) (changes []string, exists bool, err error) {
+	exists, err = containerExists(name)
+	if err != nil || !exists {
+		return nil, exists, err
+	}
+
+	output, err := dockerCommand("inspect", name).Output()
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to inspect container: %w", err)
+	}
+
+	var inspected []containerInspect
+	if err := json.Unmarshal(output, &inspected); err != nil || len(inspected) == 0 {
+		return nil, true, fmt.Errorf("failed to parse docker inspect output for %s", name)
+	}
+
+	return diffDaemonConfig(inspected[0], image, ports, env), true, nil
+}
+
+// diffDaemonConfig lists human-readable differences between an inspected container and the desired config.
+// Env values are never included. Env keys present only on the container are not reported because
+// they are indistinguishable from defaults baked into the image.
+func diffDaemonConfig(existing containerInspect, image string, ports map[string]string, env map[string]EnvVar) []string {
+	var changes []string
+
+	if existing.Config.Image != image {
+		changes = append(changes, fmt.Sprintf("image: %s → %s", existing.Config.Image, image))
+	}
+
+	current := make(map[string]bool)
+	for containerPort, bindings := range existing.HostConfig.PortBindings {
+		for _, binding := range bindings {
+			current[fmt.Sprintf("%s:%s", binding.HostPort, strings.TrimSuffix(containerPort, "/tcp"))] = true
+		}
+	}
+	desired := make(map[string]bool)
+	for hostPort, containerPort := range ports {
+		desired[fmt.Sprintf("%s:%s", hostPort, containerPort)] = true
+	}
+	var portChanges []string
+	for mapping := range desired {
+		if !current[mapping] {
+			portChanges = append(portChanges, "port added: "+mapping)
+		}
+	}
+	for mapping := range current {
+		if !desired[mapping] {
+			portChanges = append(portChanges, "port removed: "+mapping)
+		}
+	}
+	sort.Strings(portChanges)
+	changes = append(changes, portChanges...)
+
+	currentEnv := make(map[string]string)
+	for _, pair := range existing.Config.Env {
+		key, value, _ := strings.Cut(pair, "=")
+		currentEnv[key] = value
+	}
+	var envChanges []string
+	for key, envVar := range env {
+		value, ok := currentEnv[key]
+		switch {
+		case !ok:
+			envChanges = append(envChanges, fmt.Sprintf("env added: %s", key))
+		case value != envVar.Value:
+			envChanges = append(envChanges, fmt.Sprintf("env changed: %s", key))
+		}
+	}
+	sort.Strings(envChanges)
+	changes = append(changes, envChanges...)
+
+	return changes
+}
+
+// validateLogTail checks a --tail value: a non-negative line count or "all"
+func validateLogTail(tail string) error {
+	if tail == "all" {
+		return nil
+	}
+	if n, err := strconv.Atoi(tail); err != nil || n < 0 {
+		return fmt.Errorf("invalid --tail: %q (must be a non-negative number or 'all')", tail)
+	}
+	return nil
+}
+
+// ContainerLogs streams the last tail lines of a container's output via `docker logs`, following new
+// output if requested. Ctrl-C (or cancelling ctx) ends the stream without an error.
+func ContainerLogs(ctx context.Context, name, tail string, follow bool) error {
+	if err := validateLogTail(tail); err != nil {
+		return err
+	}
+	exists, err := containerExists(name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("no container named '%s'", name)
+	}
+
+	dockerArgs := []string{"logs", "--tail", tail}
+	if follow {
+		dockerArgs = append(dockerArgs, "-f")
+	}
+	dockerArgs = append(dockerArgs, name)
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	cmd := dockerCommandContext(ctx, dockerArgs...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("docker logs failed: %w", err)
+	}
+	return nil
+}
+
+// ExecContainer runs a command inside a running container via `docker exec`.
+// When stdin and stdout are terminals a TTY is allocated; otherwise it falls back to a non-TTY exec
+// so piped input and captured output keep working.
+func ExecContainer(name string, command []string, detachKeys string) error {
+	running, err := containerRunning(name)
+	if err != nil {
+		return err
+	}
+	if !running {
+		return fmt.Errorf("container is not running: %s", name)
+	}
+
+	dockerArgs := []string{"exec", "-i"}
+
+	interactive := term.IsTerminal(int(syscall.Stdin)) && term.IsTerminal(int(syscall.Stdout))
+	if interactive {
+		dockerArgs = append(dockerArgs, "-t")
+
+		// Seed the initial size for programs that read it from the environment
+		if width, height, err := term.GetSize(int(syscall.Stdout)); err == nil {
+			dockerArgs = append(dockerArgs,
+				"-e", fmt.Sprintf("COLUMNS=%d", width),
+				"-e", fmt.Sprintf("LINES=%d", height),
+			)
+		}
+
+		if detachKeys != "" {
+			dockerArgs = append(dockerArgs, "--detach-keys", detachKeys)
+		}
+	} else if detachKeys != "" {
+		fmt.Fprintln(os.Stderr, "Warning: --detach-keys ignored, stdin is not a terminal")
+	}
+
+	dockerArgs = append(dockerArgs, name)
+	dockerArgs = append(dockerArgs, command...)
+
+	// The docker CLI puts the terminal into raw mode and forwards SIGWINCH resizes to the exec TTY
+	// itself, so the real terminal file descriptors must be handed over directly (not via pipes).
+	cmd := dockerCommand(dockerArgs...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("docker exec failed: %w", err)
+	}
+
+	return nil
+}
+
+// CopyFiles copies files between a container and the host via `docker cp`.
+// Exactly one of src and dst must be a container path in the form name:/path.
+func CopyFiles(src, dst string) error {
+	srcContainer, srcPath, srcIsContainer := parseCopyPath(src)
+	dstContainer, dstPath, dstIsContainer := parseCopyPath(dst)
+
+	if srcIsContainer == dstIsContainer {
+		return fmt.Errorf("exactly one of source and destination must be a container path (name:/path)")
+	}
+
+	container, containerPath := srcContainer, srcPath
+	if dstIsContainer {
+		container, containerPath = dstContainer, dstPath
+	}
+
+	if container == "" {
+		return fmt.Errorf("container name must not be empty")
+	}
+	if !strings.HasPrefix(containerPath, "/") {
+		return fmt.Errorf("container path must be absolute: %s", containerPath)
+	}
+
+	exists, err := containerExists(container)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("container does not exist: %s", container)
+	}
+
+	if dstIsContainer {
+		// Copying into the container: the local source must exist
+		if _, err := os.Stat(srcPath); err != nil {
+			return fmt.Errorf("source does not exist: %s", srcPath)
+		}
+	} else {
+		// Copying out of the container: the local destination's parent must exist
+		parent := filepath.Dir(filepath.Clean(dstPath))
+		if info, err := os.Stat(parent); err != nil || !info.IsDir() {
+			return fmt.Errorf("destination directory does not exist: %s", parent)
+		}
+	}
+
+	cmd := dockerCommand("cp", src, dst)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("docker cp failed: %w", err)
+	}
+
+	return nil
+}
+
+// parseCopyPath splits a docker cp argument into container name and path.
+// Like docker, a colon before the first slash marks a container path; anything else is local.
+func parseCopyPath(arg string) (container, path string, isContainer bool) {
+	colon := strings.Index(arg, ":")
+	slash := strings.Index(arg, "/")
+	if colon <= 0 || (slash >= 0 && slash < colon) {
+		return "", arg, false
+	}
+	return arg[:colon], arg[colon+1:], true
+}
+
+// dockerCommand builds a docker CLI invocation; every engine call goes through here.
+// Global --engine-arg values are inserted between the binary and the subcommand, and
+// failures are checked for an unreachable engine daemon.
+func dockerCommand(args ...string) *engineCmd {
+	return &engineCmd{Cmd: exec.Command("docker", engineCommandArgs(args)...)}
+}
+
+// dockerCommandContext is dockerCommand bound to ctx; the process is killed when ctx is done
+func dockerCommandContext(ctx context.Context, args ...string) *engineCmd {
+	return &engineCmd{Cmd: exec.CommandContext(ctx, "docker", engineCommandArgs(args)...)}
+}
+
+// validateMount checks a host:container volume mount with an optional :ro or :rw mode,
+// requiring an existing absolute host path and an absolute container path
+func validateMount(mount string) error {
+	parts := strings.Split(mount, ":")
+	if len(parts) == 3 && (parts[2] == "ro" || parts[2] == "rw") {
+		parts = parts[:2]
+	}
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("invalid volume mount %q (expected host:container)", mount)
+	}
+	if !filepath.IsAbs(parts[0]) || !path.IsAbs(parts[1]) {
+		return fmt.Errorf("invalid volume mount %q: paths must be absolute", mount)
+	}
+	// A dry run may show a mount whose directory the real run would create first
+	if _, err := os.Stat(parts[0]); err != nil && !runtimeSettings.DryRun {
+		return fmt.Errorf("invalid volume mount %q: host path does not exist", mount)
+	}
+	return nil
+}
+
+// printEngineCommand prints an engine invocation with sensitive values redacted:
+// always in dry-run mode, otherwise unless --quiet is set
+func printEngineCommand(args []string, env map[string]EnvVar) {
+	sanitized := strings.Join(sanitizeDockerArgs(engineCommandArgs(args), env), " ")
+	switch {
+	case runtimeSettings.DryRun:
+		fmt.Printf("Dry run: docker %s\n", sanitized)
+	case !runtimeSettings.Quiet:
+		fmt.Printf("Executing: docker %s\n", sanitized)
+	}
+}
+
+// infof prints informational progress to stdout unless --quiet is set; results and errors bypass it
+func infof(format string, args ...any) {
+	if !runtimeSettings.Quiet {
+		fmt.Printf(format, args...)
+	}
+}
+
+// isInterrupted reports whether err comes from a cancelled or signalled container run
+func isInterrupted(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// ErrTimeout is wrapped by the error returned when a container run exceeds --timeout
+var ErrTimeout = errors.New("timed out")
+
+// withRunTimeout bounds ctx by --timeout; without one the returned context only ends with ctx
+func withRunTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if runtimeSettings.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, runtimeSettings.Timeout)
+}
+
+// timeoutError reports a container run aborted by --timeout. It is not an interruption, so a
+// batch moves on to its next item.
+func timeoutError() error {
+	return fmt.Errorf("container %w after %s (--timeout)", ErrTimeout, runtimeSettings.Timeout)
+}
+
+// readContainerID returns the container ID written to cidFile, or "" if the container has not started
+func readContainerID(cidFile string) string {
+	data, err := os.ReadFile(cidFile)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// removeContainer force-removes a container, warning instead of failing so the original error is kept
+func removeContainer(ref string) {
+	fmt.Fprintf(os.Stderr, "Removing container %s...\n", ref)
+	if output, err := dockerCommand("rm", "-f", ref).CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to remove container %s: %v: %s\n", ref, err, strings.TrimSpace(string(output)))
+	}
+}
+
+// stopContainer stops the container whose ID was written to cidFile, if it has started
+func stopContainer(cidFile string) {
+	containerID := readContainerID(cidFile)
+	if containerID == "" {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Stopping container %s...\n", containerID)
+	if output, err := dockerCommand("stop", containerID).CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to stop container %s: %v: %s\n", containerID, err, strings.TrimSpace(string(output)))
+	}
+}
+
+// engineCommandArgs prefixes args with the configured top-level engine flags
+func engineCommandArgs(args []string) []string {
+	if len(runtimeSettings.EngineArgs) == 0 {
+		return args
+	}
+	return append(append([]string{}, runtimeSettings.EngineArgs...), args...)
+}
+
+// validateEngineArgs ensures every --engine-arg is a flag rather than a stray subcommand
+func validateEngineArgs(args []string) error {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			return fmt.Errorf("invalid engine argument: %q (must start with '-')", arg)
+		}
+	}
+	return nil
+}
+
+// containerRunning reports whether a running container with exactly the given name exists
+func containerRunning(name string) (bool, error) {
+	output, err := dockerCommand("ps", "--format", containerListFormat).Output()
+	if err != nil {
+		return false, fmt.Errorf("failed to list containers: %w", err)
+	}
+	return parseContainerList(string(output), name) != nil, nil
+}
+
+// containerExists reports whether a container (running or stopped) with exactly the given name exists
+func containerExists(name string) (bool, error) {
+	info, err := findContainer(name)
+	return info != nil, err
+}
+
+// containerInfo is one row of `docker ps -a` for a named container
+type containerInfo struct {
+	Name   string
+	State  string // running, exited, created, paused, ...
+	Status string // Human-readable status, e.g. "Up 2 hours"
+	Ports  string // Published ports as docker formats them, e.g. 0.0.0.0:4001->4003/tcp
+}
+
+// containerListFormat is the `docker ps` format parsed by parseContainerList
+const containerListFormat = "{{.Names}}\t{{.State}}\t{{.Status}}\t{{.Ports}}"
+
+// findContainer returns the container (running or stopped) with exactly the given name, or nil if there is none
+func findContainer(name string) (*containerInfo, error) {
+	output, err := dockerCommand("ps", "-a", "--format", containerListFormat).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	return parseContainerList(string(output), name), nil
+}
+
+// parseContainerList finds the row for name in `docker ps --format containerListFormat` output
+func parseContainerList(output, name string) *containerInfo {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(strings.TrimRight(line, "\r"), "\t", 4)
+		if name == "" || strings.TrimSpace(fields[0]) != name {
+			continue
+		}
+		info := &containerInfo{Name: name}
+		for i, field := range []*string{&info.State, &info.Status, &info.Ports} {
+			if i+1 < len(fields) {
+				*field = strings.TrimSpace(fields[i+1])
+			}
+		}
+		return info
+	}
+	return nil
+}
+
+// redactedValue replaces secrets in logged engine commands
+const redactedValue = "***REDACTED***"
+
+// minRedactSubstringLength is the shortest sensitive value redacted wherever it appears in an arg
+const minRedactSubstringLength = 4
+
+// sanitizeDockerArgs redacts sensitive environment variable values from docker arguments for logging.
+// It handles -e/--env KEY=value, the combined -e=KEY=value and --env=KEY=value forms, and --env-file,
+// whose path is redacted since the file holds secrets. A bare -e KEY carries no value and is left alone.
+// Any other occurrence of a sensitive value, e.g. in a positional argument, is redacted as well.
 func sanitizeDockerArgs(args []string, env map[string]EnvVar) []string {
 	result := make([]string, len(args))
 	copy(result, args)
-	
+
+	for i := 0; i < len(result); i++ {
+		arg := result[i]
+		switch {
+		case arg == "-e" || arg == "--env":
+			if i+1 < len(result) {
+				result[i+1] = redactEnvPair(result[i+1], env)
+				i++
+			}
+		case strings.HasPrefix(arg, "-e="):
+			result[i] = "-e=" + redactEnvPair(strings.TrimPrefix(arg, "-e="), env)
+		case strings.HasPrefix(arg, "--env="):
+			result[i] = "--env=" + redactEnvPair(strings.TrimPrefix(arg, "--env="), env)
+		case arg == "--env-file":
+			if i+1 < len(result) {
+				result[i+1] = redactedValue
+				i++
+			}
+		case strings.HasPrefix(arg, "--env-file="):
+			result[i] = "--env-file=" + redactedValue
+		}
+	}
+
+	// Replace longer secrets first so one that contains another is redacted whole. Secrets too short
+	// to search for without mangling unrelated text are only redacted where they make up a whole arg.
+	var secrets []string
+	for _, envVar := range env {
+		if envVar.Sensitive && envVar.Value != "" {
+			secrets = append(secrets, envVar.Value)
+		}
+	}
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
 	for i, arg := range result {
-		if arg == "-e" && i+1 < len(result) {
-			// Check if next arg contains sensitive data
-			envPair := result[i+1]
-			for key, envVar := range env {
-				if envVar.Sensitive && strings.HasPrefix(envPair, key+"=") {
-					result[i+1] = key + "=***REDACTED***"
-					break
+		for _, secret := range secrets {
+			if len(secret) < minRedactSubstringLength {
+				if arg == secret {
+					arg = redactedValue
 				}
+				continue
 			}
+			arg = strings.ReplaceAll(arg, secret, redactedValue)
 		}
+		result[i] = arg
 	}
-	
+
 	return result
 }
+
+// redactEnvPair redacts the value of a KEY=value pair whose key is marked sensitive in env
+func redactEnvPair(pair string, env map[string]EnvVar) string {
+	key, _, found := strings.Cut(pair, "=")
+	if !found {
+		return pair
+	}
+	if envVar, ok := env[key]; ok && envVar.Sensitive {
+		return key + "=" + redactedValue
+	}
+	return pair
+}
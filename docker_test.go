@@ -1,8 +1,16 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestSanitizeDockerArgs(t *testing.T) {
@@ -101,6 +109,93 @@ func TestSanitizeDockerArgs(t *testing.T) {
 			},
 			expected: []string{"run", "--rm", "-e", "COMPLEX=***REDACTED***", "image:latest"},
 		},
+		{
+			name: "long --env flag",
+			args: []string{"run", "--env", "API_KEY=secret123", "image:latest"},
+			env: map[string]EnvVar{
+				"API_KEY": {Value: "secret123", Sensitive: true},
+			},
+			expected: []string{"run", "--env", "API_KEY=***REDACTED***", "image:latest"},
+		},
+		{
+			name: "combined -e=KEY=value form",
+			args: []string{"run", "-e=API_KEY=secret123", "-e=LOG_LEVEL=debug", "image:latest"},
+			env: map[string]EnvVar{
+				"API_KEY":   {Value: "secret123", Sensitive: true},
+				"LOG_LEVEL": {Value: "debug", Sensitive: false},
+			},
+			expected: []string{"run", "-e=API_KEY=***REDACTED***", "-e=LOG_LEVEL=debug", "image:latest"},
+		},
+		{
+			name: "combined --env=KEY=value form",
+			args: []string{"run", "--env=API_KEY=secret123", "image:latest"},
+			env: map[string]EnvVar{
+				"API_KEY": {Value: "secret123", Sensitive: true},
+			},
+			expected: []string{"run", "--env=API_KEY=***REDACTED***", "image:latest"},
+		},
+		{
+			name: "bare -e KEY passes the host value without exposing it",
+			args: []string{"run", "-e", "API_KEY", "-e=API_KEY", "image:latest"},
+			env: map[string]EnvVar{
+				"API_KEY": {Value: "secret123", Sensitive: true},
+			},
+			expected: []string{"run", "-e", "API_KEY", "-e=API_KEY", "image:latest"},
+		},
+		{
+			name:     "env-file path",
+			args:     []string{"run", "--env-file", "/home/me/secrets.env", "image:latest"},
+			env:      map[string]EnvVar{},
+			expected: []string{"run", "--env-file", "***REDACTED***", "image:latest"},
+		},
+		{
+			name:     "combined --env-file=path form",
+			args:     []string{"run", "--env-file=/home/me/secrets.env", "image:latest"},
+			env:      map[string]EnvVar{},
+			expected: []string{"run", "--env-file=***REDACTED***", "image:latest"},
+		},
+		{
+			name: "value that looks like a flag is not rescanned",
+			args: []string{"run", "-e", "NOTE=--env-file", "-e", "API_KEY=secret123", "image:latest"},
+			env: map[string]EnvVar{
+				"API_KEY": {Value: "secret123", Sensitive: true},
+			},
+			expected: []string{"run", "-e", "NOTE=--env-file", "-e", "API_KEY=***REDACTED***", "image:latest"},
+		},
+		{
+			name: "secret in a positional argument",
+			args: []string{"run", "--rm", "image:latest", "login", "--token", "secret123", "--url=https://x/?t=secret123"},
+			env: map[string]EnvVar{
+				"API_KEY": {Value: "secret123", Sensitive: true},
+			},
+			expected: []string{"run", "--rm", "image:latest", "login", "--token", "***REDACTED***", "--url=https://x/?t=***REDACTED***"},
+		},
+		{
+			name: "non-sensitive and empty values are not scanned for",
+			args: []string{"run", "-e", "LOG_LEVEL=debug", "image:latest", "debug"},
+			env: map[string]EnvVar{
+				"LOG_LEVEL": {Value: "debug", Sensitive: false},
+				"EMPTY":     {Value: "", Sensitive: true},
+			},
+			expected: []string{"run", "-e", "LOG_LEVEL=debug", "image:latest", "debug"},
+		},
+		{
+			name: "secret containing another secret is redacted whole",
+			args: []string{"run", "image:latest", "pass-extended"},
+			env: map[string]EnvVar{
+				"SHORT": {Value: "pass", Sensitive: true},
+				"LONG":  {Value: "pass-extended", Sensitive: true},
+			},
+			expected: []string{"run", "image:latest", "***REDACTED***"},
+		},
+		{
+			name: "short secret is only redacted as a whole arg",
+			args: []string{"run", "--restart", "unless-stopped", "-e", "TWS_USERID=u", "image:latest", "--user", "u"},
+			env: map[string]EnvVar{
+				"TWS_USERID": {Value: "u", Sensitive: true},
+			},
+			expected: []string{"run", "--restart", "unless-stopped", "-e", "TWS_USERID=***REDACTED***", "image:latest", "--user", "***REDACTED***"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -128,3 +223,656 @@ func TestSanitizeDockerArgsDoesNotModifyOriginal(t *testing.T) {
 		t.Errorf("sanitizeDockerArgs modified the original slice.\nOriginal: %v\nAfter: %v", originalCopy, original)
 	}
 }
+
+func TestParseEnvAssignments(t *testing.T) {
+	env, err := parseEnvAssignments([]string{"TWS_ACCEPT_INCOMING=accept", "VNC_SERVER_PASSWORD=hunter2", "EXTRA=a=b"})
+	if err != nil {
+		t.Fatalf("parseEnvAssignments() unexpected error: %v", err)
+	}
+
+	expected := map[string]EnvVar{
+		"TWS_ACCEPT_INCOMING": {Value: "accept", Sensitive: false},
+		"VNC_SERVER_PASSWORD": {Value: "hunter2", Sensitive: true},
+		"EXTRA":               {Value: "a=b", Sensitive: false},
+	}
+	if !reflect.DeepEqual(env, expected) {
+		t.Errorf("parseEnvAssignments() =\n%v\nexpected\n%v", env, expected)
+	}
+
+	for _, invalid := range []string{"NOVALUE", "=value"} {
+		if _, err := parseEnvAssignments([]string{invalid}); err == nil {
+			t.Errorf("parseEnvAssignments(%q) expected error", invalid)
+		}
+	}
+}
+
+func TestParseCopyPath(t *testing.T) {
+	tests := []struct {
+		arg             string
+		wantContainer   string
+		wantPath        string
+		wantIsContainer bool
+	}{
+		{arg: "ibgateway:/home/ibgateway/Jts", wantContainer: "ibgateway", wantPath: "/home/ibgateway/Jts", wantIsContainer: true},
+		{arg: "./logs", wantPath: "./logs"},
+		{arg: "/tmp/a:b", wantPath: "/tmp/a:b"},
+		{arg: ":/path", wantPath: ":/path"},
+		{arg: "relative", wantPath: "relative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			container, path, isContainer := parseCopyPath(tt.arg)
+			if container != tt.wantContainer || path != tt.wantPath || isContainer != tt.wantIsContainer {
+				t.Errorf("parseCopyPath(%q) = (%q, %q, %v), expected (%q, %q, %v)",
+					tt.arg, container, path, isContainer, tt.wantContainer, tt.wantPath, tt.wantIsContainer)
+			}
+		})
+	}
+}
+
+func TestDiffDaemonConfig(t *testing.T) {
+	var existing containerInspect
+	existing.Config.Image = "ghcr.io/gnzsnz/ib-gateway:stable"
+	existing.Config.Env = []string{"TWS_USERID=alice", "TRADING_MODE=paper", "PATH=/usr/bin"}
+	existing.HostConfig.PortBindings = map[string][]struct {
+		HostPort string `json:"HostPort"`
+	}{
+		"4003/tcp": {{HostPort: "4001"}},
+		"4004/tcp": {{HostPort: "4002"}},
+	}
+
+	ports := map[string]string{"4001": "4003", "5002": "4004"}
+	env := map[string]EnvVar{
+		"TWS_USERID":   {Value: "alice", Sensitive: true},
+		"TWS_PASSWORD": {Value: "secret", Sensitive: true},
+		"TRADING_MODE": {Value: "live", Sensitive: false},
+	}
+
+	expected := []string{
+		"image: ghcr.io/gnzsnz/ib-gateway:stable → ghcr.io/gnzsnz/ib-gateway:latest",
+		"port added: 5002:4004",
+		"port removed: 4002:4004",
+		"env added: TWS_PASSWORD",
+		"env changed: TRADING_MODE",
+	}
+
+	changes := diffDaemonConfig(existing, "ghcr.io/gnzsnz/ib-gateway:latest", ports, env)
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("diffDaemonConfig() =\n%v\nexpected\n%v", changes, expected)
+	}
+}
+
+func TestParseContainerDiff(t *testing.T) {
+	output := "C /home\nC /home/node\nA /home/node/.config/Bitwarden CLI/data.json\nD /etc/motd\n\n"
+
+	expected := map[string][]string{
+		"Changed": {"/home", "/home/node"},
+		"Added":   {"/home/node/.config/Bitwarden CLI/data.json"},
+		"Deleted": {"/etc/motd"},
+	}
+
+	changes := parseContainerDiff(output)
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("parseContainerDiff() =\n%v\nexpected\n%v", changes, expected)
+	}
+}
+
+func TestIsTmpfsError(t *testing.T) {
+	engineFailure := exec.Command("sh", "-c", "exit 125").Run()
+	appFailure := exec.Command("sh", "-c", "exit 2").Run()
+
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "tmpfs option rejected by engine",
+			err:      &RunError{Err: engineFailure, Stderr: "Error: invalid tmpfs option [\"noexec\"]"},
+			expected: true,
+		},
+		{
+			name:     "other engine failure",
+			err:      &RunError{Err: engineFailure, Stderr: "Error: no such image"},
+			expected: false,
+		},
+		{
+			name:     "container exit mentioning tmpfs",
+			err:      &RunError{Err: appFailure, Stderr: "writing to tmpfs failed"},
+			expected: false,
+		},
+		{
+			name:     "not a run error",
+			err:      errors.New("tmpfs"),
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := isTmpfsError(tt.err); result != tt.expected {
+				t.Errorf("isTmpfsError() = %v, expected %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEngineCommandArgs(t *testing.T) {
+	saved := runtimeSettings.EngineArgs
+	defer func() { runtimeSettings.EngineArgs = saved }()
+
+	runtimeSettings.EngineArgs = nil
+	if got := engineCommandArgs([]string{"ps"}); !reflect.DeepEqual(got, []string{"ps"}) {
+		t.Errorf("engineCommandArgs without engine args = %v", got)
+	}
+
+	runtimeSettings.EngineArgs = []string{"--config=/etc/docker-alt"}
+	want := []string{"--config=/etc/docker-alt", "run", "--rm"}
+	if got := engineCommandArgs([]string{"run", "--rm"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("engineCommandArgs() = %v, want %v", got, want)
+	}
+
+	if err := validateEngineArgs([]string{"--config=/x", "-D"}); err != nil {
+		t.Errorf("validateEngineArgs() unexpected error: %v", err)
+	}
+	if err := validateEngineArgs([]string{"/x"}); err == nil {
+		t.Error("validateEngineArgs() expected error for argument without leading '-'")
+	}
+}
+
+// fakeDockerScript records its arguments and, for `run`, writes a container ID to --cidfile and blocks
+const fakeDockerScript = `#!/bin/sh
+echo "$@" >> "$FAKE_DOCKER_LOG"
+if [ "$1" = "run" ]; then
+	while [ $# -gt 0 ]; do
+		if [ "$1" = "--cidfile" ]; then echo fakecid123 > "$2"; fi
+		shift
+	done
+	exec sleep 10
+fi
+`
+
+// fakeDocker installs script as the docker CLI at the front of PATH and returns the $FAKE_DOCKER_LOG path
+func fakeDocker(t *testing.T, script string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "docker"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	logPath := filepath.Join(dir, "log")
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("FAKE_DOCKER_LOG", logPath)
+	return logPath
+}
+
+// stubEngineProbe makes the daemon probe report problem ("" for a healthy engine) for the rest of the test
+func stubEngineProbe(t *testing.T, problem string) {
+	t.Helper()
+	savedProbe := probeEngineDaemon
+	t.Cleanup(func() {
+		probeEngineDaemon = savedProbe
+		daemonProbeOnce = sync.Once{}
+		daemonProblem = ""
+	})
+	daemonProbeOnce = sync.Once{}
+	daemonProblem = ""
+	probeEngineDaemon = func() string { return problem }
+}
+
+func TestDockerCommandContext(t *testing.T) {
+	fakeDocker(t, fakeDockerScript)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := dockerCommandContext(ctx, "version").Run(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() with a cancelled context = %v, expected context.Canceled", err)
+	}
+	if err := dockerCommandContext(context.Background(), "version").Run(); err != nil {
+		t.Errorf("Run() with a live context unexpected error: %v", err)
+	}
+}
+
+func TestRunContainerStopsOnCancel(t *testing.T) {
+	stubEngineProbe(t, "")
+
+	dir := t.TempDir()
+	logPath := fakeDocker(t, fakeDockerScript)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(500*time.Millisecond, cancel)
+	err := RunContainer(ctx, "ghcr.io/example/tool:latest", dir, nil, ContainerOptions{Remove: true})
+	if !isInterrupted(err) {
+		t.Fatalf("RunContainer() error = %v, expected an interrupted run", err)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "stop fakecid123") {
+		t.Errorf("container was not stopped; engine calls:\n%s", data)
+	}
+}
+
+func TestRunTimeoutRemovesContainer(t *testing.T) {
+	stubEngineProbe(t, "")
+	savedTimeout := runtimeSettings.Timeout
+	defer func() { runtimeSettings.Timeout = savedTimeout }()
+	runtimeSettings.Timeout = 300 * time.Millisecond
+
+	dir := t.TempDir()
+	logPath := fakeDocker(t, fakeDockerScript)
+
+	err := RunContainer(context.Background(), "ghcr.io/example/tool:latest", dir, nil, ContainerOptions{})
+	if !errors.Is(err, ErrTimeout) || isInterrupted(err) {
+		t.Fatalf("RunContainer() error = %v, expected a timeout", err)
+	}
+	if err := RunDaemon("ibgateway", "ghcr.io/example/tool:latest", nil, nil, DaemonOptions{}); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("RunDaemon() error = %v, expected a timeout", err)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, call := range []string{"stop fakecid123", "rm -f fakecid123", "rm -f ibgateway"} {
+		if !strings.Contains(string(data), call) {
+			t.Errorf("engine calls missing %q:\n%s", call, data)
+		}
+	}
+}
+
+func TestIsTransientRunError(t *testing.T) {
+	engineFailure := exec.Command("sh", "-c", "exit 125").Run()
+	appFailure := exec.Command("sh", "-c", "exit 1").Run()
+
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "pull timeout", err: &RunError{Err: engineFailure, Stderr: "docker: Error response from daemon: Get \"https://ghcr.io/v2/\": net/http: TLS handshake timeout."}, expected: true},
+		{name: "rate limited", err: &RunError{Err: engineFailure, Stderr: "toomanyrequests: retry later"}, expected: true},
+		{name: "unknown image", err: &RunError{Err: engineFailure, Stderr: "manifest unknown"}},
+		{name: "app exit", err: &RunError{Err: appFailure, Stderr: "i/o timeout"}},
+		{name: "other error", err: errors.New("i/o timeout")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransientRunError(tt.err); got != tt.expected {
+				t.Errorf("isTransientRunError() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	expected := []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 30 * time.Second, 30 * time.Second}
+	for i, want := range expected {
+		if got := retryBackoff(i + 1); got != want {
+			t.Errorf("retryBackoff(%d) = %s, expected %s", i+1, got, want)
+		}
+	}
+}
+
+func TestRunContainerRetriesTransientFailures(t *testing.T) {
+	stubEngineProbe(t, "")
+	savedRetries, savedDelay := runtimeSettings.Retries, retryBaseDelay
+	defer func() { runtimeSettings.Retries, retryBaseDelay = savedRetries, savedDelay }()
+	retryBaseDelay = time.Millisecond
+
+	// Fails with a pull error until the attempt count in $FAKE_DOCKER_LOG reaches $FAKE_DOCKER_OK_AFTER
+	dir := t.TempDir()
+	script := `#!/bin/sh
+echo "$@" >> "$FAKE_DOCKER_LOG"
+if [ "$1" = "run" ]; then
+	if [ "$(grep -c '^run' "$FAKE_DOCKER_LOG")" -lt "$FAKE_DOCKER_OK_AFTER" ]; then
+		echo "docker: error pulling image: i/o timeout" >&2
+		exit 125
+	fi
+	exit "$FAKE_DOCKER_EXIT"
+fi
+`
+	fakeDocker(t, script)
+
+	tests := []struct {
+		name      string
+		retries   int
+		okAfter   string
+		exit      string
+		expectErr bool
+		runs      int
+	}{
+		{name: "no retries by default", okAfter: "2", exit: "0", expectErr: true, runs: 1},
+		{name: "succeeds on retry", retries: 3, okAfter: "3", exit: "0", runs: 3},
+		{name: "gives up", retries: 1, okAfter: "5", exit: "0", expectErr: true, runs: 2},
+		{name: "app failure is not retried", retries: 3, okAfter: "1", exit: "1", expectErr: true, runs: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logPath := filepath.Join(t.TempDir(), "log")
+			t.Setenv("FAKE_DOCKER_LOG", logPath)
+			t.Setenv("FAKE_DOCKER_OK_AFTER", tt.okAfter)
+			t.Setenv("FAKE_DOCKER_EXIT", tt.exit)
+			runtimeSettings.Retries = tt.retries
+
+			err := RunContainer(context.Background(), "ghcr.io/example/tool:latest", dir, nil, ContainerOptions{Remove: true})
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("RunContainer() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			data, err := os.ReadFile(logPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if runs := strings.Count(string(data), "run "); runs != tt.runs {
+				t.Errorf("docker run called %d times, expected %d", runs, tt.runs)
+			}
+		})
+	}
+}
+
+func TestRunContainerHardeningFlags(t *testing.T) {
+	stubEngineProbe(t, "")
+
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho \"$@\" >> \"$FAKE_DOCKER_LOG\"\n"
+	fakeDocker(t, script)
+
+	tests := []struct {
+		name     string
+		opts     ContainerOptions
+		expected []string
+		absent   []string
+	}{
+		{name: "defaults", absent: []string{"--read-only", "--security-opt"}},
+		{name: "read-only", opts: ContainerOptions{ReadOnly: true}, expected: []string{" --read-only "}, absent: []string{"--security-opt"}},
+		{name: "no-new-privileges", opts: ContainerOptions{NoNewPrivs: true}, expected: []string{" --security-opt no-new-privileges "}, absent: []string{"--read-only"}},
+		{name: "both", opts: ContainerOptions{ReadOnly: true, NoNewPrivs: true}, expected: []string{" --read-only ", " --security-opt no-new-privileges "}},
+		{name: "network none", opts: ContainerOptions{Network: "none"}, expected: []string{" --network none "}},
+		{name: "default network", absent: []string{"--network"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logPath := filepath.Join(t.TempDir(), "log")
+			t.Setenv("FAKE_DOCKER_LOG", logPath)
+			if err := RunContainer(context.Background(), "ghcr.io/example/tool:latest", dir, nil, tt.opts); err != nil {
+				t.Fatalf("RunContainer() unexpected error: %v", err)
+			}
+			data, err := os.ReadFile(logPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.expected {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("engine args missing %q:\n%s", want, data)
+				}
+			}
+			for _, unwanted := range tt.absent {
+				if strings.Contains(string(data), unwanted) {
+					t.Errorf("engine args unexpectedly contain %q:\n%s", unwanted, data)
+				}
+			}
+		})
+	}
+}
+
+func TestCapabilityArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		drop      []string
+		add       []string
+		expected  []string
+		expectErr bool
+	}{
+		{name: "none"},
+		{name: "drop all, add back", drop: []string{"ALL"}, add: []string{"chown", "CAP_NET_BIND_SERVICE"}, expected: []string{"--cap-drop", "ALL", "--cap-add", "CHOWN", "--cap-add", "NET_BIND_SERVICE"}},
+		{name: "drop is always first", drop: []string{"net_raw"}, add: []string{"SYS_TIME"}, expected: []string{"--cap-drop", "NET_RAW", "--cap-add", "SYS_TIME"}},
+		{name: "typo", drop: []string{"ALL"}, add: []string{"NET_BIND_SERIVCE"}, expectErr: true},
+		{name: "empty name", drop: []string{""}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := capabilityArgs(tt.drop, tt.add)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("capabilityArgs() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if strings.Join(got, " ") != strings.Join(tt.expected, " ") {
+				t.Errorf("capabilityArgs() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidateNetwork(t *testing.T) {
+	tests := []struct {
+		network   string
+		expectErr bool
+	}{
+		{network: "none"},
+		{network: "bridge"},
+		{network: "host"},
+		{network: "my_net.1"},
+		{network: "container:ibgateway"},
+		{network: "container:", expectErr: true},
+		{network: "--privileged", expectErr: true},
+		{network: "none --privileged", expectErr: true},
+		{network: "bridge,alias=x", expectErr: true},
+	}
+	for _, tt := range tests {
+		if err := validateNetwork(tt.network); (err != nil) != tt.expectErr {
+			t.Errorf("validateNetwork(%q) error = %v, expectErr %v", tt.network, err, tt.expectErr)
+		}
+	}
+}
+
+func TestValidateUserSpec(t *testing.T) {
+	tests := []struct {
+		user      string
+		expectErr bool
+	}{
+		{user: "1000"},
+		{user: "1000:1000"},
+		{user: "node:staff"},
+		{user: "", expectErr: true},
+		{user: ":1000", expectErr: true},
+		{user: "1000:", expectErr: true},
+		{user: "--privileged", expectErr: true},
+		{user: "1000 1000", expectErr: true},
+	}
+	for _, tt := range tests {
+		if err := validateUserSpec(tt.user); (err != nil) != tt.expectErr {
+			t.Errorf("validateUserSpec(%q) error = %v, expectErr %v", tt.user, err, tt.expectErr)
+		}
+	}
+}
+
+func TestDryRunDoesNotExecute(t *testing.T) {
+	dir := t.TempDir()
+	logPath := fakeDocker(t, fakeDockerScript)
+
+	runtimeSettings.DryRun = true
+	defer func() { runtimeSettings.DryRun = false }()
+
+	env := map[string]EnvVar{"API_TOKEN": {Value: "secret", Sensitive: true}}
+	if err := RunContainer(context.Background(), "ghcr.io/example/tool:latest", dir, nil, ContainerOptions{Env: env}); err != nil {
+		t.Errorf("RunContainer() dry run unexpected error: %v", err)
+	}
+	if err := RunDaemon("daemon", "ghcr.io/example/tool:latest", nil, env, DaemonOptions{}); err != nil {
+		t.Errorf("RunDaemon() dry run unexpected error: %v", err)
+	}
+	if _, err := os.Stat(logPath); !os.IsNotExist(err) {
+		data, _ := os.ReadFile(logPath)
+		t.Errorf("dry run invoked the engine:\n%s", data)
+	}
+}
+
+func TestValidateMount(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		mount     string
+		expectErr bool
+	}{
+		{mount: dir + ":/output"},
+		{mount: dir + ":/pdfa:ro"},
+		{mount: dir + ":/data:rw"},
+		{mount: dir, expectErr: true},
+		{mount: dir + ":", expectErr: true},
+		{mount: dir + ":/a:/b", expectErr: true},
+		{mount: dir + ":/a:readonly", expectErr: true},
+		{mount: "relative:/output", expectErr: true},
+		{mount: dir + ":relative", expectErr: true},
+		{mount: filepath.Join(dir, "missing") + ":/output", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mount, func(t *testing.T) {
+			err := validateMount(tt.mount)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("validateMount(%q) error = %v, expected error: %v", tt.mount, err, tt.expectErr)
+			}
+		})
+	}
+}
+
+func TestResourceLimits(t *testing.T) {
+	tests := []struct {
+		name      string
+		limits    ResourceLimits
+		expected  []string
+		expectErr bool
+	}{
+		{name: "empty omits flags", limits: ResourceLimits{}, expected: nil},
+		{name: "memory and cpus", limits: ResourceLimits{Memory: "2g", CPUs: "1.5"}, expected: []string{"--memory", "2g", "--cpus", "1.5"}},
+		{name: "memory only", limits: ResourceLimits{Memory: "512m"}, expected: []string{"--memory", "512m"}},
+		{name: "invalid memory", limits: ResourceLimits{Memory: "abc"}, expectErr: true},
+		{name: "memory below minimum", limits: ResourceLimits{Memory: "1m"}, expectErr: true},
+		{name: "invalid cpus", limits: ResourceLimits{CPUs: "two"}, expectErr: true},
+		{name: "zero cpus", limits: ResourceLimits{CPUs: "0"}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.limits.Validate()
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("Validate() expected error for %+v", tt.limits)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() unexpected error: %v", err)
+			}
+			if args := tt.limits.args(); !reflect.DeepEqual(args, tt.expected) {
+				t.Errorf("args() = %v, expected %v", args, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPullArgs(t *testing.T) {
+	script := "#!/bin/sh\n[ \"$1 $2\" = \"image inspect\" ] && [ \"$5\" = \"ghcr.io/example/present:latest\" ]\n"
+	fakeDocker(t, script)
+	defer func() { runtimeSettings.PullPolicy = "" }()
+
+	tests := []struct {
+		policy    string
+		image     string
+		expected  []string
+		expectErr bool
+	}{
+		{policy: "", image: "ghcr.io/example/missing:latest"},
+		{policy: "always", image: "ghcr.io/example/missing:latest", expected: []string{"--pull", "always"}},
+		{policy: "missing", image: "ghcr.io/example/missing:latest", expected: []string{"--pull", "missing"}},
+		{policy: "never", image: "ghcr.io/example/present:latest", expected: []string{"--pull", "never"}},
+		{policy: "never", image: "ghcr.io/example/missing:latest", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		runtimeSettings.PullPolicy = tt.policy
+		got, err := pullArgs(tt.image)
+		if (err != nil) != tt.expectErr {
+			t.Errorf("pullArgs(%q) with %q error = %v, expectErr %v", tt.image, tt.policy, err, tt.expectErr)
+			continue
+		}
+		if strings.Join(got, " ") != strings.Join(tt.expected, " ") {
+			t.Errorf("pullArgs(%q) with %q = %v, expected %v", tt.image, tt.policy, got, tt.expected)
+		}
+	}
+
+	if err := validatePullPolicy("sometimes"); err == nil {
+		t.Error("validatePullPolicy(sometimes) expected error")
+	}
+}
+
+func TestParseContainerList(t *testing.T) {
+	output := "ibgateway-paper\texited\tExited (0) 3 hours ago\t\n" +
+		"ibgateway\trunning\tUp 2 hours\t0.0.0.0:4001->4003/tcp, 0.0.0.0:4002->4004/tcp\n" +
+		"other\trunning\tUp 5 minutes\t\n"
+
+	tests := []struct {
+		name     string
+		expected *containerInfo
+	}{
+		{name: "ibgateway", expected: &containerInfo{Name: "ibgateway", State: "running", Status: "Up 2 hours", Ports: "0.0.0.0:4001->4003/tcp, 0.0.0.0:4002->4004/tcp"}},
+		{name: "ibgateway-paper", expected: &containerInfo{Name: "ibgateway-paper", State: "exited", Status: "Exited (0) 3 hours ago"}},
+		{name: "ibgate"},
+		{name: ""},
+	}
+
+	for _, tt := range tests {
+		got := parseContainerList(output, tt.name)
+		if (got == nil) != (tt.expected == nil) || (got != nil && *got != *tt.expected) {
+			t.Errorf("parseContainerList(%q) = %+v, expected %+v", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestRunDaemonRemovesExistingContainer(t *testing.T) {
+	stubEngineProbe(t, "")
+
+	script := "#!/bin/sh\necho \"$@\" >> \"$FAKE_DOCKER_LOG\"\n" +
+		"if [ \"$1\" = \"ps\" ]; then printf 'other\\nibgateway\\nibgateway-live\\n'; fi\n"
+	logPath := fakeDocker(t, script)
+
+	if err := RunDaemon("ibgateway", "ghcr.io/example/tool:latest", nil, nil, DaemonOptions{}); err != nil {
+		t.Fatalf("RunDaemon() unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "rm -f ibgateway\n") {
+		t.Errorf("RunDaemon() did not remove the existing container, engine calls:\n%s", data)
+	}
+}
+
+func TestContainerLogs(t *testing.T) {
+	script := "#!/bin/sh\necho \"$@\" >> \"$FAKE_DOCKER_LOG\"\n" +
+		"if [ \"$1\" = \"ps\" ]; then echo ibgateway; fi\n"
+	logPath := fakeDocker(t, script)
+
+	if err := ContainerLogs(context.Background(), "ibgateway", "50", true); err != nil {
+		t.Fatalf("ContainerLogs() unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "logs --tail 50 -f ibgateway\n") {
+		t.Errorf("ContainerLogs() engine calls:\n%s", data)
+	}
+
+	if err := ContainerLogs(context.Background(), "missing", "50", false); err == nil {
+		t.Error("ContainerLogs() expected error for a missing container")
+	}
+	for _, tail := range []string{"-1", "ten", ""} {
+		if err := ContainerLogs(context.Background(), "ibgateway", tail, false); err == nil {
+			t.Errorf("ContainerLogs() expected error for --tail %q", tail)
+		}
+	}
+}
@@ -38,29 +38,55 @@ fi
 
 TIMESTAMP=$(date -u +'%Y-%m-%d-%H%M%S')
 
-# Determine file extension and format based on backup password
-if [ -n "${BW_BACKUP_PASSWORD:-}" ]; then
-    FILE_EXT="encrypted.json"
+# Determine export format (BW_EXPORT_FORMAT overrides the password-based default)
+if [ -n "${BW_EXPORT_FORMAT:-}" ]; then
+    EXPORT_FORMAT="${BW_EXPORT_FORMAT}"
+elif [ -n "${BW_BACKUP_PASSWORD:-}" ]; then
     EXPORT_FORMAT="encrypted_json"
 else
-    FILE_EXT="json"
     EXPORT_FORMAT="json"
 fi
 
-# Generate backup filename based on profile and organization
-if [ -n "${BW_ORGANIZATIONID:-}" ]; then
-    # Organization backup with profile
-    if [ -n "${BW_PROFILE:-}" ]; then
-        BACKUP_FILENAME="bitwarden-${BW_PROFILE}-org-${BW_ORGANIZATIONID}-backup-${TIMESTAMP}.${FILE_EXT}"
-    else
-        BACKUP_FILENAME="bitwarden-org-${BW_ORGANIZATIONID}-backup-${TIMESTAMP}.${FILE_EXT}"
-    fi
+case "${EXPORT_FORMAT}" in
+    encrypted_json) FILE_EXT="encrypted.json" ;;
+    json) FILE_EXT="json" ;;
+    csv) FILE_EXT="csv" ;;
+    *)
+        log "ERROR: Unsupported export format: ${EXPORT_FORMAT}"
+        exit 1
+        ;;
+esac
+
+if [ "${EXPORT_FORMAT}" = "encrypted_json" ] && [ -z "${BW_BACKUP_PASSWORD:-}" ]; then
+    log "ERROR: encrypted_json export requires BW_BACKUP_PASSWORD"
+    exit 1
+fi
+
+# Use the filename chosen by the containers CLI so it can verify the file afterwards;
+# otherwise generate it based on profile and organization
+if [ -n "${BW_BACKUP_FILENAME:-}" ]; then
+    case "${BW_BACKUP_FILENAME}" in
+        */*|.*)
+            log "ERROR: BW_BACKUP_FILENAME must be a plain file name: ${BW_BACKUP_FILENAME}"
+            exit 1
+            ;;
+    esac
+    BACKUP_FILENAME="${BW_BACKUP_FILENAME}"
 else
-    # Personal vault backup
-    if [ -n "${BW_PROFILE:-}" ]; then
-        BACKUP_FILENAME="bitwarden-${BW_PROFILE}-backup-${TIMESTAMP}.${FILE_EXT}"
+    if [ -n "${BW_ORGANIZATIONID:-}" ]; then
+        # Organization backup with profile
+        if [ -n "${BW_PROFILE:-}" ]; then
+            BACKUP_FILENAME="bitwarden-${BW_PROFILE}-org-${BW_ORGANIZATIONID}-backup-${TIMESTAMP}.${FILE_EXT}"
+        else
+            BACKUP_FILENAME="bitwarden-org-${BW_ORGANIZATIONID}-backup-${TIMESTAMP}.${FILE_EXT}"
+        fi
     else
-        BACKUP_FILENAME="bitwarden-backup-${TIMESTAMP}.${FILE_EXT}"
+        # Personal vault backup
+        if [ -n "${BW_PROFILE:-}" ]; then
+            BACKUP_FILENAME="bitwarden-${BW_PROFILE}-backup-${TIMESTAMP}.${FILE_EXT}"
+        else
+            BACKUP_FILENAME="bitwarden-backup-${TIMESTAMP}.${FILE_EXT}"
+        fi
     fi
 fi
 
@@ -72,65 +98,30 @@ chmod 0400 "${BACKUP_PATH}"
 
 log "Backup will be saved to: ${BACKUP_PATH}"
 
-# Step 3: Check status and login only if unauthenticated
-STATUS=$(bw status| jq -r '.status')
-log "Current Bitwarden status: ${STATUS}"
-
-if [ "$STATUS" = "unauthenticated" ]; then
-    log "Logging in to Bitwarden..."
-    if ! bw login --apikey 2>&1; then
-        log "ERROR: Failed to login to Bitwarden"
-        exit 1
-    fi
-fi
-
-# Step 4: Unlock vault and export session
-log "Unlocking Bitwarden vault..."
-if ! BW_SESSION=$(bw unlock --passwordenv BW_PASSWORD --raw); then
-    log "ERROR: Failed to unlock Bitwarden vault"
-    exit 1
-fi
+# Steps 3-4: Log in and unlock the vault (exports BW_SESSION)
+source /app/session.sh
+unlock_session
 
-# Verify session token was actually returned
-if [ -z "$BW_SESSION" ]; then
-    log "ERROR: Unlock succeeded but session token is empty. Check password or account settings."
-    exit 1
+# Step 5: Export vault (pipe password to handle CLI bug where it prompts despite valid session)
+EXPORT_ARGS=(--format "${EXPORT_FORMAT}" --output "${BACKUP_PATH}")
+if [ "${EXPORT_FORMAT}" = "encrypted_json" ]; then
+    log "Using encrypted JSON export with password protection"
+    EXPORT_ARGS+=(--password "${BW_BACKUP_PASSWORD}")
+else
+    log "Using unencrypted ${EXPORT_FORMAT} export (will be stored on encrypted drive)"
 fi
 
-# Export BW_SESSION for bw export command to use
-export BW_SESSION
-log "Session unlocked and exported (length: ${#BW_SESSION})"
-
-# Step 5: Export vault (pipe password to handle CLI bug where it prompts despite valid session)
 if [ -n "${BW_ORGANIZATIONID:-}" ]; then
     log "Exporting organization vault (ID: ${BW_ORGANIZATIONID}) to ${BACKUP_FILENAME}..."
-    if [ -n "${BW_BACKUP_PASSWORD:-}" ]; then
-        log "Using encrypted JSON export with password protection"
-        if ! echo "${BW_PASSWORD}" | bw export --organizationid "${BW_ORGANIZATIONID}" --format encrypted_json --password "${BW_BACKUP_PASSWORD}" --output "${BACKUP_PATH}"; then
-            log "ERROR: Failed to export organization vault"
-            exit 2
-        fi
-    else
-        log "Using unencrypted JSON export (will be stored on encrypted drive)"
-        if ! echo "${BW_PASSWORD}" | bw export --organizationid "${BW_ORGANIZATIONID}" --format json --output "${BACKUP_PATH}"; then
-            log "ERROR: Failed to export organization vault"
-            exit 2
-        fi
+    if ! echo "${BW_PASSWORD}" | bw export --organizationid "${BW_ORGANIZATIONID}" "${EXPORT_ARGS[@]}"; then
+        log "ERROR: Failed to export organization vault"
+        exit 2
     fi
 else
     log "Exporting personal vault to ${BACKUP_FILENAME}..."
-    if [ -n "${BW_BACKUP_PASSWORD:-}" ]; then
-        log "Using encrypted JSON export with password protection"
-        if ! echo "${BW_PASSWORD}" | bw export --format encrypted_json --password "${BW_BACKUP_PASSWORD}" --output "${BACKUP_PATH}"; then
-            log "ERROR: Failed to export personal vault"
-            exit 2
-        fi
-    else
-        log "Using unencrypted JSON export (will be stored on encrypted drive)"
-        if ! echo "${BW_PASSWORD}" | bw export --format json --output "${BACKUP_PATH}"; then
-            log "ERROR: Failed to export personal vault"
-            exit 2
-        fi
+    if ! echo "${BW_PASSWORD}" | bw export "${EXPORT_ARGS[@]}"; then
+        log "ERROR: Failed to export personal vault"
+        exit 2
     fi
 fi
 
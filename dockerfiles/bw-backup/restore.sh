@@ -0,0 +1,73 @@
+#!/bin/bash
+set -euo pipefail
+
+# Logging function
+log() {
+    echo "[$(date +'%Y-%m-%d %H:%M:%S UTC')] $1"
+}
+
+log "Starting Bitwarden restore process..."
+
+# Step 1: Validate credentials from environment
+if [ -z "${BW_CLIENTID:-}" ] || [ -z "${BW_CLIENTSECRET:-}" ] || [ -z "${BW_PASSWORD:-}" ]; then
+    log "ERROR: Missing credentials. Provide BW_CLIENTID, BW_CLIENTSECRET, BW_PASSWORD via environment."
+    exit 1
+fi
+
+# Cleanup function to unset credentials
+cleanup_credentials() {
+    unset BW_CLIENTID BW_CLIENTSECRET BW_PASSWORD BW_SESSION BW_BACKUP_PASSWORD
+    log "Credentials cleared from memory"
+}
+
+# Register cleanup to run on exit, interrupt, or termination
+trap cleanup_credentials EXIT INT TERM
+
+log "Profile: ${BW_PROFILE:-<not set>}"
+log "Organization ID: ${BW_ORGANIZATIONID:-<not set>}"
+
+# Step 2: Locate the backup file (its directory is mounted to /workspace by containers CLI)
+if [ -z "${BW_RESTORE_FILE:-}" ]; then
+    log "ERROR: BW_RESTORE_FILE is not set."
+    exit 1
+fi
+RESTORE_PATH="/workspace/${BW_RESTORE_FILE}"
+if [ ! -r "${RESTORE_PATH}" ]; then
+    log "ERROR: Backup file ${RESTORE_PATH} does not exist or is not readable."
+    exit 1
+fi
+
+case "${BW_RESTORE_FORMAT:-bitwardenjson}" in
+    bitwardenjson|bitwardencsv) IMPORT_FORMAT="${BW_RESTORE_FORMAT:-bitwardenjson}" ;;
+    *)
+        log "ERROR: Unsupported import format: ${BW_RESTORE_FORMAT}"
+        exit 1
+        ;;
+esac
+
+# Steps 3-4: Log in and unlock the vault (exports BW_SESSION)
+source /app/session.sh
+unlock_session
+
+# Step 5: Import the backup. Password-protected exports prompt for their password, which is piped in.
+IMPORT_ARGS=("${IMPORT_FORMAT}" "${RESTORE_PATH}")
+if [ -n "${BW_ORGANIZATIONID:-}" ]; then
+    IMPORT_ARGS+=(--organizationid "${BW_ORGANIZATIONID}")
+    log "Importing ${BW_RESTORE_FILE} into organization vault (ID: ${BW_ORGANIZATIONID})..."
+else
+    log "Importing ${BW_RESTORE_FILE} into personal vault..."
+fi
+
+if ! echo "${BW_BACKUP_PASSWORD:-}" | bw import "${IMPORT_ARGS[@]}"; then
+    log "ERROR: Failed to import backup"
+    exit 2
+fi
+
+# Step 6: Lock vault (keep session for next run)
+log "Locking Bitwarden vault..."
+bw lock || true
+
+unset BW_SESSION
+
+log "Restore process completed successfully!"
+exit 0
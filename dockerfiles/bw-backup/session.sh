@@ -0,0 +1,67 @@
+#!/bin/bash
+# Shared Bitwarden login/unlock for backup.sh and restore.sh; callers define log().
+
+# unlock_session configures the server, logs in if needed and exports BW_SESSION.
+# Exits 3 when a cached session fails to unlock so the containers CLI can retry with BW_FORCE_LOGIN=1.
+unlock_session() {
+    # Point the CLI at a self-hosted server if requested. The server can only be changed while
+    # logged out, so a session for a different server is dropped first.
+    if [ -n "${BW_SERVER:-}" ]; then
+        CURRENT_SERVER=$(bw config server 2>/dev/null || true)
+        if [ "${CURRENT_SERVER}" != "${BW_SERVER}" ]; then
+            if [ "$(bw status | jq -r '.status')" != "unauthenticated" ]; then
+                log "Logging out of ${CURRENT_SERVER} to switch servers..."
+                bw logout >/dev/null 2>&1 || true
+            fi
+            log "Using Bitwarden server: ${BW_SERVER}"
+            if ! bw config server "${BW_SERVER}" >/dev/null; then
+                log "ERROR: Failed to configure Bitwarden server ${BW_SERVER}"
+                exit 1
+            fi
+        fi
+    fi
+
+    # Check status and login only if unauthenticated
+    local STATUS FRESH_LOGIN
+    STATUS=$(bw status| jq -r '.status')
+    log "Current Bitwarden status: ${STATUS}"
+
+    # BW_FORCE_LOGIN discards the cached session (set by the containers CLI when retrying an expired one)
+    if [ "${BW_FORCE_LOGIN:-}" = "1" ] && [ "$STATUS" != "unauthenticated" ]; then
+        log "Forcing fresh login: logging out of cached session..."
+        bw logout >/dev/null 2>&1 || true
+        STATUS="unauthenticated"
+    fi
+
+    FRESH_LOGIN=0
+    if [ "$STATUS" = "unauthenticated" ]; then
+        log "Logging in to Bitwarden..."
+        if ! bw login --apikey 2>&1; then
+            log "ERROR: Failed to login to Bitwarden"
+            exit 1
+        fi
+        FRESH_LOGIN=1
+    fi
+
+    # Unlock vault and export session
+    log "Unlocking Bitwarden vault..."
+    if ! BW_SESSION=$(bw unlock --passwordenv BW_PASSWORD --raw); then
+        if [ "$FRESH_LOGIN" = "0" ]; then
+            # Exit code 3 marks a likely expired/invalid cached session; the CLI retries with a fresh login
+            log "ERROR: Failed to unlock Bitwarden vault with cached session (session may be expired)"
+            exit 3
+        fi
+        log "ERROR: Failed to unlock Bitwarden vault"
+        exit 1
+    fi
+
+    # Verify session token was actually returned
+    if [ -z "$BW_SESSION" ]; then
+        log "ERROR: Unlock succeeded but session token is empty. Check password or account settings."
+        exit 1
+    fi
+
+    # Export BW_SESSION for later bw commands to use
+    export BW_SESSION
+    log "Session unlocked and exported (length: ${#BW_SESSION})"
+}
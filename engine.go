@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// engineInfo describes the container engine behind the docker CLI (which may be Podman's docker shim)
+type engineInfo struct {
+	Podman   bool
+	Rootless bool
+}
+
+var (
+	detectedEngine engineInfo
+	detectOnce     sync.Once
+)
+
+// detectEngine identifies the engine once per process; failures are treated as plain Docker
+func detectEngine() engineInfo {
+	detectOnce.Do(func() {
+		output, err := dockerCommand("--version").Output()
+		if err != nil || !strings.HasPrefix(strings.ToLower(strings.TrimSpace(string(output))), "podman") {
+			return
+		}
+		detectedEngine.Podman = true
+
+		rootless, err := dockerCommand("info", "--format", "{{.Host.Security.Rootless}}").Output()
+		detectedEngine.Rootless = err == nil && strings.TrimSpace(string(rootless)) == "true"
+	})
+	return detectedEngine
+}
+
+// errEngineUnavailable is wrapped by errors caused by an unreachable container engine daemon
+var errEngineUnavailable = errors.New("container engine unavailable")
+
+// engineCmd is an engine CLI invocation whose failures are translated into a clear
+// message when the engine daemon is down
+type engineCmd struct {
+	*exec.Cmd
+}
+
+// Run runs the command, classifying failures
+func (c *engineCmd) Run() error {
+	return classifyEngineError(c.Cmd.Run())
+}
+
+// Output runs the command and returns its stdout, classifying failures
+func (c *engineCmd) Output() ([]byte, error) {
+	output, err := c.Cmd.Output()
+	return output, classifyEngineError(err)
+}
+
+// CombinedOutput runs the command and returns stdout and stderr, classifying failures
+func (c *engineCmd) CombinedOutput() ([]byte, error) {
+	output, err := c.Cmd.CombinedOutput()
+	return output, classifyEngineError(err)
+}
+
+// engineUnavailableError replaces a raw engine failure with an actionable message
+type engineUnavailableError struct {
+	message string
+	err     error
+}
+
+func (e *engineUnavailableError) Error() string {
+	return e.message
+}
+
+func (e *engineUnavailableError) Unwrap() []error {
+	return []error{errEngineUnavailable, e.err}
+}
+
+// engineNotFoundMessage is the guidance given when the docker CLI is not installed
+const engineNotFoundMessage = "the container engine CLI (docker) was not found on PATH — install Docker Desktop, Docker Engine or Podman (with its docker CLI shim)"
+
+var (
+	daemonProblem     string
+	daemonProbeOnce   sync.Once
+	probeEngineDaemon = probeEngineDaemonStatus
+)
+
+// classifyEngineError returns err unchanged unless the engine daemon is unreachable.
+// The daemon is probed at most once per process, on the first failure.
+func classifyEngineError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, exec.ErrNotFound) {
+		return &engineUnavailableError{message: engineNotFoundMessage, err: err}
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return err
+	}
+
+	daemonProbeOnce.Do(func() { daemonProblem = probeEngineDaemon() })
+	if daemonProblem == "" {
+		return err
+	}
+	return &engineUnavailableError{message: daemonProblem, err: err}
+}
+
+// checkEngine fails early, before a container is started, when the docker CLI is missing or its daemon
+// is unreachable, so the user gets the same guidance as classifyEngineError instead of a raw exec error
+func checkEngine() error {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return &engineUnavailableError{message: engineNotFoundMessage, err: err}
+	}
+	daemonProbeOnce.Do(func() { daemonProblem = probeEngineDaemon() })
+	if daemonProblem != "" {
+		return &engineUnavailableError{message: daemonProblem, err: errors.New("docker info failed")}
+	}
+	return nil
+}
+
+// probeEngineDaemonStatus asks the engine for its server version and describes why it is unreachable, or returns ""
+func probeEngineDaemonStatus() string {
+	output, err := exec.Command("docker", engineCommandArgs([]string{"info", "--format", "{{.ServerVersion}}"})...).CombinedOutput()
+	if err == nil {
+		return ""
+	}
+	return describeDaemonProblem(string(output))
+}
+
+// describeDaemonProblem turns failed `docker info` output into an actionable message
+func describeDaemonProblem(output string) string {
+	if strings.Contains(strings.ToLower(output), "permission denied") {
+		return "permission denied connecting to the container engine daemon — add your user to the docker group or check the socket permissions"
+	}
+	return "the container engine daemon does not appear to be running — start Docker Desktop/dockerd"
+}
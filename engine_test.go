@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestClassifyEngineError(t *testing.T) {
+	stubEngineProbe(t, "")
+	exitErr := exec.Command("sh", "-c", "exit 125").Run()
+
+	// Daemon down: the exit error is replaced but stays inspectable
+	daemonProbeOnce = sync.Once{}
+	probes := 0
+	probeEngineDaemon = func() string {
+		probes++
+		return describeDaemonProblem("Cannot connect to the Docker daemon at unix:///var/run/docker.sock. Is the docker daemon running?")
+	}
+	err := classifyEngineError(exitErr)
+	if !errors.Is(err, errEngineUnavailable) || !strings.Contains(err.Error(), "does not appear to be running") {
+		t.Errorf("daemon down: got %v", err)
+	}
+	runErr := &RunError{Err: err}
+	if runErr.ExitCode() != 125 {
+		t.Errorf("daemon down: ExitCode() = %d, want 125", runErr.ExitCode())
+	}
+	classifyEngineError(exitErr)
+	if probes != 1 {
+		t.Errorf("daemon probed %d times, want 1", probes)
+	}
+
+	// Daemon up: errors pass through untouched
+	stubEngineProbe(t, "")
+	if err := classifyEngineError(exitErr); err != exitErr {
+		t.Errorf("daemon up: got %v, want original error", err)
+	}
+
+	if err := classifyEngineError(exec.ErrNotFound); !errors.Is(err, errEngineUnavailable) {
+		t.Errorf("missing CLI: got %v", err)
+	}
+	if classifyEngineError(nil) != nil {
+		t.Error("nil error should stay nil")
+	}
+}
+
+func TestCheckEngine(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	if err := checkEngine(); !errors.Is(err, errEngineUnavailable) || !strings.Contains(err.Error(), "not found on PATH") {
+		t.Errorf("missing CLI: got %v", err)
+	}
+
+	fakeDocker(t, "#!/bin/sh\n")
+	stubEngineProbe(t, describeDaemonProblem("Cannot connect to the Docker daemon"))
+	if err := checkEngine(); !errors.Is(err, errEngineUnavailable) || !strings.Contains(err.Error(), "does not appear to be running") {
+		t.Errorf("daemon down: got %v", err)
+	}
+
+	stubEngineProbe(t, "")
+	if err := checkEngine(); err != nil {
+		t.Errorf("engine ready: got %v", err)
+	}
+}
+
+func TestDescribeDaemonProblem(t *testing.T) {
+	if got := describeDaemonProblem("permission denied while trying to connect to the Docker daemon socket"); !strings.Contains(got, "permission denied") {
+		t.Errorf("permission problem: got %q", got)
+	}
+	if got := describeDaemonProblem("Cannot connect to the Docker daemon"); !strings.Contains(got, "start Docker Desktop/dockerd") {
+		t.Errorf("daemon down: got %q", got)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// readEnvFile parses KEY=VALUE lines, skipping blank lines and # comments. Values are taken verbatim
+// (no quote handling, like docker's own --env-file). Entries are sensitive unless plain is set, and
+// secret-looking keys are sensitive either way.
+func readEnvFile(path string, plain bool) (map[string]EnvVar, error) {
+	path, err := expandHome(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open env file: %w", err)
+	}
+	defer file.Close()
+
+	env := make(map[string]EnvVar)
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("invalid line %d in env file %s (expected KEY=VALUE)", n, path)
+		}
+		env[key] = EnvVar{Value: value, Sensitive: !plain || isSensitiveKey(key)}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read env file: %w", err)
+	}
+	return env, nil
+}
+
+// envFromFiles reads --env-file-plain and then --env-file files in order, later files winning on
+// conflicts. Callers overlay their flag-provided variables on the result.
+func envFromFiles(c *cli.Context) (map[string]EnvVar, error) {
+	env := make(map[string]EnvVar)
+	for _, source := range []struct {
+		flag  string
+		plain bool
+	}{{"env-file-plain", true}, {"env-file", false}} {
+		for _, path := range c.StringSlice(source.flag) {
+			fileEnv, err := readEnvFile(path, source.plain)
+			if err != nil {
+				return nil, err
+			}
+			for key, envVar := range fileEnv {
+				env[key] = envVar
+			}
+		}
+	}
+	return env, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadEnvFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "backup.env")
+	content := "# proxy settings\n\nHTTPS_PROXY=http://proxy:3128\n  BW_SESSION_TOKEN = abc=def \nEMPTY=\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		plain    bool
+		expected map[string]EnvVar
+	}{
+		{
+			name: "sensitive by default",
+			expected: map[string]EnvVar{
+				"HTTPS_PROXY":      {Value: "http://proxy:3128", Sensitive: true},
+				"BW_SESSION_TOKEN": {Value: " abc=def", Sensitive: true},
+				"EMPTY":            {Value: "", Sensitive: true},
+			},
+		},
+		{
+			name:  "plain keeps secret-looking keys sensitive",
+			plain: true,
+			expected: map[string]EnvVar{
+				"HTTPS_PROXY":      {Value: "http://proxy:3128"},
+				"BW_SESSION_TOKEN": {Value: " abc=def", Sensitive: true},
+				"EMPTY":            {Value: ""},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, err := readEnvFile(path, tt.plain)
+			if err != nil {
+				t.Fatalf("readEnvFile() error = %v", err)
+			}
+			if !reflect.DeepEqual(env, tt.expected) {
+				t.Errorf("readEnvFile() =\n%v\nexpected\n%v", env, tt.expected)
+			}
+		})
+	}
+
+	for _, invalid := range []string{"NOVALUE\n", "=value\n", "BAD KEY=1\n"} {
+		bad := filepath.Join(dir, "bad.env")
+		if err := os.WriteFile(bad, []byte("OK=1\n"+invalid), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readEnvFile(bad, false); err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("readEnvFile(%q) error = %v, expected a line 2 error", invalid, err)
+		}
+	}
+	if _, err := readEnvFile(filepath.Join(dir, "missing.env"), false); err == nil {
+		t.Error("readEnvFile() expected an error for a missing file")
+	}
+}
+
+func TestEnvFileValuesRedacted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.env")
+	if err := os.WriteFile(path, []byte("ENDPOINT=https://internal.example\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	env, err := readEnvFile(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(sanitizeDockerArgs([]string{"run", "-e", "ENDPOINT=https://internal.example", "image"}, env), " ")
+	if strings.Contains(got, "internal.example") {
+		t.Errorf("sanitizeDockerArgs() = %q, expected the env file value redacted", got)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Event types emitted by long-running modes
+const (
+	eventStarted    = "started"
+	eventStopped    = "stopped"
+	eventReady      = "ready"
+	eventCompressed = "compressed"
+	eventBackupDone = "backup_done"
+	eventRecreate   = "recreate"
+	eventError      = "error"
+)
+
+// Event is one JSON-lines record written by --events-jsonl
+type Event struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Command string    `json:"command,omitempty"`
+	Target  string    `json:"target,omitempty"` // File, profile or container the event is about
+	Message string    `json:"message,omitempty"`
+}
+
+// eventEmitter writes events as JSON lines; a nil writer disables it so callers can emit unconditionally
+type eventEmitter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	now func() time.Time
+}
+
+// events is enabled by the global --events-jsonl flag
+var events = newEventEmitter(nil)
+
+// newEventEmitter returns an emitter writing to w, or a no-op emitter when w is nil
+func newEventEmitter(w io.Writer) *eventEmitter {
+	e := &eventEmitter{now: time.Now}
+	if w != nil {
+		e.enc = json.NewEncoder(w)
+	}
+	return e
+}
+
+// Emit writes one event; it is safe for concurrent use
+func (e *eventEmitter) Emit(eventType, command, target, message string) {
+	if e.enc == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// Encoding errors (e.g. closed pipe) must never take down the daemon loop
+	_ = e.enc.Encode(Event{
+		Time:    e.now().UTC(),
+		Type:    eventType,
+		Command: command,
+		Target:  target,
+		Message: message,
+	})
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEventEmitter(t *testing.T) {
+	var buf bytes.Buffer
+	e := newEventEmitter(&buf)
+	e.now = func() time.Time { return time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.Emit(eventBackupDone, "bw-backup", "personal", "")
+		}()
+	}
+	wg.Wait()
+
+	lines := 0
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("line %d is not valid JSON: %v: %s", lines, err, scanner.Text())
+		}
+		if event.Type != eventBackupDone || event.Target != "personal" {
+			t.Errorf("unexpected event: %+v", event)
+		}
+		lines++
+	}
+	if lines != 20 {
+		t.Errorf("got %d lines, want 20", lines)
+	}
+}
+
+func TestEventEmitterDisabled(t *testing.T) {
+	// A nil writer must be a silent no-op
+	newEventEmitter(nil).Emit(eventError, "", "", "ignored")
+}
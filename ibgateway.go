@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// defaultIBGatewayPorts maps host ports to the gateway's socat-exposed API ports
+var defaultIBGatewayPorts = map[string]string{
+	"4001": "4003",
+	"4002": "4004",
+}
+
+// ibGatewaySettingsDir is the in-container settings path (TWS_SETTINGS_PATH) used with --log-dir
+const ibGatewaySettingsDir = "/home/ibgateway/tws_settings"
+
+// runIBGateway starts the IB Gateway daemon container
+func runIBGateway(c *cli.Context) error {
+	user := c.String("user")
+	password := c.String("password")
+	mode := c.String("mode")
+	image := c.String("image")
+	name := c.String("name")
+
+	if user == "" || password == "" {
+		return fmt.Errorf("--user and --password (or TWS_USERID and TWS_PASSWORD) are required to start the gateway")
+	}
+
+	// Validate trading mode
+	if mode != "paper" && mode != "live" {
+		return fmt.Errorf("invalid trading mode: %s (must be 'paper' or 'live')", mode)
+	}
+
+	// Configure port mappings (flags replace the defaults entirely)
+	ports := defaultIBGatewayPorts
+	if c.IsSet("port") {
+		var err error
+		ports, err = parsePortMappings(c.StringSlice("port"))
+		if err != nil {
+			return err
+		}
+	}
+
+	// Start from passthrough variables so the dedicated flags below always win.
+	// Precedence: dedicated flags > --env > --env-prefix host variables.
+	env := hostEnvWithPrefix(c.StringSlice("env-prefix"))
+	explicitEnv, err := parseEnvAssignments(c.StringSlice("env"))
+	if err != nil {
+		return err
+	}
+	for key, envVar := range explicitEnv {
+		env[key] = envVar
+	}
+
+	// Configure environment variables
+	env["TWS_USERID"] = EnvVar{Value: user, Sensitive: true}
+	env["TWS_PASSWORD"] = EnvVar{Value: password, Sensitive: true}
+	env["TRADING_MODE"] = EnvVar{Value: mode, Sensitive: false}
+
+	limits, err := resourceLimitsFromFlags(c)
+	if err != nil {
+		return err
+	}
+	daemonOpts := DaemonOptions{Limits: limits, Labels: toolLabels("ibgateway")}
+
+	// Persist the gateway's settings directory, where it also writes its logs, on the host
+	settingsDir, err := gatewaySettingsDir(c.String("config-dir"), c.String("log-dir"))
+	if err != nil {
+		return err
+	}
+	if settingsDir != "" {
+		// A dry run leaves the filesystem alone
+		if !runtimeSettings.DryRun {
+			if err := os.MkdirAll(settingsDir, 0700); err != nil {
+				return fmt.Errorf("failed to create settings directory: %w", err)
+			}
+		}
+		env["TWS_SETTINGS_PATH"] = EnvVar{Value: ibGatewaySettingsDir, Sensitive: false}
+		daemonOpts.Volumes = append(daemonOpts.Volumes, fmt.Sprintf("%s:%s", settingsDir, ibGatewaySettingsDir))
+	}
+
+	// Preview what recreating the container will change; a dry run always shows it
+	if c.Bool("diff") || runtimeSettings.DryRun {
+		if err := printDaemonDiff(name, image, ports, env); err != nil {
+			if !runtimeSettings.DryRun {
+				return err
+			}
+			fmt.Fprintln(os.Stderr, colorWarning("WARNING:"), "could not compare with the existing container:", err)
+		}
+	}
+
+	var probePort string
+	if c.Bool("watchdog") {
+		if c.Int("probe-failures") < 1 {
+			return fmt.Errorf("--probe-failures must be at least 1")
+		}
+		if c.Duration("probe-interval") <= 0 {
+			return fmt.Errorf("--probe-interval must be positive")
+		}
+		if c.Duration("startup-grace") < 0 {
+			return fmt.Errorf("--startup-grace must not be negative")
+		}
+		if probePort, err = watchdogProbePort(ports, mode); err != nil {
+			return err
+		}
+	}
+
+	waitTimeout := c.Duration("wait-timeout")
+	if waitTimeout < 0 {
+		return fmt.Errorf("--wait-timeout must not be negative")
+	}
+
+	infof("Starting IB Gateway container '%s' in %s mode...\n", name, mode)
+	if err := RunDaemon(name, image, ports, env, daemonOpts); err != nil {
+		return err
+	}
+
+	if waitTimeout > 0 && !runtimeSettings.DryRun {
+		// Without a mapped API port, readiness falls back to the container state and healthcheck
+		waitPort, _ := watchdogProbePort(ports, mode)
+		if err := waitForDaemon(name, waitPort, waitTimeout); err != nil {
+			return err
+		}
+		events.Emit(eventReady, "ibgateway", name, "")
+	}
+
+	if !c.Bool("watchdog") || runtimeSettings.DryRun {
+		return nil
+	}
+	// RunDaemon removes the dead container before re-running it
+	return runGatewayWatchdog(probePort, c.Duration("probe-interval"), c.Duration("startup-grace"), c.Int("probe-failures"), func() error {
+		return RunDaemon(name, image, ports, env, daemonOpts)
+	})
+}
+
+// gatewaySettingsDir resolves the host directory mounted as the gateway's settings directory.
+// --config-dir and --log-dir both name it (the gateway keeps settings and logs together), so
+// they may only be combined when they resolve to the same path. Empty means no mount.
+func gatewaySettingsDir(configDir, logDir string) (string, error) {
+	var resolved string
+	for _, dir := range []string{configDir, logDir} {
+		if dir == "" {
+			continue
+		}
+		expanded, err := expandHome(dir)
+		if err != nil {
+			return "", err
+		}
+		absDir, err := filepath.Abs(expanded)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve settings directory: %w", err)
+		}
+		if resolved != "" && resolved != absDir {
+			return "", fmt.Errorf("--config-dir and --log-dir both set the gateway settings directory; use one of them")
+		}
+		resolved = absDir
+	}
+	return resolved, nil
+}
+
+// parsePortMappings converts "host:container" strings into a host→container port map, rejecting reused host ports
+func parsePortMappings(mappings []string) (map[string]string, error) {
+	ports := make(map[string]string, len(mappings))
+	for _, mapping := range mappings {
+		hostPort, containerPort, ok := strings.Cut(mapping, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid port mapping: %s (expected host:container)", mapping)
+		}
+		if err := validatePort(hostPort); err != nil {
+			return nil, fmt.Errorf("invalid port mapping %s: host %w", mapping, err)
+		}
+		if err := validatePort(containerPort); err != nil {
+			return nil, fmt.Errorf("invalid port mapping %s: container %w", mapping, err)
+		}
+		if existing, dup := ports[hostPort]; dup {
+			return nil, fmt.Errorf("duplicate host port %s in port mappings %s:%s and %s", hostPort, hostPort, existing, mapping)
+		}
+		ports[hostPort] = containerPort
+	}
+	return ports, nil
+}
+
+// validatePort checks that port is an integer in the valid TCP port range
+func validatePort(port string) error {
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("port must be an integer between 1 and 65535, got %q", port)
+	}
+	return nil
+}
+
+// printDaemonDiff prints the configuration changes a recreate of the named container would apply
+func printDaemonDiff(name, image string, ports map[string]string, env map[string]EnvVar) error {
+	changes, exists, err := DiffDaemon(name, image, ports, env)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !exists:
+		fmt.Printf("Container '%s' does not exist and will be created\n", name)
+	case len(changes) == 0:
+		fmt.Printf("Container '%s' configuration is unchanged\n", name)
+	default:
+		fmt.Printf("Recreating container '%s' will change:\n", name)
+		for _, change := range changes {
+			fmt.Printf("  - %s\n", change)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// lookupGateway returns the named gateway container, failing with a hint if it does not exist
+func lookupGateway(name string) (*containerInfo, error) {
+	info, err := findContainer(name)
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, fmt.Errorf("no container named '%s' (start it with 'containers ibgateway')", name)
+	}
+	return info, nil
+}
+
+// runGatewayCommand runs an engine command against the gateway container, only printing it under --dry-run
+func runGatewayCommand(args ...string) error {
+	printEngineCommand(args, nil)
+	if runtimeSettings.DryRun {
+		return nil
+	}
+	if output, err := dockerCommand(args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// runIBGatewayStatus reports whether the gateway container is running and which ports it publishes
+func runIBGatewayStatus(c *cli.Context) error {
+	name := c.String("name")
+	info, err := lookupGateway(name)
+	if err != nil {
+		return err
+	}
+
+	state := info.State
+	if state == "running" {
+		state = colorSuccess(state)
+	} else {
+		state = colorFailure(state)
+	}
+	fmt.Printf("Container '%s': %s (%s)\n", name, state, info.Status)
+	ports := "(none)"
+	if info.Ports != "" {
+		ports = info.Ports
+	}
+	fmt.Printf("Ports: %s\n", ports)
+	return nil
+}
+
+// runIBGatewayStop stops the gateway container and, with --rm, removes it
+func runIBGatewayStop(c *cli.Context) error {
+	name := c.String("name")
+	info, err := lookupGateway(name)
+	if err != nil {
+		return err
+	}
+
+	if info.State == "running" {
+		fmt.Printf("Stopping container '%s'...\n", name)
+		if err := runGatewayCommand("stop", name); err != nil {
+			return fmt.Errorf("failed to stop container: %w", err)
+		}
+	}
+	if c.Bool("rm") {
+		if err := runGatewayCommand("rm", name); err != nil {
+			return fmt.Errorf("failed to remove container: %w", err)
+		}
+		fmt.Printf("Removed container '%s'\n", name)
+		return nil
+	}
+	fmt.Printf("Stopped container '%s'\n", name)
+	return nil
+}
+
+// runIBGatewayRestart stops the gateway container and starts it again with the configuration it was created with
+func runIBGatewayRestart(c *cli.Context) error {
+	name := c.String("name")
+	if _, err := lookupGateway(name); err != nil {
+		return err
+	}
+
+	fmt.Printf("Restarting container '%s'...\n", name)
+	if err := runGatewayCommand("restart", name); err != nil {
+		return fmt.Errorf("failed to restart container: %w", err)
+	}
+	if runtimeSettings.DryRun {
+		return nil
+	}
+	return runIBGatewayStatus(c)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePortMappings(t *testing.T) {
+	tests := []struct {
+		name     string
+		mappings []string
+		expected map[string]string
+		wantErr  bool
+	}{
+		{
+			name:     "single mapping",
+			mappings: []string{"5001:4003"},
+			expected: map[string]string{"5001": "4003"},
+		},
+		{
+			name:     "multiple mappings",
+			mappings: []string{"5001:4003", "5002:4004"},
+			expected: map[string]string{"5001": "4003", "5002": "4004"},
+		},
+		{
+			name:     "duplicate host port",
+			mappings: []string{"5001:4003", "5001:4004"},
+			wantErr:  true,
+		},
+		{
+			name:     "same container port on two host ports",
+			mappings: []string{"5001:4003", "6001:4003"},
+			expected: map[string]string{"5001": "4003", "6001": "4003"},
+		},
+		{
+			name:     "missing separator",
+			mappings: []string{"5001"},
+			wantErr:  true,
+		},
+		{
+			name:     "non-numeric host port",
+			mappings: []string{"abc:4003"},
+			wantErr:  true,
+		},
+		{
+			name:     "non-numeric container port",
+			mappings: []string{"5001:abc"},
+			wantErr:  true,
+		},
+		{
+			name:     "port out of range",
+			mappings: []string{"70000:4003"},
+			wantErr:  true,
+		},
+		{
+			name:     "extra separator",
+			mappings: []string{"127.0.0.1:5001:4003"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parsePortMappings(tt.mappings)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parsePortMappings() expected error, got %v", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePortMappings() unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("parsePortMappings() =\n%v\nexpected\n%v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGatewaySettingsDir(t *testing.T) {
+	dir := t.TempDir()
+	other := t.TempDir()
+
+	tests := []struct {
+		name      string
+		configDir string
+		logDir    string
+		expected  string
+		wantErr   bool
+	}{
+		{name: "neither"},
+		{name: "config dir", configDir: dir, expected: dir},
+		{name: "log dir", logDir: dir, expected: dir},
+		{name: "same dir", configDir: dir, logDir: dir + "/", expected: dir},
+		{name: "different dirs", configDir: dir, logDir: other, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := gatewaySettingsDir(tt.configDir, tt.logDir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("gatewaySettingsDir() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("gatewaySettingsDir() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIBGatewayRejectsBadWatchdogTimings(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   []string
+		wantErr string
+	}{
+		{name: "zero probe interval", flags: []string{"--probe-interval", "0s"}, wantErr: "--probe-interval"},
+		{name: "negative probe interval", flags: []string{"--probe-interval", "-5s"}, wantErr: "--probe-interval"},
+		{name: "negative startup grace", flags: []string{"--startup-grace", "-1m"}, wantErr: "--startup-grace"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubEngineProbe(t, "")
+			logPath := fakeDocker(t, fakeDockerScript)
+
+			args := append([]string{"ibgateway", "--user", "u", "--password", "p", "--watchdog"}, tt.flags...)
+			err := runAppCommand(t, "ibgateway", args, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("app.Run() error = %v, expected an error about %s", err, tt.wantErr)
+			}
+			if _, err := os.Stat(logPath); !os.IsNotExist(err) {
+				log, _ := os.ReadFile(logPath)
+				t.Errorf("docker was called before validation: %s", log)
+			}
+		})
+	}
+}
+
+func TestIBGatewayDryRunCreatesNoSettingsDir(t *testing.T) {
+	stubEngineProbe(t, "")
+	fakeDocker(t, fakeDockerScript)
+	settingsDir := filepath.Join(t.TempDir(), "settings")
+
+	args := []string{"--dry-run", "ibgateway", "--user", "u", "--password", "p", "--config-dir", settingsDir}
+	if err := runAppCommand(t, "ibgateway", args, nil); err != nil {
+		t.Fatalf("dry run error = %v", err)
+	}
+	if _, err := os.Stat(settingsDir); !os.IsNotExist(err) {
+		t.Errorf("dry run created %s (stat error %v)", settingsDir, err)
+	}
+}
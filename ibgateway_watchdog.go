@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// gatewayState is the watchdog's view of the gateway lifecycle
+type gatewayState int
+
+const (
+	gatewayStarting gatewayState = iota // Started or recreated, not yet observed healthy
+	gatewayReady                        // Observed healthy at least once since the last (re)start
+)
+
+// watchdogAction is what the watchdog loop should do after a probe
+type watchdogAction int
+
+const (
+	watchdogNone watchdogAction = iota
+	watchdogBecameReady
+	watchdogNotReadyAfterGrace
+	watchdogRecreate
+)
+
+// gatewayWatchdog separates readiness from liveness: probe failures only count
+// towards recreation once the gateway has been healthy and the startup grace has passed
+type gatewayWatchdog struct {
+	grace     time.Duration
+	threshold int
+
+	state     gatewayState
+	startedAt time.Time
+	failures  int
+	warned    bool
+}
+
+// newGatewayWatchdog returns a watchdog for a gateway started at now
+func newGatewayWatchdog(grace time.Duration, threshold int, now time.Time) *gatewayWatchdog {
+	w := &gatewayWatchdog{grace: grace, threshold: threshold}
+	w.restarted(now)
+	return w
+}
+
+// restarted resets the watchdog after the container was (re)created
+func (w *gatewayWatchdog) restarted(now time.Time) {
+	w.state = gatewayStarting
+	w.startedAt = now
+	w.failures = 0
+	w.warned = false
+}
+
+// observe records a probe result and returns the action to take
+func (w *gatewayWatchdog) observe(healthy bool, now time.Time) watchdogAction {
+	inGrace := now.Sub(w.startedAt) < w.grace
+
+	switch w.state {
+	case gatewayStarting:
+		if healthy {
+			w.state = gatewayReady
+			w.failures = 0
+			return watchdogBecameReady
+		}
+		// Still booting: never recreate a container that has not been ready yet
+		if !inGrace && !w.warned {
+			w.warned = true
+			return watchdogNotReadyAfterGrace
+		}
+		return watchdogNone
+
+	case gatewayReady:
+		if healthy {
+			w.failures = 0
+			return watchdogNone
+		}
+		if inGrace {
+			return watchdogNone
+		}
+		w.failures++
+		if w.failures >= w.threshold {
+			return watchdogRecreate
+		}
+	}
+	return watchdogNone
+}
+
+// watchdogProbePort returns the host port mapped to the API port of the given trading mode
+func watchdogProbePort(ports map[string]string, mode string) (string, error) {
+	apiPort := defaultIBGatewayPorts["4002"]
+	if mode == "live" {
+		apiPort = defaultIBGatewayPorts["4001"]
+	}
+	for hostPort, containerPort := range ports {
+		if containerPort == apiPort {
+			return hostPort, nil
+		}
+	}
+	return "", fmt.Errorf("no host port maps to the %s API port %s; cannot probe gateway", mode, apiPort)
+}
+
+// probeGateway reports whether the gateway accepts TCP connections on the host port
+func probeGateway(hostPort string) bool {
+	return hostPortOpen(hostPort)
+}
+
+// runGatewayWatchdog probes the gateway until interrupted, calling recreate when it dies
+func runGatewayWatchdog(hostPort string, interval, grace time.Duration, threshold int, recreate func() error) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("Watchdog probing 127.0.0.1:%s every %s (startup grace %s, %d failures to recreate)\n",
+		hostPort, interval, grace, threshold)
+
+	events.Emit(eventStarted, "ibgateway", hostPort, "watchdog")
+	w := newGatewayWatchdog(grace, threshold, time.Now())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Println("Watchdog stopped")
+			events.Emit(eventStopped, "ibgateway", hostPort, "watchdog")
+			return nil
+		case <-ticker.C:
+		}
+
+		switch w.observe(probeGateway(hostPort), time.Now()) {
+		case watchdogBecameReady:
+			fmt.Println("Watchdog: gateway is ready")
+			events.Emit(eventReady, "ibgateway", hostPort, "")
+		case watchdogNotReadyAfterGrace:
+			fmt.Fprintf(os.Stderr, "Watchdog: gateway not ready after %s startup grace; still waiting\n", grace)
+		case watchdogRecreate:
+			fmt.Fprintf(os.Stderr, "Watchdog: gateway failed %d consecutive probes; recreating\n", threshold)
+			events.Emit(eventRecreate, "ibgateway", hostPort, fmt.Sprintf("%d consecutive failed probes", threshold))
+			if err := recreate(); err != nil {
+				fmt.Fprintf(os.Stderr, "Watchdog: recreate failed: %v\n", err)
+				events.Emit(eventError, "ibgateway", hostPort, err.Error())
+			}
+			w.restarted(time.Now())
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGatewayWatchdog(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	w := newGatewayWatchdog(time.Minute, 2, start)
+
+	// Booting: failures never recreate, even after the grace period
+	if got := w.observe(false, at(10*time.Second)); got != watchdogNone {
+		t.Fatalf("failure during grace = %v, want none", got)
+	}
+	if got := w.observe(false, at(2*time.Minute)); got != watchdogNotReadyAfterGrace {
+		t.Fatalf("failure after grace while starting = %v, want not-ready warning", got)
+	}
+	if got := w.observe(false, at(3*time.Minute)); got != watchdogNone {
+		t.Fatalf("repeated failure while starting = %v, want none", got)
+	}
+
+	// Ready, then dead: recreate once the threshold is reached
+	if got := w.observe(true, at(4*time.Minute)); got != watchdogBecameReady {
+		t.Fatalf("first healthy probe = %v, want became ready", got)
+	}
+	if got := w.observe(false, at(5*time.Minute)); got != watchdogNone {
+		t.Fatalf("first failure after ready = %v, want none", got)
+	}
+	if got := w.observe(true, at(6*time.Minute)); got != watchdogNone {
+		t.Fatalf("recovery = %v, want none", got)
+	}
+	if got := w.observe(false, at(7*time.Minute)); got != watchdogNone {
+		t.Fatalf("failure after recovery = %v, want none (counter reset)", got)
+	}
+	if got := w.observe(false, at(8*time.Minute)); got != watchdogRecreate {
+		t.Fatalf("threshold reached = %v, want recreate", got)
+	}
+
+	// After recreation the gateway is starting again
+	w.restarted(at(9 * time.Minute))
+	for i := 0; i < 5; i++ {
+		if got := w.observe(false, at(9*time.Minute+time.Duration(i)*time.Second)); got != watchdogNone {
+			t.Fatalf("failure after restart = %v, want none", got)
+		}
+	}
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// defaultRegistry is the registry docker resolves unqualified image names against
+const defaultRegistry = "docker.io"
+
+// Default images run by the built-in commands
+const (
+	pdfCompressImage = "ghcr.io/vupham90/containers-pdf-compress:latest"
+	bwBackupImage    = "ghcr.io/vupham90/containers-bw-backup:latest"
+	ibGatewayImage   = "ghcr.io/gnzsnz/ib-gateway:latest"
+)
+
+// toolImage pairs an image reference with the command that runs it
+type toolImage struct {
+	Purpose string
+	Ref     string
+}
+
+// defaultImages lists every image the built-in commands run unless overridden
+func defaultImages() []toolImage {
+	return []toolImage{
+		{Purpose: "pdf-compress", Ref: pdfCompressImage},
+		{Purpose: "bw-backup", Ref: bwBackupImage},
+		{Purpose: "ibgateway", Ref: ibGatewayImage},
+	}
+}
+
+// effectiveImages lists the images the built-in commands run once the config file's bw-backup
+// image-digest and ibgateway image overrides are applied, the same way those commands resolve them
+func effectiveImages() ([]toolImage, error) {
+	images := defaultImages()
+	for i, image := range images {
+		switch image.Purpose {
+		case "bw-backup":
+			digest, _ := configFlagValue("bw-backup", "image-digest")
+			ref, err := pinnedBackupImage(digest)
+			if err != nil {
+				return nil, fmt.Errorf("invalid image for bw-backup in the config file: %w", err)
+			}
+			images[i].Ref = ref
+		case "ibgateway":
+			if ref, ok := configFlagValue("ibgateway", "image"); ok {
+				images[i].Ref = ref
+			}
+		}
+	}
+	return images, nil
+}
+
+// ImageRef is a parsed container image reference: [registry/]repository[:tag][@digest]
+type ImageRef struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseImageRef parses an image reference using docker's normalization rules.
+// The first path component is treated as a registry only if it looks like a host
+// (contains "." or ":" or is "localhost"); otherwise docker.io is assumed.
+func ParseImageRef(ref string) (ImageRef, error) {
+	if ref == "" {
+		return ImageRef{}, fmt.Errorf("image reference must not be empty")
+	}
+
+	var image ImageRef
+	remainder := ref
+
+	// Split off digest
+	if name, digest, ok := strings.Cut(remainder, "@"); ok {
+		if !strings.HasPrefix(digest, "sha256:") || len(digest) != len("sha256:")+64 {
+			return ImageRef{}, fmt.Errorf("invalid image digest in %s (expected sha256:<64 hex chars>)", ref)
+		}
+		image.Digest = digest
+		remainder = name
+	}
+
+	// Split off registry
+	if first, rest, ok := strings.Cut(remainder, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		image.Registry = first
+		remainder = rest
+	} else {
+		image.Registry = defaultRegistry
+	}
+
+	// Split off tag (a colon after the last slash)
+	if i := strings.LastIndex(remainder, ":"); i > strings.LastIndex(remainder, "/") {
+		image.Tag = remainder[i+1:]
+		remainder = remainder[:i]
+	}
+
+	if remainder == "" {
+		return ImageRef{}, fmt.Errorf("invalid image reference: %s", ref)
+	}
+	if image.Registry == defaultRegistry && !strings.Contains(remainder, "/") {
+		remainder = "library/" + remainder
+	}
+	image.Repository = remainder
+
+	return image, nil
+}
+
+// String returns the fully-qualified reference
+func (r ImageRef) String() string {
+	ref := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		ref += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		ref += "@" + r.Digest
+	}
+	return ref
+}
+
+// checkImageAllowed refuses images whose registry is not on the allowlist
+func checkImageAllowed(image string, allowedRegistries []string) error {
+	ref, err := ParseImageRef(image)
+	if err != nil {
+		return err
+	}
+
+	for _, registry := range allowedRegistries {
+		if strings.EqualFold(ref.Registry, registry) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("image %s is from registry %s which is not allowed (allowed: %s); use --allowed-registry to permit it",
+		image, ref.Registry, strings.Join(allowedRegistries, ", "))
+}
+
+// pullMissingImage runs an explicit `docker pull` under --pull missing for an image that is not present
+// locally, with its progress on stderr, so a first run does not stall silently inside `docker run`.
+// --pull always is left to `docker run`, which pulls anyway. The pull is bounded by ctx and --timeout,
+// stops on Ctrl-C and retries transient failures up to --retries times; other failures are left for
+// `docker run` to report.
+func pullMissingImage(ctx context.Context, image string) error {
+	if runtimeSettings.PullPolicy != "missing" || runtimeSettings.DryRun {
+		return nil
+	}
+	if dockerCommandContext(ctx, "image", "inspect", "--format", "{{.Id}}", image).Run() == nil {
+		return nil
+	}
+
+	ctx, cancel := withRunTimeout(ctx)
+	defer cancel()
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	args := []string{"pull"}
+	if runtimeSettings.Quiet {
+		args = append(args, "--quiet")
+	} else {
+		fmt.Fprintf(os.Stderr, "Pulling image %s...\n", image)
+	}
+	args = append(args, image)
+	for attempt := 1; ; attempt++ {
+		stderrTail := &tailBuffer{limit: 64 * 1024}
+		cmd := dockerCommandContext(ctx, args...)
+		cmd.WaitDelay = 5 * time.Second
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = io.MultiWriter(os.Stderr, stderrTail)
+		err := cmd.Run()
+		switch {
+		case err == nil:
+			return nil
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			return fmt.Errorf("image pull %w after %s (--timeout)", ErrTimeout, runtimeSettings.Timeout)
+		case ctx.Err() != nil:
+			return fmt.Errorf("image pull interrupted: %w", ctx.Err())
+		case attempt > runtimeSettings.Retries || !isTransientEngineOutput(stderrTail.String()):
+			fmt.Fprintf(os.Stderr, "Warning: failed to pull %s: %v\n", image, err)
+			return nil
+		}
+
+		delay := retryBackoff(attempt)
+		fmt.Fprintf(os.Stderr, "Transient pull failure (attempt %d of %d), retrying in %s...\n", attempt, runtimeSettings.Retries+1, delay)
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
+	}
+}
+
+// verifyImageDigest makes sure a digest-pinned image is present, pulling it unless --pull never,
+// and that the engine resolved it to the requested digest. Images without a digest are not checked.
+func verifyImageDigest(image string) error {
+	ref, err := ParseImageRef(image)
+	if err != nil {
+		return err
+	}
+	if ref.Digest == "" || runtimeSettings.DryRun {
+		return nil
+	}
+
+	repoDigests, err := imageRepoDigests(image)
+	if err != nil || runtimeSettings.PullPolicy == "always" {
+		if runtimeSettings.PullPolicy == "never" {
+			return fmt.Errorf("image %s is not available locally and --pull never forbids pulling it", image)
+		}
+		if output, err := dockerCommand("pull", "--quiet", image).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to pull %s: %w: %s", image, err, strings.TrimSpace(string(output)))
+		}
+		if repoDigests, err = imageRepoDigests(image); err != nil {
+			return err
+		}
+	}
+
+	if !digestMatches(ref, repoDigests) {
+		return fmt.Errorf("image digest mismatch for %s: engine resolved %s", image, strings.Join(repoDigests, ", "))
+	}
+	return nil
+}
+
+// imageRepoDigests returns the repository@digest references the engine records for a local image
+func imageRepoDigests(image string) ([]string, error) {
+	output, err := dockerCommand("image", "inspect", "--format", "{{json .RepoDigests}}", image).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect image %s: %w", image, err)
+	}
+	var repoDigests []string
+	if err := json.Unmarshal(output, &repoDigests); err != nil {
+		return nil, fmt.Errorf("failed to parse digests of image %s: %w", image, err)
+	}
+	return repoDigests, nil
+}
+
+// digestMatches reports whether any repository@digest reference names ref's repository and digest
+func digestMatches(ref ImageRef, repoDigests []string) bool {
+	for _, entry := range repoDigests {
+		resolved, err := ParseImageRef(entry)
+		if err != nil {
+			continue
+		}
+		if strings.EqualFold(resolved.Registry, ref.Registry) && resolved.Repository == ref.Repository && resolved.Digest == ref.Digest {
+			return true
+		}
+	}
+	return false
+}
+
+// withDigest returns image pinned to digest, dropping any tag
+func withDigest(image, digest string) (string, error) {
+	ref, err := ParseImageRef(image)
+	if err != nil {
+		return "", err
+	}
+	pinned := ImageRef{Registry: ref.Registry, Repository: ref.Repository, Digest: digest}.String()
+	if _, err := ParseImageRef(pinned); err != nil {
+		return "", err
+	}
+	return pinned, nil
+}
+
+// registryLimiter bounds concurrent registry operations and spaces out their start times
+// so batch operations stay under anonymous pull rate limits
+type registryLimiter struct {
+	slots chan struct{}
+	delay time.Duration
+
+	mu        sync.Mutex
+	nextStart time.Time
+}
+
+// newRegistryLimiter creates a limiter allowing concurrency parallel operations started at least delay apart
+func newRegistryLimiter(concurrency int, delay time.Duration) *registryLimiter {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &registryLimiter{slots: make(chan struct{}, concurrency), delay: delay}
+}
+
+// Do runs fn once a concurrency slot is free and the start delay has elapsed
+func (l *registryLimiter) Do(fn func() error) error {
+	l.slots <- struct{}{}
+	defer func() { <-l.slots }()
+
+	l.mu.Lock()
+	wait := time.Until(l.nextStart)
+	if wait < 0 {
+		wait = 0
+	}
+	l.nextStart = time.Now().Add(wait + l.delay)
+	l.mu.Unlock()
+
+	time.Sleep(wait)
+	return fn()
+}
+
+// runImagesList prints every image the tool can run and whether it is pinned by digest
+func runImagesList(c *cli.Context) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PURPOSE\tIMAGE\tPINNED")
+
+	images, err := effectiveImages()
+	if err != nil {
+		return err
+	}
+	var unpinned []string
+	for _, image := range images {
+		ref, err := ParseImageRef(image.Ref)
+		if err != nil {
+			return fmt.Errorf("invalid image for %s: %w", image.Purpose, err)
+		}
+		pinned := "no"
+		if ref.Digest != "" {
+			pinned = "yes"
+		} else {
+			unpinned = append(unpinned, image.Purpose)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", image.Purpose, ref.String(), pinned)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write image list: %w", err)
+	}
+
+	if c.Bool("require-digest") && len(unpinned) > 0 {
+		return fmt.Errorf("%d image(s) not pinned by digest: %s", len(unpinned), strings.Join(unpinned, ", "))
+	}
+	return nil
+}
+
+// runImagesPull pulls every image the tool runs through the registry limiter
+func runImagesPull(c *cli.Context) error {
+	limiter := newRegistryLimiter(c.Int("registry-concurrency"), c.Duration("registry-delay"))
+	images, err := effectiveImages()
+	if err != nil {
+		return err
+	}
+	if runtimeSettings.DryRun {
+		for _, image := range images {
+			if err := checkImageAllowed(image.Ref, runtimeSettings.AllowedRegistries); err != nil {
+				return err
+			}
+			printEngineCommand([]string{"pull", "--quiet", image.Ref}, nil)
+		}
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var failed []string
+
+	for _, image := range images {
+		wg.Add(1)
+		go func(image toolImage) {
+			defer wg.Done()
+			err := limiter.Do(func() error {
+				if err := checkImageAllowed(image.Ref, runtimeSettings.AllowedRegistries); err != nil {
+					return err
+				}
+				output, err := dockerCommand("pull", "--quiet", image.Ref).CombinedOutput()
+				if err != nil {
+					return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+				}
+				return nil
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed = append(failed, image.Ref)
+				fmt.Fprintf(os.Stderr, "  %s %s: %v\n", markFail(), image.Ref, err)
+			} else {
+				infof("  %s %s\n", markOK(), image.Ref)
+			}
+		}(image)
+	}
+	wg.Wait()
+
+	fmt.Printf("Pulled %d of %d image(s)\n", len(images)-len(failed), len(images))
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to pull %d image(s)", len(failed))
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseImageRef(t *testing.T) {
+	digest := "sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+	tests := []struct {
+		ref      string
+		expected ImageRef
+		wantErr  bool
+	}{
+		{
+			ref:      "ghcr.io/vupham90/containers-bw-backup:latest",
+			expected: ImageRef{Registry: "ghcr.io", Repository: "vupham90/containers-bw-backup", Tag: "latest"},
+		},
+		{
+			ref:      "alpine",
+			expected: ImageRef{Registry: "docker.io", Repository: "library/alpine"},
+		},
+		{
+			ref:      "gnzsnz/ib-gateway:stable",
+			expected: ImageRef{Registry: "docker.io", Repository: "gnzsnz/ib-gateway", Tag: "stable"},
+		},
+		{
+			ref:      "localhost:5000/tools/gs",
+			expected: ImageRef{Registry: "localhost:5000", Repository: "tools/gs"},
+		},
+		{
+			ref:      "ghcr.io/vupham90/containers-bw-backup@" + digest,
+			expected: ImageRef{Registry: "ghcr.io", Repository: "vupham90/containers-bw-backup", Digest: digest},
+		},
+		{
+			ref:      "ghcr.io/vupham90/containers-bw-backup:1.2@" + digest,
+			expected: ImageRef{Registry: "ghcr.io", Repository: "vupham90/containers-bw-backup", Tag: "1.2", Digest: digest},
+		},
+		{ref: "", wantErr: true},
+		{ref: "ghcr.io/foo@sha256:short", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			ref, err := ParseImageRef(tt.ref)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseImageRef(%q) expected error, got %+v", tt.ref, ref)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseImageRef(%q) unexpected error: %v", tt.ref, err)
+			}
+			if ref != tt.expected {
+				t.Errorf("ParseImageRef(%q) = %+v, expected %+v", tt.ref, ref, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCheckImageAllowed(t *testing.T) {
+	allowed := []string{"ghcr.io"}
+
+	if err := checkImageAllowed("ghcr.io/gnzsnz/ib-gateway:latest", allowed); err != nil {
+		t.Errorf("checkImageAllowed() unexpected error for allowed registry: %v", err)
+	}
+	if err := checkImageAllowed("gnzsnz/ib-gateway:latest", allowed); err == nil {
+		t.Errorf("checkImageAllowed() expected error for docker.io image")
+	}
+	if err := checkImageAllowed("ghcr.io.evil.com/vupham90/containers-bw-backup", allowed); err == nil {
+		t.Errorf("checkImageAllowed() expected error for look-alike registry")
+	}
+}
+
+func TestRegistryLimiterBoundsConcurrency(t *testing.T) {
+	limiter := newRegistryLimiter(2, 0)
+
+	var running, peak int32
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = limiter.Do(func() error {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					p := atomic.LoadInt32(&peak)
+					if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	if peak > 2 {
+		t.Errorf("registryLimiter allowed %d concurrent operations, expected at most 2", peak)
+	}
+}
+
+func TestDigestMatches(t *testing.T) {
+	digest := "sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	other := "sha256:" + "fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"
+	ref, err := ParseImageRef("ghcr.io/vupham90/containers-bw-backup@" + digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		repoDigests []string
+		expected    bool
+	}{
+		{name: "match", repoDigests: []string{"ghcr.io/vupham90/containers-bw-backup@" + digest}, expected: true},
+		{name: "match among several", repoDigests: []string{"mirror.example.com/bw@" + other, "ghcr.io/vupham90/containers-bw-backup@" + digest}, expected: true},
+		{name: "different digest", repoDigests: []string{"ghcr.io/vupham90/containers-bw-backup@" + other}},
+		{name: "different repository", repoDigests: []string{"ghcr.io/attacker/containers-bw-backup@" + digest}},
+		{name: "no digests", repoDigests: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := digestMatches(ref, tt.repoDigests); got != tt.expected {
+				t.Errorf("digestMatches() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestVerifyImageDigest(t *testing.T) {
+	digest := "sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	// The fake engine resolves every image to the digest above
+	script := "#!/bin/sh\necho '[\"ghcr.io/example/tool@" + digest + "\"]'\n"
+	fakeDocker(t, script)
+
+	if err := verifyImageDigest("ghcr.io/example/tool:latest"); err != nil {
+		t.Errorf("verifyImageDigest() unpinned unexpected error: %v", err)
+	}
+	if err := verifyImageDigest("ghcr.io/example/tool@" + digest); err != nil {
+		t.Errorf("verifyImageDigest() matching unexpected error: %v", err)
+	}
+	tampered := "ghcr.io/example/tool@sha256:" + "fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"
+	if err := verifyImageDigest(tampered); err == nil {
+		t.Error("verifyImageDigest() expected a mismatch error")
+	}
+}
+
+func TestPullMissingImage(t *testing.T) {
+	stubEngineProbe(t, "")
+	savedPolicy, savedRetries, savedTimeout, savedDelay := runtimeSettings.PullPolicy, runtimeSettings.Retries, runtimeSettings.Timeout, retryBaseDelay
+	defer func() {
+		runtimeSettings.PullPolicy, runtimeSettings.Retries, runtimeSettings.Timeout, retryBaseDelay = savedPolicy, savedRetries, savedTimeout, savedDelay
+	}()
+	retryBaseDelay = time.Millisecond
+
+	// Pulls fail with a network error until the pull count in $FAKE_DOCKER_LOG reaches
+	// $FAKE_PULL_OK_AFTER; FAKE_PULL_HANG=1 makes them hang instead
+	script := `#!/bin/sh
+echo "$@" >> "$FAKE_DOCKER_LOG"
+if [ "$1" = "image" ] && [ "$FAKE_IMAGE_PRESENT" != 1 ]; then exit 1; fi
+if [ "$1" = "pull" ]; then
+	[ "$FAKE_PULL_HANG" = 1 ] && exec sleep 10
+	if [ "$(grep -c '^pull' "$FAKE_DOCKER_LOG")" -lt "${FAKE_PULL_OK_AFTER:-1}" ]; then
+		echo "Error response from daemon: Get https://ghcr.io/v2/: net/http: TLS handshake timeout" >&2
+		exit 1
+	fi
+fi
+`
+	fakeDocker(t, script)
+
+	tests := []struct {
+		name      string
+		policy    string
+		present   string
+		okAfter   string
+		hang      string
+		retries   int
+		timeout   time.Duration
+		pulls     int
+		expectErr bool
+	}{
+		{name: "missing image is pulled", policy: "missing", pulls: 1},
+		{name: "present image is not pulled", policy: "missing", present: "1"},
+		{name: "never skips the pull", policy: "never"},
+		{name: "always leaves the pull to docker run", policy: "always"},
+		{name: "transient failure is retried", policy: "missing", okAfter: "2", retries: 2, pulls: 2},
+		{name: "failure without retries is left to docker run", policy: "missing", okAfter: "2", pulls: 1},
+		{name: "hanging pull times out", policy: "missing", hang: "1", timeout: 200 * time.Millisecond, pulls: 1, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logPath := filepath.Join(t.TempDir(), "log")
+			t.Setenv("FAKE_DOCKER_LOG", logPath)
+			t.Setenv("FAKE_IMAGE_PRESENT", tt.present)
+			t.Setenv("FAKE_PULL_OK_AFTER", tt.okAfter)
+			t.Setenv("FAKE_PULL_HANG", tt.hang)
+			runtimeSettings.PullPolicy, runtimeSettings.Retries, runtimeSettings.Timeout = tt.policy, tt.retries, tt.timeout
+
+			err := pullMissingImage(context.Background(), "ghcr.io/example/tool:latest")
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("pullMissingImage() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if tt.expectErr && !errors.Is(err, ErrTimeout) {
+				t.Errorf("pullMissingImage() error = %v, expected a timeout", err)
+			}
+			data, _ := os.ReadFile(logPath)
+			if pulls := strings.Count(string(data), "pull ghcr.io/example/tool:latest"); pulls != tt.pulls {
+				t.Errorf("pulled %d times, expected %d; engine calls:\n%s", pulls, tt.pulls, data)
+			}
+		})
+	}
+
+	t.Run("cancelled context stops the pull", func(t *testing.T) {
+		t.Setenv("FAKE_DOCKER_LOG", filepath.Join(t.TempDir(), "log"))
+		t.Setenv("FAKE_PULL_HANG", "1")
+		runtimeSettings.PullPolicy, runtimeSettings.Timeout = "missing", 0
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(200*time.Millisecond, cancel)
+		if err := pullMissingImage(ctx, "ghcr.io/example/tool:latest"); !isInterrupted(err) {
+			t.Errorf("pullMissingImage() error = %v, expected an interrupted pull", err)
+		}
+	})
+}
+
+func TestWithDigest(t *testing.T) {
+	digest := "sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	got, err := withDigest(bwBackupImage, digest)
+	if err != nil {
+		t.Fatalf("withDigest() unexpected error: %v", err)
+	}
+	if expected := "ghcr.io/vupham90/containers-bw-backup@" + digest; got != expected {
+		t.Errorf("withDigest() = %s, expected %s", got, expected)
+	}
+	if _, err := withDigest(bwBackupImage, "sha256:short"); err == nil {
+		t.Error("withDigest() expected error for an invalid digest")
+	}
+}
+
+func TestEffectiveImages(t *testing.T) {
+	digest := "sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	savedConfig := appConfig
+	defer func() { appConfig = savedConfig }()
+
+	tests := []struct {
+		name      string
+		commands  map[string]map[string]any
+		expected  map[string]string
+		expectErr bool
+	}{
+		{name: "defaults", expected: map[string]string{"bw-backup": bwBackupImage, "ibgateway": ibGatewayImage}},
+		{
+			name: "config overrides",
+			commands: map[string]map[string]any{
+				"bw-backup": {"image-digest": digest},
+				"ibgateway": {"image": "ghcr.io/example/gateway@" + digest},
+			},
+			expected: map[string]string{
+				"bw-backup": "ghcr.io/vupham90/containers-bw-backup@" + digest,
+				"ibgateway": "ghcr.io/example/gateway@" + digest,
+			},
+		},
+		{name: "invalid digest", commands: map[string]map[string]any{"bw-backup": {"image-digest": "sha256:short"}}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appConfig = AppConfig{Commands: tt.commands}
+			images, err := effectiveImages()
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("effectiveImages() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			for _, image := range images {
+				if expected, ok := tt.expected[image.Purpose]; ok && image.Ref != expected {
+					t.Errorf("%s image = %s, expected %s", image.Purpose, image.Ref, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestImagesPullDryRun(t *testing.T) {
+	logPath := fakeDocker(t, fakeDockerScript)
+	if err := runAppCommand(t, "images pull", []string{"--dry-run", "images", "pull"}, nil); err != nil {
+		t.Fatalf("dry run error = %v", err)
+	}
+	if _, err := os.Stat(logPath); !os.IsNotExist(err) {
+		log, _ := os.ReadFile(logPath)
+		t.Errorf("dry run called docker: %s", log)
+	}
+}
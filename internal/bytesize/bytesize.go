@@ -0,0 +1,89 @@
+// Package bytesize parses and formats human-readable byte sizes using binary (1024-based) units.
+package bytesize
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	KiB int64 = 1 << (10 * (iota + 1))
+	MiB
+	GiB
+	TiB
+)
+
+// unitMultipliers maps accepted unit suffixes (lowercase) to their byte multipliers
+var unitMultipliers = map[string]int64{
+	"":    1,
+	"b":   1,
+	"k":   KiB,
+	"kb":  KiB,
+	"kib": KiB,
+	"m":   MiB,
+	"mb":  MiB,
+	"mib": MiB,
+	"g":   GiB,
+	"gb":  GiB,
+	"gib": GiB,
+	"t":   TiB,
+	"tb":  TiB,
+	"tib": TiB,
+}
+
+// ParseSize parses sizes like "512m", "2g", "1.5G" or "1024" into a byte count.
+// Units are case-insensitive and always binary, matching docker's --memory semantics.
+func ParseSize(s string) (int64, error) {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return 0, fmt.Errorf("invalid size: empty string")
+	}
+
+	// Split the numeric part from the unit suffix
+	i := 0
+	for i < len(trimmed) && (trimmed[i] >= '0' && trimmed[i] <= '9' || trimmed[i] == '.') {
+		i++
+	}
+	number, unit := trimmed[:i], strings.ToLower(strings.TrimSpace(trimmed[i:]))
+
+	multiplier, ok := unitMultipliers[unit]
+	if !ok || number == "" {
+		return 0, fmt.Errorf("invalid size: %q", s)
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size: %q", s)
+	}
+
+	bytes := value * float64(multiplier)
+	if bytes > math.MaxInt64 {
+		return 0, fmt.Errorf("size out of range: %q", s)
+	}
+
+	return int64(bytes), nil
+}
+
+// FormatSize renders a byte count with one decimal in the largest fitting binary unit, e.g. "1.5 GiB"
+func FormatSize(bytes int64) string {
+	sign := ""
+	if bytes < 0 {
+		sign = "-"
+		bytes = -bytes
+	}
+
+	switch {
+	case bytes >= TiB:
+		return fmt.Sprintf("%s%.1f TiB", sign, float64(bytes)/float64(TiB))
+	case bytes >= GiB:
+		return fmt.Sprintf("%s%.1f GiB", sign, float64(bytes)/float64(GiB))
+	case bytes >= MiB:
+		return fmt.Sprintf("%s%.1f MiB", sign, float64(bytes)/float64(MiB))
+	case bytes >= KiB:
+		return fmt.Sprintf("%s%.1f KiB", sign, float64(bytes)/float64(KiB))
+	default:
+		return fmt.Sprintf("%s%d B", sign, bytes)
+	}
+}
@@ -0,0 +1,69 @@
+package bytesize
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+		wantErr  bool
+	}{
+		{input: "0", expected: 0},
+		{input: "1024", expected: 1024},
+		{input: "512m", expected: 512 * MiB},
+		{input: "2g", expected: 2 * GiB},
+		{input: "1.5G", expected: GiB + GiB/2},
+		{input: "100M", expected: 100 * MiB},
+		{input: "50mb", expected: 50 * MiB},
+		{input: "4KiB", expected: 4 * KiB},
+		{input: " 10 k ", expected: 10 * KiB},
+		{input: "", wantErr: true},
+		{input: "abc", wantErr: true},
+		{input: "m", wantErr: true},
+		{input: "1.2.3g", wantErr: true},
+		{input: "10x", wantErr: true},
+		{input: "-5m", wantErr: true},
+		{input: "99999999999t", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := ParseSize(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseSize(%q) expected error, got %d", tt.input, result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSize(%q) unexpected error: %v", tt.input, err)
+			}
+			if result != tt.expected {
+				t.Errorf("ParseSize(%q) = %d, expected %d", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		input    int64
+		expected string
+	}{
+		{input: 0, expected: "0 B"},
+		{input: 1023, expected: "1023 B"},
+		{input: KiB, expected: "1.0 KiB"},
+		{input: 1536 * KiB, expected: "1.5 MiB"},
+		{input: GiB + GiB/2, expected: "1.5 GiB"},
+		{input: 3 * TiB, expected: "3.0 TiB"},
+		{input: -2 * MiB, expected: "-2.0 MiB"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if result := FormatSize(tt.input); result != tt.expected {
+				t.Errorf("FormatSize(%d) = %q, expected %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+// Package logging is a small leveled logger for diagnostics, written to stderr so stdout stays usable in pipelines.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Level selects how much diagnostic output is written
+type Level int
+
+const (
+	LevelInfo    Level = iota // Default: no diagnostics
+	LevelVerbose              // -v: resolved paths and the decisions made from them
+	LevelDebug                // -vv or --debug: mounts and keychain service/account names (never values)
+)
+
+var (
+	mu    sync.Mutex // Serializes lines so concurrent callers never interleave
+	level            = LevelInfo
+	out   io.Writer  = os.Stderr
+)
+
+// SetLevel sets the most detailed level that is written
+func SetLevel(l Level) {
+	mu.Lock()
+	defer mu.Unlock()
+	level = l
+}
+
+// SetOutput redirects log lines, returning the previous writer
+func SetOutput(w io.Writer) io.Writer {
+	mu.Lock()
+	defer mu.Unlock()
+	previous := out
+	out = w
+	return previous
+}
+
+// Enabled reports whether lines at level l are written
+func Enabled(l Level) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return l <= level
+}
+
+// Verbosef writes a [verbose] line when -v is set
+func Verbosef(format string, args ...any) {
+	logf(LevelVerbose, "verbose", format, args...)
+}
+
+// Debugf writes a [debug] line when -vv or --debug is set
+func Debugf(format string, args ...any) {
+	logf(LevelDebug, "debug", format, args...)
+}
+
+// logf writes one line as a single write if l is enabled
+func logf(l Level, tag, format string, args ...any) {
+	mu.Lock()
+	defer mu.Unlock()
+	if l > level {
+		return
+	}
+	io.WriteString(out, "["+tag+"] "+fmt.Sprintf(format, args...)+"\n")
+}
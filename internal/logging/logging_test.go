@@ -0,0 +1,35 @@
+package logging
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLevels(t *testing.T) {
+	var buf bytes.Buffer
+	previous := SetOutput(&buf)
+	defer SetOutput(previous)
+	defer SetLevel(LevelInfo)
+
+	tests := []struct {
+		name  string
+		level Level
+		want  string
+	}{
+		{name: "info", level: LevelInfo, want: ""},
+		{name: "verbose", level: LevelVerbose, want: "[verbose] path=/a\n"},
+		{name: "debug", level: LevelDebug, want: "[verbose] path=/a\n[debug] account=b\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			SetLevel(tt.level)
+			Verbosef("path=%s", "/a")
+			Debugf("account=%s", "b")
+			if buf.String() != tt.want {
+				t.Errorf("output = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
@@ -1,57 +1,192 @@
 package keychain
 
 import (
+	"errors"
 	"fmt"
-	"os/exec"
+	"os"
 	"strings"
+	"sync"
 	"syscall"
 
+	"github.com/vupham90/containers/internal/logging"
 	"golang.org/x/term"
 )
 
-// getPassword retrieves a password from macOS Keychain
-func getPassword(serviceName, account string) (string, error) {
-	cmd := exec.Command("security", "find-generic-password", "-a", account, "-s", serviceName, "-w")
-	output, err := cmd.Output()
-	if err != nil {
-		return "", fmt.Errorf("failed to retrieve %s from Keychain: %w", account, err)
+// The platform backend (keychain_<os>.go) provides backendName, protectedItemsSupported and the getPassword, setPassword,
+// setProtectedPassword, passwordExists, deletePassword and listAccounts helpers; everything here is shared.
+
+// mu serializes all Keychain access so concurrent callers queue instead of
+// triggering simultaneous backend invocations and duplicate auth dialogs
+var mu sync.Mutex
+
+// backendEnvVar selects an alternative credential source; "env" reads accounts from environment variables first
+const backendEnvVar = "CONTAINERS_KEYCHAIN_BACKEND"
+
+// envBackendEnabled reports whether CONTAINERS_KEYCHAIN_BACKEND=env is set
+func envBackendEnabled() bool {
+	return os.Getenv(backendEnvVar) == "env"
+}
+
+// accountEnvVar derives the environment variable consulted for an account in env mode,
+// e.g. work_bitwarden_client_id → WORK_BITWARDEN_CLIENT_ID
+func accountEnvVar(account string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, account)
+}
+
+// envPassword returns the account's environment variable in env mode; unset or empty variables don't count
+func envPassword(account string) (string, bool) {
+	if !envBackendEnabled() {
+		return "", false
+	}
+	value := os.Getenv(accountEnvVar(account))
+	if value != "" {
+		logging.Debugf("keychain: account %s read from %s", account, accountEnvVar(account))
 	}
-	return strings.TrimSpace(string(output)), nil
+	return value, value != ""
 }
 
-// setPassword stores or updates a password in macOS Keychain
-func setPassword(serviceName, account, password string) error {
-	cmd := exec.Command("security", "add-generic-password", "-a", account, "-s", serviceName, "-w", password, "-U")
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to set password for %s in Keychain: %w", account, err)
+// ErrNotTerminal is returned instead of blocking when a password prompt has no terminal to read from
+var ErrNotTerminal = errors.New("cannot prompt for password: stdin is not a terminal")
+
+// stdinIsTerminal reports whether the user can be prompted; tests replace it
+var stdinIsTerminal = func() bool {
+	return term.IsTerminal(int(syscall.Stdin))
+}
+
+// checkCanPrompt fails in env mode when stdin is not a terminal, naming the variable that would avoid the prompt
+func checkCanPrompt(account string) error {
+	if envBackendEnabled() && !stdinIsTerminal() {
+		return fmt.Errorf("%s is not set and stdin is not a terminal, so the password for '%s' cannot be prompted for", accountEnvVar(account), account)
 	}
 	return nil
 }
 
-// passwordExists checks if a password exists in Keychain for the given account
-func passwordExists(serviceName, account string) bool {
-	cmd := exec.Command("security", "find-generic-password", "-a", account, "-s", serviceName)
-	return cmd.Run() == nil
+// GetPassword retrieves a password from the environment (env mode) or the platform keychain
+func GetPassword(serviceName, account string) (string, error) {
+	if password, ok := envPassword(account); ok {
+		return password, nil
+	}
+	logging.Debugf("keychain: reading service=%s account=%s from %s", serviceName, account, backendName)
+	mu.Lock()
+	defer mu.Unlock()
+	return getPassword(serviceName, account)
+}
+
+// SetPassword stores or updates a password in the platform keychain
+func SetPassword(serviceName, account, password string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	return setPassword(serviceName, account, password)
+}
+
+// ErrProtectionUnsupported is returned by SetProtectedPassword on backends without per-item authorization
+var ErrProtectionUnsupported = errors.New("authorization-protected items are only supported by the macOS Keychain")
+
+// SetProtectedPassword prompts for a new password and stores it so every read needs the user's approval in a
+// macOS authorization dialog (login password, or Touch ID where macOS offers it)
+func SetProtectedPassword(serviceName, account string) error {
+	// Fail before prompting rather than after the password has been typed twice
+	if !protectedItemsSupported {
+		return fmt.Errorf("cannot protect %s in %s: %w", account, backendName, ErrProtectionUnsupported)
+	}
+	return storeNewPassword(serviceName, account, setProtectedPassword)
+}
+
+// PromptAndSetPassword prompts for a new password and stores it like SetPassword
+func PromptAndSetPassword(serviceName, account string) error {
+	return storeNewPassword(serviceName, account, setPassword)
 }
 
-// deletePassword removes a password from macOS Keychain
-func deletePassword(serviceName, account string) error {
-	cmd := exec.Command("security", "delete-generic-password", "-a", account, "-s", serviceName)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to delete password for %s from Keychain: %w", account, err)
+// storeNewPassword prompts for a password for account and saves it with store
+func storeNewPassword(serviceName, account string, store func(serviceName, account, password string) error) error {
+	mu.Lock()
+	defer mu.Unlock()
+	password, err := promptNewPassword(fmt.Sprintf("Enter password for '%s': ", account), nil)
+	if err != nil {
+		return promptHint(account, err)
+	}
+	if err := store(serviceName, account, password); err != nil {
+		return err
 	}
+	fmt.Printf("Password for '%s' saved to %s.\n", account, backendName)
 	return nil
 }
 
-// GetOrSetPassword retrieves a password from Keychain, or prompts the user to set it if it doesn't exist.
+// HasPassword reports whether a password is stored for the account, without reading or prompting for it
+func HasPassword(serviceName, account string) bool {
+	if _, ok := envPassword(account); ok {
+		return true
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return passwordExists(serviceName, account)
+}
+
+// ListAccounts returns the account names of all passwords stored under serviceName, never their values
+func ListAccounts(serviceName string) ([]string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	return listAccounts(serviceName)
+}
+
+// ErrNotFound is returned by DeletePassword when no password is stored for the account
+var ErrNotFound = errors.New("no password stored")
+
+// DeletePassword removes a stored password from the platform keychain, returning ErrNotFound if there is none.
+// Environment variables read in env mode are never touched.
+func DeletePassword(serviceName, account string) error {
+	logging.Debugf("keychain: deleting service=%s account=%s from %s", serviceName, account, backendName)
+	mu.Lock()
+	defer mu.Unlock()
+	if !passwordExists(serviceName, account) {
+		return fmt.Errorf("%w for %s in %s", ErrNotFound, account, backendName)
+	}
+	return deletePassword(serviceName, account)
+}
+
+// GetOrSetPassword retrieves a password from the keychain, or prompts the user to set it if it doesn't exist.
 // If reset is true, it will delete the existing password and prompt for a new one.
+// With CONTAINERS_KEYCHAIN_BACKEND=env, the account's environment variable is used first if set.
+// The lock is held across the prompt so concurrent callers never prompt at the same time.
 func GetOrSetPassword(serviceName, account string, reset bool) (string, error) {
+	return GetOrSetPasswordChecked(serviceName, account, reset, nil)
+}
+
+// GetOrSetPasswordChecked is GetOrSetPassword with a strength check applied to newly entered passwords.
+// check returns a non-empty warning for weak passwords; the user is warned but may keep the password.
+func GetOrSetPasswordChecked(serviceName, account string, reset bool, check func(string) string) (string, error) {
+	// In env mode an injected variable wins over the keychain, and reset does not apply to it
+	if password, ok := envPassword(account); ok {
+		return password, nil
+	}
+
+	logging.Debugf("keychain: looking up service=%s account=%s in %s (reset=%t)", serviceName, account, backendName, reset)
+	mu.Lock()
+	defer mu.Unlock()
+
 	// If reset flag is set, delete existing and re-enter
 	if reset {
+		if err := checkCanPrompt(account); err != nil {
+			return "", err
+		}
+		// Never delete the old value when no new one can be entered
+		if !stdinIsTerminal() {
+			return "", promptHint(account, ErrNotTerminal)
+		}
 		if passwordExists(serviceName, account) {
 			_ = deletePassword(serviceName, account)
 		}
-		return updatePassword(serviceName, account)
+		password, err := updatePassword(serviceName, account, check)
+		return password, promptHint(account, err)
 	}
 
 	// Try to retrieve from Keychain
@@ -60,38 +195,139 @@ func GetOrSetPassword(serviceName, account string, reset bool) (string, error) {
 	}
 
 	// Password doesn't exist, prompt user to set it
-	fmt.Printf("Password for '%s' not found in Keychain.\n", account)
-	password, err := promptPassword(fmt.Sprintf("Enter password for '%s': ", account))
-	if err != nil {
+	if err := checkCanPrompt(account); err != nil {
 		return "", err
 	}
+	fmt.Printf("Password for '%s' not found in %s.\n", account, backendName)
+	password, err := promptNewPassword(fmt.Sprintf("Enter password for '%s': ", account), check)
+	if err != nil {
+		return "", promptHint(account, err)
+	}
 
 	// Store in Keychain
 	if err := setPassword(serviceName, account, password); err != nil {
-		return "", fmt.Errorf("failed to save password to Keychain: %w", err)
+		return "", fmt.Errorf("failed to save password to %s: %w", backendName, err)
 	}
 
-	fmt.Printf("Password for '%s' saved to Keychain.\n", account)
+	fmt.Printf("Password for '%s' saved to %s.\n", account, backendName)
 	return password, nil
 }
 
-// updatePassword updates a password in Keychain, prompting the user for a new value
-func updatePassword(serviceName, account string) (string, error) {
-	password, err := promptPassword(fmt.Sprintf("Enter new password for '%s': ", account))
+// UpdatePasswords prompts for a new value for every account, then stores them all.
+// Nothing is stored unless every prompt succeeds, and if storing one fails the accounts already
+// written are restored to their previous values (or deleted if they had none).
+func UpdatePasswords(serviceName string, accounts []string) error {
+	logging.Debugf("keychain: updating service=%s accounts=%s in %s", serviceName, strings.Join(accounts, ","), backendName)
+	mu.Lock()
+	defer mu.Unlock()
+
+	passwords := make([]string, len(accounts))
+	for i, account := range accounts {
+		password, err := promptNewPassword(fmt.Sprintf("Enter new password for '%s': ", account), nil)
+		if err != nil {
+			return promptHint(account, err)
+		}
+		passwords[i] = password
+	}
+
+	// Keep the previous values so a failed write can be rolled back
+	previous := make([]*string, len(accounts))
+	for i, account := range accounts {
+		if !passwordExists(serviceName, account) {
+			continue
+		}
+		old, err := getPassword(serviceName, account)
+		if err != nil {
+			return err
+		}
+		previous[i] = &old
+	}
+
+	for i, account := range accounts {
+		if err := setPassword(serviceName, account, passwords[i]); err != nil {
+			rollbackPasswords(serviceName, accounts[:i], previous[:i])
+			return fmt.Errorf("failed to update password in %s, earlier accounts were restored: %w", backendName, err)
+		}
+	}
+	return nil
+}
+
+// rollbackPasswords restores accounts to their previous values, deleting those that had none; callers must hold mu
+func rollbackPasswords(serviceName string, accounts []string, previous []*string) {
+	for i, account := range accounts {
+		var err error
+		if previous[i] == nil {
+			err = deletePassword(serviceName, account)
+		} else {
+			err = setPassword(serviceName, account, *previous[i])
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to restore '%s' in %s: %v\n", account, backendName, err)
+		}
+	}
+}
+
+// promptHint explains how to supply account without a prompt when err is ErrNotTerminal
+func promptHint(account string, err error) error {
+	if !errors.Is(err, ErrNotTerminal) {
+		return err
+	}
+	return fmt.Errorf("%w: '%s' is not in %s; pass it as a flag, or set %s=env and %s", err, account, backendName, backendEnvVar, accountEnvVar(account))
+}
+
+// updatePassword updates a password in the keychain, prompting the user for a new value; callers must hold mu
+func updatePassword(serviceName, account string, check func(string) string) (string, error) {
+	password, err := promptNewPassword(fmt.Sprintf("Enter new password for '%s': ", account), check)
 	if err != nil {
 		return "", err
 	}
 
 	if err := setPassword(serviceName, account, password); err != nil {
-		return "", fmt.Errorf("failed to update password in Keychain: %w", err)
+		return "", fmt.Errorf("failed to update password in %s: %w", backendName, err)
 	}
 
-	fmt.Printf("Password for '%s' updated in Keychain.\n", account)
+	fmt.Printf("Password for '%s' updated in %s.\n", account, backendName)
 	return password, nil
 }
 
-// promptPassword reads a password from stdin securely without echoing
-func promptPassword(prompt string) (string, error) {
+// readPassword is the prompt used for new passwords; tests replace it
+var readPassword = PromptPassword
+
+// promptNewPassword asks for a password twice and loops until both entries match,
+// so a typo is never stored. Empty entries are rejected; weak ones only warn.
+func promptNewPassword(prompt string, check func(string) string) (string, error) {
+	for {
+		password, err := readPassword(prompt)
+		if err != nil {
+			return "", err
+		}
+		if password == "" {
+			fmt.Println("Password must not be empty, please try again.")
+			continue
+		}
+		if check != nil {
+			if warning := check(password); warning != "" {
+				fmt.Printf("Warning: %s\n", warning)
+			}
+		}
+
+		confirmation, err := readPassword("Confirm password: ")
+		if err != nil {
+			return "", err
+		}
+		if confirmation == password {
+			return password, nil
+		}
+		fmt.Println("Passwords do not match, please try again.")
+	}
+}
+
+// PromptPassword reads a password from stdin securely without echoing.
+// It fails with ErrNotTerminal rather than blocking when stdin is not a terminal (cron, CI).
+func PromptPassword(prompt string) (string, error) {
+	if !stdinIsTerminal() {
+		return "", ErrNotTerminal
+	}
 	fmt.Print(prompt)
 	bytePassword, err := term.ReadPassword(int(syscall.Stdin))
 	fmt.Println() // Print newline after password input
@@ -0,0 +1,125 @@
+package keychain
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// protectedItemsSupported reports whether setProtectedPassword can store items at all
+const protectedItemsSupported = true
+
+// backendName names the credential store in messages
+const backendName = "macOS Keychain"
+
+// getPassword retrieves a password; callers must hold mu.
+// Items stored with SetProtectedPassword make macOS show an authorization dialog, which this waits on.
+func getPassword(serviceName, account string) (string, error) {
+	cmd := exec.Command("security", "find-generic-password", "-a", account, "-s", serviceName, "-w")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve %s from %s: %w%s", account, backendName, err, authorizationHint(stderr.String()))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// authorizationHint explains a read that failed at the macOS authorization dialog, or returns ""
+func authorizationHint(stderr string) string {
+	switch {
+	case strings.Contains(stderr, "User interaction is not allowed"):
+		return " (the item requires authorization, but no dialog can be shown in this session, e.g. over SSH or from cron)"
+	case strings.Contains(stderr, "User canceled"), strings.Contains(stderr, "passphrase you entered is not correct"):
+		return " (authorization was denied)"
+	}
+	return ""
+}
+
+// setProtectedPassword stores a password that no application may read without the user's approval; callers must hold mu.
+// `security` trusts the app creating an item by default, so an empty -T list is what forces the dialog. The ACL is
+// only applied when the item is created, so an existing item is deleted first.
+func setProtectedPassword(serviceName, account, password string) error {
+	if passwordExists(serviceName, account) {
+		if err := deletePassword(serviceName, account); err != nil {
+			return err
+		}
+	}
+	cmd := exec.Command("security", "add-generic-password", "-a", account, "-s", serviceName, "-w", password, "-T", "")
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to set protected password for %s in %s: %w", account, backendName, err)
+	}
+	return nil
+}
+
+// setPassword stores or updates a password; callers must hold mu
+func setPassword(serviceName, account, password string) error {
+	cmd := exec.Command("security", "add-generic-password", "-a", account, "-s", serviceName, "-w", password, "-U")
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to set password for %s in %s: %w", account, backendName, err)
+	}
+	return nil
+}
+
+// passwordExists checks if a password exists in the Keychain for the given account; callers must hold mu
+func passwordExists(serviceName, account string) bool {
+	cmd := exec.Command("security", "find-generic-password", "-a", account, "-s", serviceName)
+	return cmd.Run() == nil
+}
+
+// deletePassword removes a password from macOS Keychain; callers must hold mu
+func deletePassword(serviceName, account string) error {
+	cmd := exec.Command("security", "delete-generic-password", "-a", account, "-s", serviceName)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to delete password for %s from %s: %w", account, backendName, err)
+	}
+	return nil
+}
+
+// listAccounts parses the attribute dump of `security dump-keychain`, which never includes secret values;
+// callers must hold mu
+func listAccounts(serviceName string) ([]string, error) {
+	cmd := exec.Command("security", "dump-keychain")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump %s: %w", backendName, err)
+	}
+	return parseDumpAccounts(string(output), serviceName), nil
+}
+
+// parseDumpAccounts extracts account names for serviceName from `security dump-keychain` output.
+// Each item starts with a "keychain:" line followed by its attributes, e.g. "acct"<blob>="name".
+func parseDumpAccounts(dump, serviceName string) []string {
+	var accounts []string
+	var account, service string
+	flush := func() {
+		if service == serviceName && account != "" {
+			accounts = append(accounts, account)
+		}
+		account, service = "", ""
+	}
+
+	for _, line := range strings.Split(dump, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "keychain:"):
+			flush()
+		case strings.HasPrefix(line, `"acct"<blob>=`):
+			account = unquoteAttribute(strings.TrimPrefix(line, `"acct"<blob>=`))
+		case strings.HasPrefix(line, `"svce"<blob>=`):
+			service = unquoteAttribute(strings.TrimPrefix(line, `"svce"<blob>=`))
+		}
+	}
+	flush()
+
+	return accounts
+}
+
+// unquoteAttribute strips the surrounding quotes from a dump-keychain attribute value
+func unquoteAttribute(value string) string {
+	if value == "<NULL>" {
+		return ""
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(value, `"`), `"`)
+}
@@ -0,0 +1,92 @@
+package keychain
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// protectedItemsSupported reports whether setProtectedPassword can store items at all
+const protectedItemsSupported = false
+
+// backendName names the credential store in messages
+const backendName = "Secret Service (secret-tool)"
+
+// secretTool runs libsecret's secret-tool, explaining how to install it when it is missing
+func secretTool(stdin string, args ...string) ([]byte, error) {
+	cmd := exec.Command("secret-tool", args...)
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+	output, err := cmd.Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, fmt.Errorf("secret-tool not found on PATH (install libsecret-tools / libsecret): %w", err)
+	}
+	return output, err
+}
+
+// getPassword retrieves a password; callers must hold mu
+func getPassword(serviceName, account string) (string, error) {
+	output, err := secretTool("", "lookup", "service", serviceName, "account", account)
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve %s from %s: %w", account, backendName, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// setPassword stores or updates a password; callers must hold mu.
+// The secret is passed on stdin so it never appears in the process list.
+func setPassword(serviceName, account, password string) error {
+	label := fmt.Sprintf("%s: %s", serviceName, account)
+	if _, err := secretTool(password, "store", "--label="+label, "service", serviceName, "account", account); err != nil {
+		return fmt.Errorf("failed to set password for %s in %s: %w", account, backendName, err)
+	}
+	return nil
+}
+
+// setProtectedPassword always fails: the Secret Service has no per-item authorization prompt (see protectedItemsSupported)
+func setProtectedPassword(_, account, _ string) error {
+	return fmt.Errorf("failed to set protected password for %s: %w", account, ErrProtectionUnsupported)
+}
+
+// passwordExists checks if a password exists for the given account; callers must hold mu
+func passwordExists(serviceName, account string) bool {
+	_, err := secretTool("", "lookup", "service", serviceName, "account", account)
+	return err == nil
+}
+
+// deletePassword removes a password from the Secret Service; callers must hold mu
+func deletePassword(serviceName, account string) error {
+	if _, err := secretTool("", "clear", "service", serviceName, "account", account); err != nil {
+		return fmt.Errorf("failed to delete password for %s from %s: %w", account, backendName, err)
+	}
+	return nil
+}
+
+// listAccounts returns the account attribute of every item stored under serviceName; callers must hold mu
+func listAccounts(serviceName string) ([]string, error) {
+	output, err := secretTool("", "search", "--all", "service", serviceName)
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(output) == 0 {
+			// secret-tool exits non-zero when nothing matches
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to search %s: %w", backendName, err)
+	}
+	return parseSearchAccounts(string(output)), nil
+}
+
+// parseSearchAccounts extracts account names from `secret-tool search` output, where each item
+// lists its attributes as "attribute.account = name". Secret lines are ignored.
+func parseSearchAccounts(output string) []string {
+	var accounts []string
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), " = ")
+		if ok && key == "attribute.account" && value != "" {
+			accounts = append(accounts, value)
+		}
+	}
+	return accounts
+}
@@ -0,0 +1,160 @@
+package keychain
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseSearchAccounts(t *testing.T) {
+	output := `[/org/freedesktop/secrets/collection/login/1]
+label = containers-bw-backup: bitwarden_client_id
+secret = user.1234
+created = 2026-01-02 10:00:00
+modified = 2026-01-02 10:00:00
+schema = org.freedesktop.Secret.Generic
+attribute.service = containers-bw-backup
+attribute.account = bitwarden_client_id
+[/org/freedesktop/secrets/collection/login/2]
+label = containers-bw-backup: work_bitwarden_password
+secret = attribute.account = not-an-account
+attribute.account = work_bitwarden_password
+attribute.service = containers-bw-backup
+`
+	expected := []string{"bitwarden_client_id", "work_bitwarden_password"}
+	if accounts := parseSearchAccounts(output); !reflect.DeepEqual(accounts, expected) {
+		t.Errorf("parseSearchAccounts() = %v, expected %v", accounts, expected)
+	}
+	if accounts := parseSearchAccounts(""); accounts != nil {
+		t.Errorf("parseSearchAccounts(\"\") = %v, expected nil", accounts)
+	}
+}
+
+// fakeSecretStore puts a secret-tool on PATH that keeps each account in a file under the returned dir.
+// Storing the account "unwritable" fails.
+func fakeSecretStore(t *testing.T) string {
+	dir := t.TempDir()
+	store := filepath.Join(dir, "store")
+	if err := os.Mkdir(store, 0700); err != nil {
+		t.Fatal(err)
+	}
+	script := "#!/bin/sh\n" +
+		"case \"$1\" in\n" +
+		"lookup) cat " + store + "/\"$5\" 2>/dev/null ;;\n" +
+		"store) [ \"$6\" = unwritable ] && exit 1; cat > " + store + "/\"$6\" ;;\n" +
+		"clear) rm -f " + store + "/\"$5\" ;;\n" +
+		"esac\n"
+	if err := os.WriteFile(filepath.Join(dir, "secret-tool"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return store
+}
+
+func TestUpdatePasswords(t *testing.T) {
+	tests := []struct {
+		name      string
+		accounts  []string
+		entries   []string
+		expected  map[string]string
+		expectErr bool
+	}{
+		{
+			name:     "all updated",
+			accounts: []string{"id", "secret"},
+			entries:  []string{"new-id", "new-id", "new-secret", "new-secret"},
+			expected: map[string]string{"id": "new-id", "secret": "new-secret", "old": "old-value"},
+		},
+		{
+			name:      "failed prompt stores nothing",
+			accounts:  []string{"id", "secret"},
+			entries:   []string{"new-id", "new-id"},
+			expected:  map[string]string{"id": "old-id", "old": "old-value"},
+			expectErr: true,
+		},
+		{
+			name:      "failed write restores earlier accounts",
+			accounts:  []string{"id", "secret", "unwritable"},
+			entries:   []string{"a", "a", "b", "b", "c", "c"},
+			expected:  map[string]string{"id": "old-id", "old": "old-value"},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := fakeSecretStore(t)
+			for account, value := range map[string]string{"id": "old-id", "old": "old-value"} {
+				if err := os.WriteFile(filepath.Join(store, account), []byte(value), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var prompts int
+			readPassword = func(string) (string, error) {
+				if prompts == len(tt.entries) {
+					return "", ErrNotTerminal
+				}
+				entry := tt.entries[prompts]
+				prompts++
+				return entry, nil
+			}
+			defer func() { readPassword = PromptPassword }()
+
+			if err := UpdatePasswords("svc", tt.accounts); (err != nil) != tt.expectErr {
+				t.Fatalf("UpdatePasswords() error = %v, expectErr %v", err, tt.expectErr)
+			}
+
+			got := make(map[string]string)
+			entries, err := os.ReadDir(store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range entries {
+				data, err := os.ReadFile(filepath.Join(store, entry.Name()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got[entry.Name()] = string(data)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("stored = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDeletePassword(t *testing.T) {
+	store := fakeSecretStore(t)
+	if err := os.WriteFile(filepath.Join(store, "stored"), []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DeletePassword("svc", "stored"); err != nil {
+		t.Errorf("DeletePassword() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(store, "stored")); !os.IsNotExist(err) {
+		t.Errorf("stored account still exists after DeletePassword()")
+	}
+	if err := DeletePassword("svc", "stored"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeletePassword() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSetProtectedPasswordUnsupported(t *testing.T) {
+	store := fakeSecretStore(t)
+	readPassword = func(string) (string, error) {
+		t.Error("SetProtectedPassword() prompted on a backend that cannot protect items")
+		return "s3cret", nil
+	}
+	defer func() { readPassword = PromptPassword }()
+
+	if err := SetProtectedPassword("svc", "id"); !errors.Is(err, ErrProtectionUnsupported) {
+		t.Errorf("SetProtectedPassword() error = %v, want ErrProtectionUnsupported", err)
+	}
+	if _, err := os.Stat(filepath.Join(store, "id")); !os.IsNotExist(err) {
+		t.Errorf("SetProtectedPassword() stored an unprotected item")
+	}
+}
@@ -0,0 +1,47 @@
+//go:build !darwin && !linux
+
+package keychain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// protectedItemsSupported reports whether setProtectedPassword can store items at all
+const protectedItemsSupported = false
+
+// backendName names the credential store in messages
+const backendName = "keychain"
+
+// errNoBackend is returned on platforms without a supported credential store
+var errNoBackend = errors.New("no keychain backend on this platform (supported: macOS Keychain, Linux Secret Service); pass credentials as flags instead")
+
+// getPassword always fails: there is no backend to read from
+func getPassword(_, account string) (string, error) {
+	return "", fmt.Errorf("failed to retrieve %s: %w", account, errNoBackend)
+}
+
+// setPassword always fails: there is no backend to write to
+func setPassword(_, account, _ string) error {
+	return fmt.Errorf("failed to set password for %s: %w", account, errNoBackend)
+}
+
+// setProtectedPassword always fails: nothing can be stored
+func setProtectedPassword(_, account, _ string) error {
+	return fmt.Errorf("failed to set protected password for %s: %w", account, errNoBackend)
+}
+
+// passwordExists reports false: nothing can be stored
+func passwordExists(_, _ string) bool {
+	return false
+}
+
+// deletePassword always fails: there is no backend to delete from
+func deletePassword(_, account string) error {
+	return fmt.Errorf("failed to delete password for %s: %w", account, errNoBackend)
+}
+
+// listAccounts always fails: there is no backend to list
+func listAccounts(_ string) ([]string, error) {
+	return nil, errNoBackend
+}
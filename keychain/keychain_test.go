@@ -0,0 +1,115 @@
+package keychain
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPromptNewPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		entries  []string
+		expected string
+		prompts  int
+		warnings int
+	}{
+		{name: "matching entries", entries: []string{"s3cret", "s3cret"}, expected: "s3cret", prompts: 2},
+		{name: "typo is re-prompted", entries: []string{"s3cret", "s3cert", "s3cret", "s3cret"}, expected: "s3cret", prompts: 4},
+		{name: "empty entry is re-prompted", entries: []string{"", "s3cret", "s3cret"}, expected: "s3cret", prompts: 3},
+		{name: "weak password warns", entries: []string{"weak", "weak"}, expected: "weak", prompts: 2, warnings: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var prompts int
+			readPassword = func(string) (string, error) {
+				entry := tt.entries[prompts]
+				prompts++
+				return entry, nil
+			}
+			defer func() { readPassword = PromptPassword }()
+
+			var warnings int
+			check := func(password string) string {
+				if password == "weak" {
+					warnings++
+					return "too weak"
+				}
+				return ""
+			}
+
+			password, err := promptNewPassword("Enter password: ", check)
+			if err != nil {
+				t.Fatalf("promptNewPassword() unexpected error: %v", err)
+			}
+			if password != tt.expected {
+				t.Errorf("promptNewPassword() = %q, expected %q", password, tt.expected)
+			}
+			if prompts != tt.prompts {
+				t.Errorf("prompted %d times, expected %d", prompts, tt.prompts)
+			}
+			if warnings != tt.warnings {
+				t.Errorf("warned %d times, expected %d", warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestAccountEnvVar(t *testing.T) {
+	tests := map[string]string{
+		"bitwarden_client_id":       "BITWARDEN_CLIENT_ID",
+		"work_bitwarden_password":   "WORK_BITWARDEN_PASSWORD",
+		"bitwarden_password_team-a": "BITWARDEN_PASSWORD_TEAM_A",
+	}
+	for account, expected := range tests {
+		if got := accountEnvVar(account); got != expected {
+			t.Errorf("accountEnvVar(%q) = %q, expected %q", account, got, expected)
+		}
+	}
+}
+
+func TestEnvBackend(t *testing.T) {
+	t.Setenv("BITWARDEN_CLIENT_ID", "user.1234")
+
+	// Without opting in, environment variables are never consulted
+	t.Setenv(backendEnvVar, "")
+	if _, ok := envPassword("bitwarden_client_id"); ok {
+		t.Error("envPassword() used the environment without CONTAINERS_KEYCHAIN_BACKEND=env")
+	}
+
+	t.Setenv(backendEnvVar, "env")
+	password, err := GetOrSetPassword("containers-test", "bitwarden_client_id", true)
+	if err != nil || password != "user.1234" {
+		t.Errorf("GetOrSetPassword() = %q, %v, expected the environment value", password, err)
+	}
+	if !HasPassword("containers-test", "bitwarden_client_id") {
+		t.Error("HasPassword() = false, expected true for an environment-provided account")
+	}
+
+	// Without a terminal, a missing variable must fail instead of prompting
+	savedIsTerminal := stdinIsTerminal
+	defer func() { stdinIsTerminal = savedIsTerminal }()
+	stdinIsTerminal = func() bool { return false }
+	_, err = GetOrSetPassword("containers-test", "bitwarden_missing", true)
+	if err == nil || !strings.Contains(err.Error(), "BITWARDEN_MISSING is not set") {
+		t.Errorf("GetOrSetPassword() error = %v, expected a missing-variable error", err)
+	}
+}
+
+func TestPromptPasswordWithoutTerminal(t *testing.T) {
+	savedIsTerminal := stdinIsTerminal
+	defer func() { stdinIsTerminal = savedIsTerminal }()
+	stdinIsTerminal = func() bool { return false }
+	t.Setenv(backendEnvVar, "")
+
+	if _, err := PromptPassword("Enter password: "); !errors.Is(err, ErrNotTerminal) {
+		t.Errorf("PromptPassword() error = %v, expected ErrNotTerminal", err)
+	}
+
+	// The error bubbles up through GetOrSetPassword with a hint naming the account
+	_, err := GetOrSetPassword("containers-test-nonexistent", "bitwarden_absent", false)
+	if !errors.Is(err, ErrNotTerminal) || !strings.Contains(err.Error(), "BITWARDEN_ABSENT") {
+		t.Errorf("GetOrSetPassword() error = %v, expected ErrNotTerminal with a hint", err)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v2"
+	"github.com/vupham90/containers/keychain"
+)
+
+// bwCredentialAccounts lists the base keychain accounts bw-backup writes, before prefix and profile are added
+var bwCredentialAccounts = append(slices.Clone(profileCredentialAccounts), "bitwarden_backup_password")
+
+// keychainAccount is a stored account name split back into the parts keychainAccountName joined
+type keychainAccount struct {
+	Name       string
+	Prefix     string
+	Credential string // Base account, or "" if the name is not one bw-backup builds
+	Profile    string
+}
+
+// parseKeychainAccount splits an account name of the form [prefix_]credential[_profile]
+func parseKeychainAccount(name string) keychainAccount {
+	account := keychainAccount{Name: name}
+	for _, base := range bwCredentialAccounts {
+		i := strings.Index(name, base)
+		if i < 0 {
+			continue
+		}
+		before, after := name[:i], name[i+len(base):]
+		if (before != "" && !strings.HasSuffix(before, "_")) || (after != "" && !strings.HasPrefix(after, "_")) {
+			continue
+		}
+		account.Credential = base
+		account.Prefix = strings.TrimSuffix(before, "_")
+		account.Profile = strings.TrimPrefix(after, "_")
+		return account
+	}
+	return account
+}
+
+// runKeychainList prints the account names stored under the bw-backup service, never their values
+func runKeychainList(c *cli.Context) error {
+	names, err := keychain.ListAccounts(bwKeychainService)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		fmt.Printf("No accounts stored under service %s\n", bwKeychainService)
+		return nil
+	}
+
+	accounts := make([]keychainAccount, 0, len(names))
+	for _, name := range names {
+		accounts = append(accounts, parseKeychainAccount(name))
+	}
+	// Group by prefix and profile so each profile's credentials are listed together
+	sort.Slice(accounts, func(i, j int) bool {
+		a, b := accounts[i], accounts[j]
+		if a.Prefix != b.Prefix {
+			return a.Prefix < b.Prefix
+		}
+		if a.Profile != b.Profile {
+			return a.Profile < b.Profile
+		}
+		return a.Name < b.Name
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ACCOUNT\tCREDENTIAL\tPROFILE\tPREFIX")
+	for _, account := range accounts {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", account.Name, orDash(account.Credential), orDash(account.Profile), orDash(account.Prefix))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d account(s) under service %s\n", len(accounts), bwKeychainService)
+	return nil
+}
+
+// orDash shows empty table cells as "-"
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// runKeychainSet prompts for and stores a single keychain entry, optionally gated by an authorization dialog
+func runKeychainSet(c *cli.Context) error {
+	service, account := c.String("service"), c.String("account")
+	if account == "" {
+		return fmt.Errorf("--account is required")
+	}
+	if c.Bool("biometric") {
+		if err := keychain.SetProtectedPassword(service, account); err != nil {
+			return err
+		}
+		fmt.Printf("Reading '%s' now requires your approval each time.\n", account)
+		return nil
+	}
+	return keychain.PromptAndSetPassword(service, account)
+}
+
+// runKeychainDelete removes a single keychain entry, e.g. one left behind by a renamed profile
+func runKeychainDelete(c *cli.Context) error {
+	account := c.String("account")
+	if account == "" {
+		return fmt.Errorf("--account is required")
+	}
+	return deleteKeychainAccounts(c.String("service"), []string{account}, c.Bool("yes"))
+}
+
+// runKeychainReset removes the Bitwarden API client ID, client secret and master password stored for a profile
+func runKeychainReset(c *cli.Context) error {
+	if !c.IsSet("profile") {
+		return fmt.Errorf("--profile is required (pass --profile \"\" for the default profile)")
+	}
+	profile := c.String("profile")
+	prefix := c.String("keychain-account-prefix")
+	accounts := make([]string, 0, len(profileCredentialAccounts))
+	for _, base := range profileCredentialAccounts {
+		accounts = append(accounts, keychainAccountName(prefix, base, profile))
+	}
+	return deleteKeychainAccounts(bwKeychainService, accounts, c.Bool("yes"))
+}
+
+// deleteKeychainAccounts deletes the accounts under service after confirmation, reporting each one as
+// removed or not found. Only backend failures make it return an error; missing entries do not.
+func deleteKeychainAccounts(service string, accounts []string, yes bool) error {
+	fmt.Printf("Keychain entries to delete from service %s:\n", service)
+	for _, account := range accounts {
+		fmt.Printf("  %s\n", account)
+	}
+	if runtimeSettings.DryRun {
+		return nil
+	}
+	if !yes {
+		confirmed, err := confirm(fmt.Sprintf("Delete %d keychain entr(ies)?", len(accounts)))
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Aborted")
+			return nil
+		}
+	}
+
+	var removed, notFound, failed int
+	for _, account := range accounts {
+		err := keychain.DeletePassword(service, account)
+		switch {
+		case err == nil:
+			removed++
+			fmt.Printf("  %s removed %s\n", markOK(), account)
+		case errors.Is(err, keychain.ErrNotFound):
+			notFound++
+			fmt.Printf("  %s not found %s\n", colorWarning("-"), account)
+		default:
+			failed++
+			fmt.Fprintf(os.Stderr, "  %s %s: %v\n", markFail(), account, err)
+		}
+	}
+	fmt.Printf("\n%d removed, %d not found\n", removed, notFound)
+
+	if failed > 0 {
+		return fmt.Errorf("failed to delete %d keychain entr(ies)", failed)
+	}
+	return nil
+}
+
+// runBwRotate replaces a profile's Bitwarden API client ID, client secret and master password in one go,
+// storing none of them unless all three are entered
+func runBwRotate(c *cli.Context) error {
+	profile := c.String("profile")
+	prefix := c.String("keychain-account-prefix")
+	accounts := make([]string, 0, len(profileCredentialAccounts))
+	for _, base := range profileCredentialAccounts {
+		accounts = append(accounts, keychainAccountName(prefix, base, profile))
+	}
+
+	fmt.Printf("Rotating Bitwarden credentials in service %s:\n", bwKeychainService)
+	for _, account := range accounts {
+		fmt.Printf("  %s\n", account)
+	}
+	if runtimeSettings.DryRun {
+		return nil
+	}
+
+	if err := keychain.UpdatePasswords(bwKeychainService, accounts); err != nil {
+		return fmt.Errorf("failed to rotate credentials: %w", err)
+	}
+	fmt.Println("\nUpdated:")
+	for _, account := range accounts {
+		fmt.Printf("  %s %s\n", markOK(), account)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestParseKeychainAccount(t *testing.T) {
+	tests := []struct {
+		name string
+		want keychainAccount
+	}{
+		{name: "bitwarden_client_id", want: keychainAccount{Credential: "bitwarden_client_id"}},
+		{name: "bitwarden_password_personal", want: keychainAccount{Credential: "bitwarden_password", Profile: "personal"}},
+		{name: "work_bitwarden_client_secret", want: keychainAccount{Prefix: "work", Credential: "bitwarden_client_secret"}},
+		{name: "work_bitwarden_client_id_team_a", want: keychainAccount{Prefix: "work", Credential: "bitwarden_client_id", Profile: "team_a"}},
+		{name: "work_bitwarden_backup_password", want: keychainAccount{Prefix: "work", Credential: "bitwarden_backup_password"}},
+		{name: "finance_backup_password", want: keychainAccount{}},
+		{name: "xbitwarden_password", want: keychainAccount{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.want.Name = tt.name
+			if got := parseKeychainAccount(tt.name); got != tt.want {
+				t.Errorf("parseKeychainAccount() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// reservedLabelPrefix namespaces the labels the tool sets itself; --label may not use it
+const reservedLabelPrefix = "containers."
+
+var (
+	runID     string
+	runIDOnce sync.Once
+)
+
+// currentRunID returns a random ID shared by every container started by this invocation
+func currentRunID() string {
+	runIDOnce.Do(func() {
+		b := make([]byte, 6)
+		if _, err := rand.Read(b); err != nil {
+			return
+		}
+		runID = hex.EncodeToString(b)
+	})
+	return runID
+}
+
+// toolLabels returns the default labels for containers started by the named command, e.g.
+// containers.tool=bw-backup, so `docker ps --filter label=containers.tool=bw-backup` finds them
+func toolLabels(tool string) map[string]string {
+	labels := map[string]string{
+		"containers.tool":    tool,
+		"containers.version": version,
+	}
+	if id := currentRunID(); id != "" {
+		labels["containers.run-id"] = id
+	}
+	return labels
+}
+
+// parseLabels converts --label KEY=VALUE strings into a map, rejecting the reserved containers. prefix
+func parseLabels(assignments []string) (map[string]string, error) {
+	labels := make(map[string]string, len(assignments))
+	for _, assignment := range assignments {
+		key, value, _ := strings.Cut(assignment, "=")
+		if key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("invalid label: %s (expected KEY=VALUE)", assignment)
+		}
+		if strings.HasPrefix(key, reservedLabelPrefix) {
+			return nil, fmt.Errorf("invalid label: %s (the %s prefix is reserved for labels set by this tool)", assignment, reservedLabelPrefix)
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+// labelArgs returns --label flags for the command's labels plus the global --label ones, sorted by key
+func labelArgs(labels map[string]string) []string {
+	merged := make(map[string]string, len(labels)+len(runtimeSettings.Labels))
+	for key, value := range labels {
+		merged[key] = value
+	}
+	for key, value := range runtimeSettings.Labels {
+		merged[key] = value
+	}
+
+	keys := make([]string, 0, len(merged))
+	for key := range merged {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	args := make([]string, 0, 2*len(keys))
+	for _, key := range keys {
+		args = append(args, "--label", key+"="+merged[key])
+	}
+	return args
+}
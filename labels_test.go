@@ -0,0 +1,50 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLabels(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     []string
+		expected  map[string]string
+		expectErr bool
+	}{
+		{name: "key and value", input: []string{"team=infra", "cost-center="}, expected: map[string]string{"team": "infra", "cost-center": ""}},
+		{name: "key only", input: []string{"backup"}, expected: map[string]string{"backup": ""}},
+		{name: "empty key", input: []string{"=x"}, expectErr: true},
+		{name: "reserved prefix", input: []string{"containers.tool=x"}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLabels(tt.input)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("parseLabels() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if !tt.expectErr && !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("parseLabels() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLabelArgs(t *testing.T) {
+	saved := runtimeSettings.Labels
+	defer func() { runtimeSettings.Labels = saved }()
+	runtimeSettings.Labels = map[string]string{"team": "infra"}
+
+	labels := toolLabels("bw-backup")
+	if labels["containers.run-id"] == "" || labels["containers.run-id"] != toolLabels("ibgateway")["containers.run-id"] {
+		t.Errorf("toolLabels() run IDs = %q, expected one non-empty ID per process", labels["containers.run-id"])
+	}
+
+	got := strings.Join(labelArgs(labels), " ")
+	expected := "--label containers.run-id=" + currentRunID() + " --label containers.tool=bw-backup --label containers.version=" + version + " --label team=infra"
+	if got != expected {
+		t.Errorf("labelArgs() = %q, expected %q", got, expected)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// archiveExt is appended to log files once they have been compressed
+const archiveExt = ".gz"
+
+// logArchiveResult lists what a logs-archive run changed, relative to the log directory
+type logArchiveResult struct {
+	Compressed []string
+	Deleted    []string
+}
+
+// runLogsArchive rotates the log files below a directory, e.g. an ibgateway --log-dir
+func runLogsArchive(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("expected 1 argument: log directory")
+	}
+	if c.Duration("older-than") < 0 || c.Duration("retention") < 0 {
+		return fmt.Errorf("--older-than and --retention must not be negative")
+	}
+	patterns := c.StringSlice("pattern")
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	dir, err := expandHome(c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	result, err := archiveLogs(dir, patterns, c.Duration("older-than"), c.Duration("retention"), time.Now())
+	if runtimeSettings.DryRun {
+		for _, path := range result.Compressed {
+			fmt.Printf("Dry run: compress %s\n", strings.TrimSuffix(path, archiveExt))
+		}
+		for _, path := range result.Deleted {
+			fmt.Printf("Dry run: delete %s\n", path)
+		}
+		return err
+	}
+	for _, path := range result.Compressed {
+		fmt.Printf("  %s compressed %s\n", markOK(), path)
+	}
+	for _, path := range result.Deleted {
+		fmt.Printf("  %s deleted %s\n", markOK(), path)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Compressed %d log file(s), deleted %d expired archive(s)\n", len(result.Compressed), len(result.Deleted))
+	return nil
+}
+
+// archiveLogs gzips files matching patterns that have not been modified for olderThan and deletes
+// archives of matching files whose (preserved) modification time is older than retention. A zero
+// retention keeps archives. A dry run only reports what would change.
+func archiveLogs(dir string, patterns []string, olderThan, retention time.Duration, now time.Time) (logArchiveResult, error) {
+	var result logArchiveResult
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		age := now.Sub(info.ModTime())
+
+		// Only archives of files matching patterns are expired; other gzip files are left alone
+		if logName, ok := strings.CutSuffix(entry.Name(), archiveExt); ok {
+			if retention > 0 && age > retention && matchesAnyPattern(logName, patterns) {
+				if !runtimeSettings.DryRun {
+					if err := os.Remove(path); err != nil {
+						return fmt.Errorf("failed to delete expired archive: %w", err)
+					}
+				}
+				result.Deleted = append(result.Deleted, rel)
+			}
+			return nil
+		}
+
+		if age < olderThan || !matchesAnyPattern(entry.Name(), patterns) {
+			return nil
+		}
+		if !runtimeSettings.DryRun {
+			if err := gzipFile(path); err != nil {
+				return err
+			}
+		}
+		result.Compressed = append(result.Compressed, rel+archiveExt)
+		return nil
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to archive logs in %s: %w", dir, err)
+	}
+	return result, nil
+}
+
+// matchesAnyPattern reports whether name matches one of the filename globs
+func matchesAnyPattern(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipFile replaces path with path.gz, keeping its mode and modification time so retention
+// is measured from when the log was last written
+func gzipFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer src.Close()
+
+	tmpPath := path + archiveExt + ".tmp"
+	dst, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer os.Remove(tmpPath)
+
+	zw := gzip.NewWriter(dst)
+	zw.Name = filepath.Base(path)
+	zw.ModTime = info.ModTime()
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		return fmt.Errorf("failed to compress %s: %w", path, err)
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		return fmt.Errorf("failed to compress %s: %w", path, err)
+	}
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	if err := os.Chtimes(tmpPath, info.ModTime(), info.ModTime()); err != nil {
+		return fmt.Errorf("failed to preserve modification time: %w", err)
+	}
+	if err := os.Rename(tmpPath, path+archiveExt); err != nil {
+		return fmt.Errorf("failed to save archive: %w", err)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove compressed log file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestArchiveLogs(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	write := func(name, content string, age time.Duration) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		modTime := now.Add(-age)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("launcher.log", "old log", 48*time.Hour)
+	write("user/ibgateway.20260201.txt", "old nested log", 72*time.Hour)
+	write("current.log", "still being written", time.Hour)
+	write("jts.ini", "settings are never touched", 48*time.Hour)
+	write("expired.log.gz", "", 100*24*time.Hour)
+	write("recent.log.gz", "", 10*24*time.Hour)
+	write("exports/settings.tar.gz", "not a log archive", 100*24*time.Hour)
+
+	result, err := archiveLogs(dir, []string{"*.log", "*.txt"}, 24*time.Hour, 90*24*time.Hour, now)
+	if err != nil {
+		t.Fatalf("archiveLogs() unexpected error: %v", err)
+	}
+
+	expectedCompressed := []string{"launcher.log.gz", filepath.Join("user", "ibgateway.20260201.txt.gz")}
+	if !reflect.DeepEqual(result.Compressed, expectedCompressed) {
+		t.Errorf("Compressed = %v, expected %v", result.Compressed, expectedCompressed)
+	}
+	if !reflect.DeepEqual(result.Deleted, []string{"expired.log.gz"}) {
+		t.Errorf("Deleted = %v, expected [expired.log.gz]", result.Deleted)
+	}
+
+	for _, name := range []string{"launcher.log", "expired.log.gz"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s should have been removed", name)
+		}
+	}
+	for _, name := range []string{"current.log", "jts.ini", "recent.log.gz", filepath.Join("exports", "settings.tar.gz")} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s should have been kept: %v", name, err)
+		}
+	}
+
+	archive := filepath.Join(dir, "launcher.log.gz")
+	info, err := os.Stat(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(now.Add(-48 * time.Hour)) {
+		t.Errorf("archive mtime = %v, expected the original log's mtime", info.ModTime())
+	}
+	f, err := os.Open(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("archive is not gzip: %v", err)
+	}
+	content, err := io.ReadAll(zr)
+	if err != nil || string(content) != "old log" {
+		t.Errorf("archive content = %q (%v), expected %q", content, err, "old log")
+	}
+}
+
+func TestArchiveLogsDryRun(t *testing.T) {
+	runtimeSettings.DryRun = true
+	defer func() { runtimeSettings.DryRun = false }()
+
+	dir := t.TempDir()
+	now := time.Now()
+	old := now.Add(-100 * 24 * time.Hour)
+	for _, name := range []string{"launcher.log", "expired.log.gz"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := archiveLogs(dir, []string{"*.log"}, 24*time.Hour, 90*24*time.Hour, now)
+	if err != nil {
+		t.Fatalf("archiveLogs() unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(result.Compressed, []string{"launcher.log.gz"}) || !reflect.DeepEqual(result.Deleted, []string{"expired.log.gz"}) {
+		t.Errorf("result = %+v, expected launcher.log compressed and expired.log.gz deleted", result)
+	}
+	for _, name := range []string{"launcher.log", "expired.log.gz"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("dry run touched %s: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "launcher.log.gz")); !os.IsNotExist(err) {
+		t.Errorf("dry run wrote an archive (stat error %v)", err)
+	}
+}
@@ -1,106 +1,324 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/urfave/cli/v2"
+	"github.com/vupham90/containers/internal/logging"
 )
 
+// errorFormat controls how the top-level error handler prints failures ("text" or "json")
+var errorFormat = "text"
+
 func main() {
+	app := newApp()
+
+	args, err := expandResponseFiles(os.Args)
+	if err != nil {
+		exitWithError(err)
+	}
+	if values, ok := completeFlags(args, app); ok {
+		fmt.Println(strings.Join(values, "\n"))
+		return
+	}
+
+	if err := app.Run(args); err != nil {
+		exitWithError(err)
+	}
+}
+
+// newApp builds the CLI with its full command tree
+func newApp() *cli.App {
+	// urfave/cli's default --version flag also claims -v, which is --verbose here
+	cli.VersionFlag = &cli.BoolFlag{Name: "version", Usage: "Print the version and build metadata"}
+	cli.VersionPrinter = func(c *cli.Context) { printVersion(c.App.Writer) }
+
+	// Filled in once the command tree exists; Before validates the config file against it
+	var commands map[string]*cli.Command
 	app := &cli.App{
-		Name:  "containers",
-		Usage: "Container-based utility tools",
+		Name:    "containers",
+		Usage:   "Container-based utility tools",
+		Version: version,
+		// Lets -vv stand for -v -v
+		UseShortOptionHandling: true,
+		// Answers --generate-bash-completion for the scripts printed by `containers completion`
+		EnableBashCompletion: true,
+		// Repeatable flags take one value each, so commas in -e KEY=a,b or --tmpfs options survive
+		DisableSliceFlagSeparator: true,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "config",
+				EnvVars: []string{"CONTAINERS_CONFIG"},
+				Usage:   "YAML file with flag defaults (a missing default file is ignored)",
+				Value:   defaultAppConfigPath,
+			},
+			&cli.StringSliceFlag{
+				Name:    "allowed-registry",
+				EnvVars: []string{"CONTAINERS_ALLOWED_REGISTRIES"},
+				Usage:   "Registry images may be run from, repeatable (replaces the default; include ghcr.io to keep it)",
+				Value:   cli.NewStringSlice(runtimeSettings.AllowedRegistries...),
+			},
+			&cli.StringFlag{
+				Name:  "error-format",
+				Usage: "Error output format on stderr: text or json",
+				Value: "text",
+			},
+			&cli.BoolFlag{
+				Name:  "keep-container",
+				Usage: "Keep one-shot containers after they exit (for debugging)",
+			},
+			&cli.BoolFlag{
+				Name:  "show-changes",
+				Usage: "Print filesystem changes of kept containers (requires --keep-container)",
+			},
+			&cli.BoolFlag{
+				Name:  "events-jsonl",
+				Usage: "Emit one JSON object per event on stdout in long-running modes (human output moves to stderr)",
+			},
+			&cli.StringFlag{
+				Name:  "color",
+				Usage: "Colorize status output: auto, always, never (auto honours NO_COLOR and disables color unless stdout and stderr are terminals)",
+				Value: "auto",
+			},
+			&cli.BoolFlag{
+				Name:    "quiet",
+				Aliases: []string{"q"},
+				Usage:   "Suppress informational output such as the executed engine command",
+			},
+			&cli.BoolFlag{
+				Name:    "verbose",
+				Aliases: []string{"v"},
+				Usage:   "Log resolved paths to stderr; repeat (-vv) to also log mounts and keychain account names",
+				Count:   new(int),
+			},
+			&cli.BoolFlag{
+				Name:  "debug",
+				Usage: "Same as -vv",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Print the (redacted) container engine commands instead of running them",
+			},
+			&cli.StringFlag{
+				Name:  "pull",
+				Usage: "Image pull policy for containers: always, missing or never (never fails early if the image is not present locally)",
+				Value: "missing",
+			},
+			&cli.DurationFlag{
+				Name:  "timeout",
+				Usage: "Stop and remove containers that run longer than this, e.g. 30m (0 means no limit)",
+			},
+			&cli.IntFlag{
+				Name:  "retries",
+				Usage: "Retry a run up to `N` times, with backoff, after transient pull or network failures",
+			},
+			&cli.StringSliceFlag{
+				Name:  "label",
+				Usage: "Extra label KEY=VALUE for every container started, repeatable (containers.* keys are reserved)",
+			},
+			&cli.StringSliceFlag{
+				Name:  "engine-arg",
+				Usage: "Top-level flag passed to the container engine before the subcommand, e.g. --config=/path (repeatable)",
+			},
+		},
+		Before: func(c *cli.Context) error {
+			// Config file values become defaults before any flag below is read
+			config, err := loadAppConfig(c.String("config"), c.IsSet("config"))
+			if err != nil {
+				return err
+			}
+			if err := validateAppConfig(config, commands); err != nil {
+				return err
+			}
+			appConfig = config
+			if err := applyConfigDefaults(c, c.App.Flags, config.Global, "global"); err != nil {
+				return err
+			}
+
+			switch format := c.String("error-format"); format {
+			case "text", "json":
+				errorFormat = format
+			default:
+				return fmt.Errorf("invalid error format: %s (must be 'text' or 'json')", format)
+			}
+			color, err := resolveColor(c.String("color"), outputIsTerminal(), os.Getenv("NO_COLOR") != "")
+			if err != nil {
+				return err
+			}
+			colorOutput = color
+			if c.Bool("show-changes") && !c.Bool("keep-container") {
+				return fmt.Errorf("--show-changes requires --keep-container")
+			}
+			if err := validateEngineArgs(c.StringSlice("engine-arg")); err != nil {
+				return err
+			}
+			if err := validatePullPolicy(c.String("pull")); err != nil {
+				return err
+			}
+			if c.Duration("timeout") < 0 {
+				return fmt.Errorf("invalid timeout: %s (must not be negative)", c.Duration("timeout"))
+			}
+			labels, err := parseLabels(c.StringSlice("label"))
+			if err != nil {
+				return err
+			}
+			runtimeSettings.Labels = labels
+			if c.Int("retries") < 0 {
+				return fmt.Errorf("invalid retries: %d (must not be negative)", c.Int("retries"))
+			}
+			runtimeSettings.AllowedRegistries = splitList(c.StringSlice("allowed-registry"))
+			runtimeSettings.EngineArgs = c.StringSlice("engine-arg")
+			runtimeSettings.KeepContainer = c.Bool("keep-container")
+			runtimeSettings.ShowChanges = c.Bool("show-changes")
+			runtimeSettings.Quiet = c.Bool("quiet")
+			runtimeSettings.DryRun = c.Bool("dry-run")
+			runtimeSettings.PullPolicy = c.String("pull")
+			runtimeSettings.Timeout = c.Duration("timeout")
+			runtimeSettings.Retries = c.Int("retries")
+			switch {
+			case c.Bool("debug") || c.Count("verbose") >= 2:
+				logging.SetLevel(logging.LevelDebug)
+			case c.Count("verbose") == 1:
+				logging.SetLevel(logging.LevelVerbose)
+			}
+			if c.Bool("events-jsonl") {
+				// Keep stdout a clean event stream: everything else, including container output, goes to stderr
+				events = newEventEmitter(os.Stdout)
+				os.Stdout = os.Stderr
+			}
+			return nil
+		},
 		Commands: []*cli.Command{
 			{
-				Name:  "pdf-compress",
-				Usage: "Compress PDF files using Ghostscript",
+				Name:    "pdf-compress",
+				Aliases: []string{"pdfc"},
+				Usage:   "Compress PDF files using Ghostscript",
 				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:     "quality",
-						Aliases:  []string{"q"},
+						Aliases:  []string{"Q"},
 						Usage:    "Compression quality: ebook, screen, printer, prepress, default",
 						Value:    "ebook",
 						Required: false,
 					},
+					&cli.StringFlag{
+						Name:  "compat-level",
+						Usage: "PDF compatibility level of the output: 1.4, 1.5, 1.6, 1.7",
+						Value: "1.4",
+					},
+					&cli.StringFlag{
+						Name:  "pdfa",
+						Usage: "Convert to PDF/A with the given conformance level: 1b, 2b, 3b",
+					},
+					&cli.IntFlag{
+						Name:  "dpi",
+						Usage: "Downsample color and gray images to this resolution (30-600), keeping the rest of the --quality preset",
+					},
+					&cli.BoolFlag{
+						Name:  "strip-metadata",
+						Usage: "Blank the title, author, subject, keywords, creator and producer and drop XMP metadata, dates and the document ID",
+					},
+					&cli.StringFlag{
+						Name:  "name-template",
+						Usage: "Output filename template with {base}, {quality}, {date}, {dpi} placeholders",
+						Value: defaultNameTemplate,
+					},
+					&cli.StringFlag{
+						Name:  "memory",
+						Usage: "Container memory limit, e.g. 512m or 2g (default: no limit)",
+					},
+					&cli.StringFlag{
+						Name:  "cpus",
+						Usage: "Container CPU limit, e.g. 1.5 (default: no limit)",
+					},
+					&cli.StringFlag{
+						Name:  "user",
+						Usage: "Run Ghostscript as this uid:gid (default: the current host user, so output files are yours)",
+					},
+					&cli.BoolFlag{
+						Name:  "no-user-mapping",
+						Usage: "Run Ghostscript as the image's user instead of the current host uid:gid",
+					},
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "Write the compressed file to this path (default: <base>_<quality>.pdf next to the input)",
+					},
+					&cli.BoolFlag{
+						Name:    "force",
+						Aliases: []string{"f"},
+						Usage:   "Overwrite the --output file if it already exists",
+					},
+					&cli.StringFlag{
+						Name:  "output-dir",
+						Usage: "Write the compressed file to this directory instead of next to the input",
+					},
+					&cli.StringFlag{
+						Name:  "state-file",
+						Usage: "JSON file recording compressed inputs; unchanged inputs are skipped on later runs",
+					},
+					&cli.BoolFlag{
+						Name:  "in-place",
+						Usage: "Replace the original file with the compressed output",
+					},
+					&cli.BoolFlag{
+						Name:  "backup-original",
+						Usage: "With --in-place, keep the original as <name>.pdf.bak",
+					},
+					&cli.BoolFlag{
+						Name:  "only-if-smaller",
+						Usage: "With --in-place, only replace the original if the compressed output is smaller",
+						Value: true,
+					},
+					&cli.DurationFlag{
+						Name:  "wait-for-file",
+						Usage: "Wait until the input's size and mtime are unchanged for this long before compressing (watch mode default: 1.5s)",
+					},
+					&cli.DurationFlag{
+						Name:  "wait-timeout",
+						Usage: "Fail if the input is still changing after this long",
+						Value: 5 * time.Minute,
+					},
+					&cli.BoolFlag{
+						Name:    "recursive",
+						Aliases: []string{"r"},
+						Usage:   "With a directory argument, also compress PDFs in its subdirectories",
+					},
+					&cli.BoolFlag{
+						Name:  "follow-symlinks",
+						Usage: "With a directory argument, compress symlinked PDFs too (symlinked directories are never descended into)",
+					},
+					&cli.StringFlag{
+						Name:  "watch",
+						Usage: "Watch a directory and compress PDFs as they arrive (instead of <file-path>)",
+					},
+					&cli.StringFlag{
+						Name:  "watch-output",
+						Usage: "Directory compressed files are moved to in --watch mode",
+					},
 				},
-				ArgsUsage: "<file-path>",
-				Action: func(c *cli.Context) error {
-					if c.NArg() != 1 {
-						return fmt.Errorf("expected 1 argument: file-path")
-					}
-
-					filePath := c.Args().Get(0)
-					quality := c.String("quality")
-
-					// Validate quality
-					validQualities := map[string]bool{
-						"ebook":    true,
-						"screen":   true,
-						"printer":  true,
-						"prepress": true,
-						"default":  true,
-					}
-					if !validQualities[quality] {
-						return fmt.Errorf("invalid quality: %s", quality)
-					}
-
-					// Resolve absolute path
-					absFilePath, err := filepath.Abs(filePath)
-					if err != nil {
-						return fmt.Errorf("failed to resolve file path: %w", err)
-					}
-
-					// Verify file exists
-					if _, err := os.Stat(absFilePath); os.IsNotExist(err) {
-						return fmt.Errorf("file does not exist: %s", absFilePath)
-					}
-
-					/*
-						docker run \
-						  --rm \
-						  -v ~/Downloads:/workspace \
-						  -w /workspace \
-						  --entrypoint sh \
-						  ghcr.io/vupham90/containers-pdf-compress:latest \
-						  -c "gs -sDEVICE=pdfwrite -dCompatibilityLevel=1.4 -dPDFSETTINGS=/ebook -o /workspace/out.pdf /workspace/ALPINE.pdf && ls -la /workspace/out.pdf"
-					*/
-
-					// Generate output path
-					dir := filepath.Dir(absFilePath)
-					base := strings.TrimSuffix(filepath.Base(absFilePath), ".pdf")
-					outputFilename := fmt.Sprintf("%s_%s.pdf", base, quality)
-
-					// Prepare Docker arguments for Ghostscript
-					image := "ghcr.io/vupham90/containers-pdf-compress:latest"
-					workDir := dir
-					args := []string{
-						"-sDEVICE=pdfwrite",
-						"-dCompatibilityLevel=1.4",
-						fmt.Sprintf("-dPDFSETTINGS=/%s", quality),
-						"-o", "/workspace/" + outputFilename,
-						"/workspace/" + filepath.Base(absFilePath),
-					}
-					return RunContainer(image, workDir, args, nil, nil, nil, true)
-				},
+				ArgsUsage: "<file-path> | <dir> | --watch <dir> --watch-output <dir>",
+				Action:    runPdfCompress,
 			},
 			{
-				Name:  "ibgateway",
-				Usage: "Start IB Gateway container for Interactive Brokers",
+				Name:    "ibgateway",
+				Aliases: []string{"ibg"},
+				Usage:   "Start IB Gateway container for Interactive Brokers",
 				Flags: []cli.Flag{
 					&cli.StringFlag{
-						Name:     "user",
-						EnvVars:  []string{"TWS_USERID"},
-						Usage:    "Interactive Brokers username",
-						Required: true,
+						Name:    "user",
+						EnvVars: []string{"TWS_USERID"},
+						Usage:   "Interactive Brokers username (required to start the gateway)",
 					},
 					&cli.StringFlag{
-						Name:     "password",
-						EnvVars:  []string{"TWS_PASSWORD"},
-						Usage:    "Interactive Brokers password",
-						Required: true,
+						Name:    "password",
+						EnvVars: []string{"TWS_PASSWORD"},
+						Usage:   "Interactive Brokers password (required to start the gateway)",
 					},
 					&cli.StringFlag{
 						Name:    "mode",
@@ -108,64 +326,177 @@ func main() {
 						Usage:   "Trading mode: paper or live",
 						Value:   "paper",
 					},
+					&cli.StringSliceFlag{
+						Name:  "port",
+						Usage: "Port mapping host:container, repeatable (replaces the default 4001:4003 and 4002:4004)",
+					},
+					&cli.StringSliceFlag{
+						Name:  "env",
+						Usage: "Extra image environment variable KEY=VALUE, repeatable (secret-looking keys are redacted in logs)",
+					},
+					&cli.StringSliceFlag{
+						Name:  "env-prefix",
+						Usage: "Forward all host environment variables starting with PREFIX, repeatable (--env wins on conflicts)",
+					},
 					&cli.StringFlag{
 						Name:  "image",
 						Usage: "Docker image to use",
-						Value: "ghcr.io/gnzsnz/ib-gateway:latest",
+						Value: ibGatewayImage,
 					},
 					&cli.StringFlag{
 						Name:  "name",
 						Usage: "Container name",
 						Value: "ibgateway",
 					},
+					&cli.StringFlag{
+						Name:  "memory",
+						Usage: "Container memory limit, e.g. 512m or 2g (default: no limit)",
+					},
+					&cli.StringFlag{
+						Name:  "cpus",
+						Usage: "Container CPU limit, e.g. 1.5 (default: no limit)",
+					},
+					&cli.StringFlag{
+						Name:  "config-dir",
+						Usage: "Host directory mounted as the gateway's settings directory so settings survive container recreation (created if missing)",
+					},
+					&cli.StringFlag{
+						Name:  "log-dir",
+						Usage: "Host directory mounted as the gateway settings directory so its logs persist (see logs-archive)",
+					},
+					&cli.BoolFlag{
+						Name:  "diff",
+						Usage: "Print configuration changes (values redacted) before recreating an existing container",
+					},
+					&cli.DurationFlag{
+						Name:  "wait-timeout",
+						Usage: "After starting, wait up to this long for the API port to accept connections and print ready, e.g. 3m (default: don't wait)",
+					},
+					&cli.BoolFlag{
+						Name:  "watchdog",
+						Usage: "Stay in the foreground and recreate the container if the gateway dies after becoming ready",
+					},
+					&cli.DurationFlag{
+						Name:  "startup-grace",
+						Usage: "Time after (re)start during which failed probes are not counted",
+						Value: 3 * time.Minute,
+					},
+					&cli.DurationFlag{
+						Name:  "probe-interval",
+						Usage: "Interval between watchdog probes of the API port",
+						Value: 15 * time.Second,
+					},
+					&cli.IntFlag{
+						Name:  "probe-failures",
+						Usage: "Consecutive failed probes of a ready gateway before it is recreated",
+						Value: 3,
+					},
 				},
-				Action: func(c *cli.Context) error {
-					user := c.String("user")
-					password := c.String("password")
-					mode := c.String("mode")
-					image := c.String("image")
-					name := c.String("name")
-
-					// Validate trading mode
-					if mode != "paper" && mode != "live" {
-						return fmt.Errorf("invalid trading mode: %s (must be 'paper' or 'live')", mode)
-					}
-
-					// Configure port mappings
-					ports := map[string]string{
-						"4001": "4003",
-						"4002": "4004",
-					}
-
-					// Configure environment variables
-					env := map[string]EnvVar{
-						"TWS_USERID":   {Value: user, Sensitive: true},
-						"TWS_PASSWORD": {Value: password, Sensitive: true},
-						"TRADING_MODE": {Value: mode, Sensitive: false},
-					}
-
-					fmt.Printf("Starting IB Gateway container '%s' in %s mode...\n", name, mode)
-					return RunDaemon(name, image, ports, env)
+				Subcommands: []*cli.Command{
+					{
+						Name:   "status",
+						Usage:  "Show whether the gateway container is running and its published ports",
+						Flags:  []cli.Flag{gatewayNameFlag()},
+						Action: runIBGatewayStatus,
+					},
+					{
+						Name:  "stop",
+						Usage: "Stop the gateway container",
+						Flags: []cli.Flag{
+							gatewayNameFlag(),
+							&cli.BoolFlag{
+								Name:  "rm",
+								Usage: "Also remove the container after stopping it",
+							},
+						},
+						Action: runIBGatewayStop,
+					},
+					{
+						Name:  "logs",
+						Usage: "Show the gateway container's output",
+						Flags: append([]cli.Flag{gatewayNameFlag()}, logsFlags()...),
+						Action: func(c *cli.Context) error {
+							return ContainerLogs(c.Context, c.String("name"), c.String("tail"), c.Bool("follow"))
+						},
+					},
+					{
+						Name:   "restart",
+						Usage:  "Stop the gateway container and start it again with the configuration it was created with",
+						Flags:  []cli.Flag{gatewayNameFlag()},
+						Action: runIBGatewayRestart,
+					},
 				},
+				Action: runIBGateway,
 			},
 			{
-				Name:  "bw-backup",
-				Usage: "Backup Bitwarden vault to local directory",
-				Flags: []cli.Flag{
+				Name:    "bw-backup",
+				Aliases: []string{"bwb"},
+				Usage:   "Backup Bitwarden vault to local directory",
+				Flags: append([]cli.Flag{
 					&cli.StringFlag{
 						Name:    "profile",
 						Aliases: []string{"P"},
 						Usage:   "Profile name for multi-account support (optional, uses default keychain if empty)",
 					},
+					&cli.StringFlag{
+						Name:  "keychain-account-prefix",
+						Usage: "Namespace prefix prepended to all keychain account names (optional, overrides config file)",
+					},
 					&cli.StringFlag{
 						Name:    "organization-id",
 						Aliases: []string{"o"},
 						Usage:   "Bitwarden organization ID to backup (optional)",
 					},
+					&cli.StringFlag{
+						Name:    "server",
+						EnvVars: []string{"BW_SERVER"},
+						Usage:   "Bitwarden server URL for self-hosted instances such as Vaultwarden (default: bitwarden.com)",
+					},
 					&cli.StringFlag{
 						Name:  "profiles",
 						Usage: "Path to YAML config file for batch backup mode",
 					},
+					&cli.BoolFlag{
+						Name:  "verify",
+						Usage: "Check each finished backup (non-empty, expected header) and record its SHA-256 in a .sha256 sidecar; earlier sidecars of the vault are re-checked",
+					},
+					&cli.StringFlag{
+						Name:  "age-recipient",
+						Usage: "Re-encrypt each finished backup on the host to <file>.age for this age recipient (public key) and delete the plaintext",
+					},
+					&cli.StringFlag{
+						Name:  "s3-bucket",
+						Usage: "Upload each finished backup to this S3 bucket (credentials from the standard AWS chain)",
+					},
+					&cli.StringFlag{
+						Name:  "s3-prefix",
+						Usage: "Key prefix for uploaded backups, e.g. bitwarden/ (requires --s3-bucket)",
+					},
+					&cli.StringFlag{
+						Name:  "s3-endpoint",
+						Usage: "Endpoint URL of an S3-compatible service such as MinIO (requires --s3-bucket)",
+					},
+					&cli.StringFlag{
+						Name:  "s3-region",
+						Usage: "Bucket region (default: from the AWS configuration)",
+					},
+					&cli.BoolFlag{
+						Name:  "s3-path-style",
+						Usage: "Use path-style bucket addressing, which most S3-compatible services need",
+					},
+					&cli.StringFlag{
+						Name:  "report",
+						Usage: "Write a JSON report of the batch backup to FILE (YAML if it ends in .yaml or .yml), even when some vaults fail",
+					},
+					&cli.IntFlag{
+						Name:  "concurrency",
+						Usage: "Number of batch profiles backed up at once (output lines are prefixed with the profile name)",
+						Value: 1,
+					},
+					&cli.StringFlag{
+						Name:  "preflight",
+						Usage: "Validate a batch YAML config (profiles, keychain entries, backup dirs, image) without running a backup",
+					},
 					&cli.StringFlag{
 						Name:    "client-id",
 						Aliases: []string{"c"},
@@ -191,25 +522,567 @@ func main() {
 						Aliases: []string{"bp"},
 						Usage:   "Password for encrypted backup (overrides keychain if provided)",
 					},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "Export format: json, encrypted_json or csv (default: encrypted_json when encrypting, else json)",
+					},
+					&cli.Float64Flag{
+						Name:  "summary-threshold",
+						Usage: "Alert when a backup is more than this percent smaller than the previous one of the same vault (0 disables)",
+					},
+					&cli.BoolFlag{
+						Name:  "summary-threshold-fail",
+						Usage: "Treat --summary-threshold alerts as failures (non-zero exit)",
+					},
 					&cli.StringFlag{
 						Name:    "backup-dir",
 						Aliases: []string{"d"},
 						Usage:   "Backup destination directory (required for single mode)",
 						Value:   "./backups",
 					},
+					&cli.IntFlag{
+						Name:  "keep",
+						Usage: "After a successful backup, keep only the N most recent backups of that vault (0 keeps all)",
+					},
 					&cli.BoolFlag{
 						Name:    "reset",
 						Aliases: []string{"r"},
 						Usage:   "Reset all credentials and re-enter them",
 					},
+					&cli.StringSliceFlag{
+						Name:  "reset-only",
+						Usage: "Reset and re-enter only the named credential, repeatable (client-id, client-secret, password, backup-password)",
+					},
+					&cli.BoolFlag{
+						Name:  "prefix-logs",
+						Usage: "Lead each audit line with a [profile] tag so concurrent profiles stay readable",
+					},
+				}, bitwardenContainerFlags()...),
+				Subcommands: []*cli.Command{
+					{
+						Name:      "list-orgs",
+						Usage:     "List the organizations contained in a backup file (prompts for the password of encrypted backups)",
+						ArgsUsage: "<backup-file>",
+						Flags: []cli.Flag{
+							&cli.StringSliceFlag{
+								Name:  "expect",
+								Usage: "Organization ID that must be present in the backup, repeatable",
+							},
+						},
+						Action: runListBackupOrgs,
+					},
 				},
 				Action: runBwBackup,
 			},
+			{
+				Name:  "bw-rotate",
+				Usage: "Replace the Bitwarden API client ID, client secret and master password stored for a profile",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "profile",
+						Aliases: []string{"P"},
+						Usage:   "Profile whose credentials to rotate (optional, uses default keychain if empty)",
+					},
+					&cli.StringFlag{
+						Name:  "keychain-account-prefix",
+						Usage: "Namespace prefix prepended to all keychain account names (optional)",
+					},
+				},
+				Action: runBwRotate,
+			},
+			{
+				Name:      "bw-restore",
+				Aliases:   []string{"bwr"},
+				Usage:     "Import a Bitwarden backup file into a vault",
+				ArgsUsage: "<backup-file>",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:    "profile",
+						Aliases: []string{"P"},
+						Usage:   "Profile name for multi-account support (optional, uses default keychain if empty)",
+					},
+					&cli.StringFlag{
+						Name:  "keychain-account-prefix",
+						Usage: "Namespace prefix prepended to all keychain account names (optional)",
+					},
+					&cli.StringFlag{
+						Name:    "organization-id",
+						Aliases: []string{"o"},
+						Usage:   "Bitwarden organization ID to import into (optional, default: personal vault)",
+					},
+					&cli.StringFlag{
+						Name:    "server",
+						EnvVars: []string{"BW_SERVER"},
+						Usage:   "Bitwarden server URL for self-hosted instances such as Vaultwarden (default: bitwarden.com)",
+					},
+					&cli.StringFlag{
+						Name:    "client-id",
+						Aliases: []string{"c"},
+						Usage:   "Bitwarden API client ID (optional, uses keychain if not provided)",
+					},
+					&cli.StringFlag{
+						Name:    "client-secret",
+						Aliases: []string{"s"},
+						Usage:   "Bitwarden API client secret (optional, uses keychain if not provided)",
+					},
+					&cli.StringFlag{
+						Name:    "password",
+						Aliases: []string{"p"},
+						Usage:   "Bitwarden master password (optional, uses keychain if not provided)",
+					},
+					&cli.BoolFlag{
+						Name:    "encrypt",
+						Aliases: []string{"e"},
+						Usage:   "Read the password of a password-protected backup from the keychain",
+					},
+					&cli.StringFlag{
+						Name:    "backup-password",
+						Aliases: []string{"bp"},
+						Usage:   "Password of a password-protected backup (overrides keychain if provided)",
+					},
+					&cli.BoolFlag{
+						Name:    "reset",
+						Aliases: []string{"r"},
+						Usage:   "Reset all credentials and re-enter them",
+					},
+					&cli.StringSliceFlag{
+						Name:  "reset-only",
+						Usage: "Reset and re-enter only the named credential, repeatable (client-id, client-secret, password, backup-password)",
+					},
+				}, bitwardenContainerFlags()...),
+				Action: runBwRestore,
+			},
+			{
+				Name:   "self-test",
+				Usage:  "Run a tiny container end-to-end to verify engine, pull, run and mount all work",
+				Action: runSelfTest,
+			},
+			{
+				Name:      "logs-archive",
+				Usage:     "Compress old log files in a directory and delete archives past retention",
+				ArgsUsage: "<dir>",
+				Flags: []cli.Flag{
+					&cli.DurationFlag{
+						Name:  "older-than",
+						Usage: "Compress log files not modified for this long",
+						Value: 24 * time.Hour,
+					},
+					&cli.DurationFlag{
+						Name:  "retention",
+						Usage: "Delete compressed archives older than this (0 keeps them forever)",
+						Value: 90 * 24 * time.Hour,
+					},
+					&cli.StringSliceFlag{
+						Name:  "pattern",
+						Usage: "Filename glob of log files to compress, repeatable",
+						Value: cli.NewStringSlice("*.log", "*.txt"),
+					},
+				},
+				Action: runLogsArchive,
+			},
+			{
+				Name:  "images",
+				Usage: "Manage the container images used by this tool",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "list",
+						Usage: "List every image this tool can run and whether it is pinned by digest",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "require-digest",
+								Usage: "Exit with an error if any image is not pinned by digest",
+							},
+						},
+						Action: runImagesList,
+					},
+					{
+						Name:  "pull",
+						Usage: "Pull every image this tool runs, rate-limited to stay under registry pull limits",
+						Flags: []cli.Flag{
+							&cli.IntFlag{
+								Name:  "registry-concurrency",
+								Usage: "Maximum number of concurrent registry operations",
+								Value: 2,
+							},
+							&cli.DurationFlag{
+								Name:  "registry-delay",
+								Usage: "Minimum delay between starting registry operations",
+								Value: 500 * time.Millisecond,
+							},
+						},
+						Action: runImagesPull,
+					},
+				},
+			},
+			{
+				Name:      "run",
+				Usage:     "Run an arbitrary image with the working directory mounted at /workspace",
+				ArgsUsage: "[--] [container-args...]",
+				Flags: []cli.Flag{
+					// Not Required: the config file may supply it, and required flags are checked before it is applied
+					&cli.StringFlag{
+						Name:  "image",
+						Usage: "Image to run, required (must come from an allowed registry)",
+					},
+					&cli.StringFlag{
+						Name:  "workdir",
+						Usage: "Host directory mounted as /workspace and used as the working directory",
+						Value: ".",
+					},
+					&cli.StringSliceFlag{
+						Name:    "env",
+						Aliases: []string{"e"},
+						Usage:   "Environment variable KEY=VALUE, repeatable (secret-looking keys are redacted in logs)",
+					},
+					&cli.StringSliceFlag{
+						Name:  "env-file",
+						Usage: "File of KEY=VALUE lines added to the environment, repeatable; values are redacted in logs (--env wins on conflicts)",
+					},
+					&cli.StringSliceFlag{
+						Name:  "env-file-plain",
+						Usage: "Like --env-file, but values are shown in logs unless the key looks secret",
+					},
+					&cli.StringSliceFlag{
+						Name:  "tmpfs",
+						Usage: "tmpfs mount /path[:options], repeatable, e.g. /tmp:rw,noexec,nosuid,size=64m",
+					},
+					&cli.StringSliceFlag{
+						Name:  "volume",
+						Usage: "Additional volume mount /host/path:/container/path[:ro|rw], repeatable",
+					},
+					&cli.StringFlag{
+						Name:  "user",
+						Usage: "uid:gid to run as (default: the image's user)",
+					},
+					&cli.StringFlag{
+						Name:  "entrypoint",
+						Usage: "Override the image's entrypoint",
+					},
+					&cli.BoolFlag{
+						Name:  "read-only",
+						Usage: "Mount the container's root filesystem read-only (use --tmpfs for scratch space)",
+					},
+					&cli.BoolFlag{
+						Name:  "no-new-privileges",
+						Usage: "Prevent processes in the container from gaining privileges through setuid binaries",
+					},
+					&cli.StringSliceFlag{
+						Name:  "cap-drop",
+						Usage: "Linux capability to drop, repeatable, e.g. ALL",
+					},
+					&cli.StringSliceFlag{
+						Name:  "cap-add",
+						Usage: "Linux capability to add back after --cap-drop, repeatable, e.g. NET_BIND_SERVICE",
+					},
+					&cli.StringFlag{
+						Name:  "network",
+						Usage: "Network mode: none, bridge, host or a named network (default: the engine's default)",
+					},
+					&cli.StringFlag{
+						Name:  "memory",
+						Usage: "Container memory limit, e.g. 512m or 2g (default: no limit)",
+					},
+					&cli.StringFlag{
+						Name:  "cpus",
+						Usage: "Container CPU limit, e.g. 1.5 (default: no limit)",
+					},
+				},
+				Action: runRun,
+			},
+			{
+				Name:      "rm",
+				Usage:     "Force-remove containers started by this tool, by name or glob",
+				ArgsUsage: "<name-or-pattern>...",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:    "yes",
+						Aliases: []string{"y"},
+						Usage:   "Remove without asking for confirmation",
+					},
+				},
+				Action: runRm,
+			},
+			{
+				Name:      "logs",
+				Usage:     "Show the output of a container, e.g. a daemon started by this tool",
+				ArgsUsage: "<container-name>",
+				Flags:     logsFlags(),
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 1 {
+						return fmt.Errorf("expected 1 argument: container-name")
+					}
+					return ContainerLogs(c.Context, c.Args().First(), c.String("tail"), c.Bool("follow"))
+				},
+			},
+			{
+				Name:      "exec",
+				Usage:     "Run a command inside a running container",
+				ArgsUsage: "<container-name> <command> [args...]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "detach-keys",
+						Usage: "Key sequence for detaching from an interactive session (e.g. ctrl-p,ctrl-q)",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.NArg() < 2 {
+						return fmt.Errorf("expected at least 2 arguments: container-name and command")
+					}
+					return ExecContainer(c.Args().First(), c.Args().Tail(), c.String("detach-keys"))
+				},
+			},
+			{
+				Name:      "cp",
+				Usage:     "Copy files between a container and the host",
+				ArgsUsage: "<container:/path> <local-path> | <local-path> <container:/path>",
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 2 {
+						return fmt.Errorf("expected 2 arguments: source and destination")
+					}
+					return CopyFiles(c.Args().Get(0), c.Args().Get(1))
+				},
+			},
+			{
+				Name:  "creds",
+				Usage: "Migrate bw-backup credentials between machines",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "export",
+						Usage:     "Export all bw-backup Keychain entries to a passphrase-encrypted file",
+						ArgsUsage: "<file-path>",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "force",
+								Usage: "Overwrite the export file if it already exists",
+							},
+						},
+						Action: runCredsExport,
+					},
+					{
+						Name:      "import",
+						Usage:     "Import bw-backup Keychain entries from an encrypted export file",
+						ArgsUsage: "<file-path>",
+						Action:    runCredsImport,
+					},
+				},
+			},
+			{
+				Name:   "version",
+				Usage:  "Print the version, commit, build date and Go version",
+				Action: runVersion,
+			},
+			{
+				Name:      "completion",
+				Usage:     "Print a shell completion script, e.g. source <(containers completion bash)",
+				ArgsUsage: "bash|zsh|fish",
+				Action:    runCompletion,
+			},
+			{
+				Name:  "keychain",
+				Usage: "Inspect, set and delete bw-backup keychain entries",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "list",
+						Usage:  "List the account names stored for bw-backup (values are never shown)",
+						Action: runKeychainList,
+					},
+					{
+						Name:  "set",
+						Usage: "Prompt for and store a single keychain entry",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "service",
+								Usage: "Keychain service to store the account under",
+								Value: bwKeychainService,
+							},
+							&cli.StringFlag{
+								Name:     "account",
+								Usage:    "Account name to store, e.g. bitwarden_password_personal",
+								Required: true,
+							},
+							&cli.BoolFlag{
+								Name:  "biometric",
+								Usage: "Require approval (Touch ID or login password) every time the entry is read (macOS only)",
+							},
+						},
+						Action: runKeychainSet,
+					},
+					{
+						Name:  "delete",
+						Usage: "Delete a single keychain entry",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "service",
+								Usage: "Keychain service the account is stored under",
+								Value: bwKeychainService,
+							},
+							&cli.StringFlag{
+								Name:     "account",
+								Usage:    "Account name to delete (see keychain list)",
+								Required: true,
+							},
+							&cli.BoolFlag{
+								Name:    "yes",
+								Aliases: []string{"y"},
+								Usage:   "Delete without asking for confirmation",
+							},
+						},
+						Action: runKeychainDelete,
+					},
+					{
+						Name:  "reset",
+						Usage: "Delete the Bitwarden client ID, client secret and master password stored for a profile",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "profile",
+								Aliases: []string{"P"},
+								Usage:   "Profile whose credentials to delete (\"\" for the default profile)",
+							},
+							&cli.StringFlag{
+								Name:  "keychain-account-prefix",
+								Usage: "Namespace prefix prepended to the keychain account names (optional)",
+							},
+							&cli.BoolFlag{
+								Name:    "yes",
+								Aliases: []string{"y"},
+								Usage:   "Delete without asking for confirmation",
+							},
+						},
+						Action: runKeychainReset,
+					},
+				},
+			},
 		},
 	}
 
-	if err := app.Run(os.Args); err != nil {
+	commands = commandPaths(app.Commands, "", make(map[string]*cli.Command))
+	installConfigDefaults(commands)
+	installLeafCompletion(commands)
+
+	// Unknown commands fall back to a containers-<name> plugin on PATH
+	app.CommandNotFound = runPlugin
+	// Exit codes and error output are handled below rather than inside app.Run
+	app.ExitErrHandler = func(*cli.Context, error) {}
+	return app
+}
+
+// exitWithError prints err in the configured error format and exits with its exit code
+func exitWithError(err error) {
+	code := 1
+	var exitCoder cli.ExitCoder
+	if errors.As(err, &exitCoder) && exitCoder.ExitCode() != 0 {
+		code = exitCoder.ExitCode()
+	}
+
+	if errorFormat == "json" {
+		data, _ := json.Marshal(struct {
+			Error string `json:"error"`
+			Code  int    `json:"code"`
+		}{Error: err.Error(), Code: code})
+		fmt.Fprintln(os.Stderr, string(data))
+	} else {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+	}
+
+	os.Exit(code)
+}
+
+// bitwardenContainerFlags returns the audit, image, tmpfs, resource and user flags shared by bw-backup and bw-restore
+func bitwardenContainerFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "audit-format",
+			Usage: "Audit log format on stderr: text ([AUDIT] lines) or json (one object per event)",
+			Value: "text",
+		},
+		&cli.StringFlag{
+			Name:  "image-digest",
+			Usage: "Run the backup image pinned to this digest (sha256:...) instead of :latest; the resolved digest is verified",
+		},
+		&cli.BoolFlag{
+			Name:  "tmpfs-fallback",
+			Usage: "Retry with reduced tmpfs hardening if the container engine rejects the tmpfs options",
+		},
+		&cli.BoolFlag{
+			Name:  "no-tmpfs",
+			Usage: "Disable tmpfs hardening entirely (debugging only)",
+		},
+		&cli.BoolFlag{
+			Name:  "read-only",
+			Usage: "Mount the container's root filesystem read-only, leaving only the tmpfs mounts and volumes writable",
+		},
+		&cli.StringSliceFlag{
+			Name:  "cap-add",
+			Usage: "Linux capability to add back after all are dropped, repeatable, e.g. CHOWN (rarely needed)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "env-file",
+			Usage: "File of extra KEY=VALUE lines for the backup container, repeatable; values are redacted in logs (built-in variables win on conflicts)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "env-file-plain",
+			Usage: "Like --env-file, but values are shown in logs unless the key looks secret",
+		},
+		&cli.StringFlag{
+			Name:  "tmp-size",
+			Usage: "Size of the /tmp tmpfs mount (raise for very large vaults)",
+			Value: "100m",
+		},
+		&cli.StringFlag{
+			Name:  "cache-size",
+			Usage: "Size of the ~/.cache tmpfs mount",
+			Value: "50m",
+		},
+		&cli.StringFlag{
+			Name:  "local-size",
+			Usage: "Size of the ~/.local tmpfs mount",
+			Value: "50m",
+		},
+		&cli.StringFlag{
+			Name:  "memory",
+			Usage: "Container memory limit, e.g. 512m or 2g (default: no limit)",
+		},
+		&cli.StringFlag{
+			Name:  "cpus",
+			Usage: "Container CPU limit, e.g. 1.5 (default: no limit)",
+		},
+		&cli.StringFlag{
+			Name:  "userns",
+			Usage: "User namespace mode for the container: auto (keep-id on rootless Podman), none, or an engine value such as keep-id or host",
+			Value: "auto",
+		},
+		&cli.BoolFlag{
+			Name:  "no-user-mapping",
+			Usage: "Run the container as the image's user instead of the current host uid:gid",
+		},
+		&cli.StringFlag{
+			Name:  "user",
+			Usage: "Run the container as this uid:gid instead of the current host user",
+		},
+	}
+}
+
+// gatewayNameFlag returns the --name flag of the ibgateway management subcommands
+func gatewayNameFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "name",
+		Usage: "Container name",
+		Value: "ibgateway",
+	}
+}
+
+// logsFlags returns the flags shared by the logs commands
+func logsFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "tail",
+			Usage: "Number of lines to show from the end of the output, or 'all'",
+			Value: "100",
+		},
+		&cli.BoolFlag{
+			Name:    "follow",
+			Aliases: []string{"f"},
+			Usage:   "Keep streaming new output until Ctrl-C",
+		},
 	}
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+// runAppCommand runs the real app with args, restoring the settings the app's Before changes.
+// A non-nil action replaces the action of the command at path (e.g. "images pull").
+func runAppCommand(t *testing.T, path string, args []string, action cli.ActionFunc) error {
+	t.Helper()
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(config, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONTAINERS_CONFIG", config)
+	savedSettings, savedConfig, savedFormat, savedColor := runtimeSettings, appConfig, errorFormat, colorOutput
+	t.Cleanup(func() {
+		runtimeSettings, appConfig, errorFormat, colorOutput = savedSettings, savedConfig, savedFormat, savedColor
+	})
+
+	app := newApp()
+	command, ok := commandPaths(app.Commands, "", make(map[string]*cli.Command))[path]
+	if !ok {
+		t.Fatalf("no %q command", path)
+	}
+	if action != nil {
+		command.Action = action
+	}
+	return app.Run(append([]string{"containers"}, args...))
+}
+
+func TestSliceFlagsKeepCommas(t *testing.T) {
+	var env []string
+	err := runAppCommand(t, "ibgateway",
+		[]string{"--label", "team=a,b", "ibgateway", "--env", "KEY=a,b", "--env", "OTHER=c"},
+		func(c *cli.Context) error {
+			env = c.StringSlice("env")
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("app.Run() error = %v", err)
+	}
+	if want := []string{"KEY=a,b", "OTHER=c"}; !reflect.DeepEqual(env, want) {
+		t.Errorf("--env = %q, want %q", env, want)
+	}
+	if got := runtimeSettings.Labels["team"]; got != "a,b" {
+		t.Errorf("--label team = %q, want %q", got, "a,b")
+	}
+}
+
+func TestAllowedRegistryEnvList(t *testing.T) {
+	t.Setenv("CONTAINERS_ALLOWED_REGISTRIES", "ghcr.io,docker.io")
+	err := runAppCommand(t, "version", []string{"version"}, func(c *cli.Context) error { return nil })
+	if err != nil {
+		t.Fatalf("app.Run() error = %v", err)
+	}
+	if want := []string{"ghcr.io", "docker.io"}; !reflect.DeepEqual(runtimeSettings.AllowedRegistries, want) {
+		t.Errorf("allowed registries = %q, want %q", runtimeSettings.AllowedRegistries, want)
+	}
+}
+
+func TestRetriesFlagPlaceholder(t *testing.T) {
+	flag := findFlag(newApp().Flags, "retries")
+	if flag == nil {
+		t.Fatal("no --retries flag")
+	}
+	if help := flag.String(); !strings.HasPrefix(help, "--retries N\t") {
+		t.Errorf("--retries help = %q, expected the N placeholder", help)
+	}
+}
+
+func TestKeychainResetProfileAlias(t *testing.T) {
+	var profile string
+	err := runAppCommand(t, "keychain reset", []string{"keychain", "reset", "-P", "work"}, func(c *cli.Context) error {
+		profile = c.String("profile")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("app.Run() error = %v", err)
+	}
+	if profile != "work" {
+		t.Errorf("keychain reset -P = %q, want work", profile)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// runPdfBatch compresses every PDF in dir (and its subdirectories with --recursive), continuing past failures
+func runPdfBatch(c *cli.Context, dir string, opts pdfCompressOptions, state *compressState) error {
+	var skips skipTracker
+	files, err := findBatchPDFs(dir, c.Bool("recursive"), c.Bool("follow-symlinks"), &skips)
+	if err != nil {
+		return err
+	}
+	if !c.Bool("in-place") {
+		files = excludeBatchOutputs(files, opts, &skips)
+	}
+	if len(files) == 0 {
+		infof("No PDFs to compress in %s (%s)\n", dir, skips.Summary())
+		return nil
+	}
+
+	infof("Compressing %d PDF(s) in %s...\n\n", len(files), dir)
+	events.Emit(eventStarted, "pdf-compress", dir, fmt.Sprintf("%d file(s)", len(files)))
+
+	var errors []string
+	var totals sizeTotals
+	successCount := 0
+	for i, path := range files {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			rel = path
+		}
+		infof("[%d/%d] %s\n", i+1, len(files), rel)
+
+		skipped, err := compressOne(c, path, opts, state, &totals)
+		switch {
+		case isInterrupted(err):
+			return err
+		case err != nil:
+			errors = append(errors, fmt.Sprintf("%s: %v", rel, err))
+			fmt.Printf("  %s %v\n", markFail(), err)
+			events.Emit(eventError, "pdf-compress", path, err.Error())
+		case skipped:
+			skips.Add(path, skipUnchanged)
+			infof("  Skipped (unchanged since last run)\n")
+		default:
+			successCount++
+			events.Emit(eventCompressed, "pdf-compress", path, "")
+		}
+	}
+
+	// Print summary
+	failedSummary := fmt.Sprintf("%d failed", len(errors))
+	if len(errors) > 0 {
+		failedSummary = colorFailure(failedSummary)
+	}
+	fmt.Printf("\nBatch compress completed: %s, %s, %s\n",
+		colorSuccess(fmt.Sprintf("%d successful", successCount)), failedSummary, skips.Summary())
+	if totals.Files > 0 {
+		fmt.Printf("Total size: %s\n", totals.Summary())
+	}
+	if len(errors) > 0 {
+		fmt.Println("\nErrors:")
+		for _, errMsg := range errors {
+			fmt.Printf("  - %s\n", errMsg)
+		}
+		return fmt.Errorf("batch compress completed with %d error(s)", len(errors))
+	}
+	return nil
+}
+
+// findBatchPDFs lists the PDFs under root in lexical order. Only root itself is read unless recursive.
+// Symlinked files are skipped unless followSymlinks; symlinked directories are never descended into.
+// Files named *.pdf that lack a PDF header are recorded in skips as ignored.
+func findBatchPDFs(root string, recursive, followSymlinks bool, skips *skipTracker) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.EqualFold(filepath.Ext(path), ".pdf") {
+			return nil
+		}
+
+		if d.Type()&fs.ModeSymlink != 0 {
+			if !followSymlinks {
+				skips.Add(path, skipIgnored)
+				return nil
+			}
+			info, err := os.Stat(path)
+			if err != nil || !info.Mode().IsRegular() {
+				skips.Add(path, skipIgnored)
+				return nil
+			}
+		} else if !d.Type().IsRegular() {
+			return nil
+		}
+
+		valid, err := isPDFFile(path)
+		if err != nil {
+			return err
+		}
+		if !valid {
+			skips.Add(path, skipIgnored)
+			return nil
+		}
+		files = append(files, path)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", root, err)
+	}
+	return files, nil
+}
+
+// excludeBatchOutputs drops files that another input would be compressed to (e.g. report_ebook.pdf
+// from an earlier run next to report.pdf), so re-running a batch does not compress its own outputs
+func excludeBatchOutputs(files []string, opts pdfCompressOptions, skips *skipTracker) []string {
+	outputs := make(map[string]bool, len(files))
+	for _, path := range files {
+		name, err := outputFilename(path, opts)
+		if err != nil {
+			continue
+		}
+		dir := filepath.Dir(path)
+		if opts.OutputDir != "" {
+			dir = opts.OutputDir
+		}
+		if output := filepath.Join(dir, name); output != path {
+			outputs[output] = true
+		}
+	}
+
+	kept := files[:0:0]
+	for _, path := range files {
+		if outputs[path] {
+			skips.Add(path, skipIgnored)
+			continue
+		}
+		kept = append(kept, path)
+	}
+	return kept
+}
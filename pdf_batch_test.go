@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindBatchPDFs(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel, content string) string {
+		path := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	a := write("a.pdf", "%PDF-1.4")
+	b := write("B.PDF", "%PDF-1.7")
+	write("notes.txt", "%PDF- but not a pdf name")
+	write("fake.pdf", "plain text")
+	nested := write("sub/c.pdf", "%PDF-1.5")
+	link := filepath.Join(root, "link.pdf")
+	if err := os.Symlink(a, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "sub"), filepath.Join(root, "linkdir")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		recursive      bool
+		followSymlinks bool
+		want           []string
+		wantSkipped    int
+	}{
+		{name: "top level only", want: []string{b, a}, wantSkipped: 2},
+		{name: "recursive", recursive: true, want: []string{b, a, nested}, wantSkipped: 2},
+		{name: "follow symlinks", followSymlinks: true, want: []string{b, a, link}, wantSkipped: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var skips skipTracker
+			got, err := findBatchPDFs(root, tt.recursive, tt.followSymlinks, &skips)
+			if err != nil {
+				t.Fatalf("findBatchPDFs() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findBatchPDFs() = %v, want %v", got, tt.want)
+			}
+			if skips.Count() != tt.wantSkipped {
+				t.Errorf("skipped = %d, want %d", skips.Count(), tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestExcludeBatchOutputs(t *testing.T) {
+	opts := pdfCompressOptions{Quality: "ebook", NameTemplate: defaultNameTemplate}
+	files := []string{"/docs/report.pdf", "/docs/report_ebook.pdf", "/docs/scan_screen.pdf"}
+
+	var skips skipTracker
+	got := excludeBatchOutputs(files, opts, &skips)
+	want := []string{"/docs/report.pdf", "/docs/scan_screen.pdf"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("excludeBatchOutputs() = %v, want %v", got, want)
+	}
+	if skips.Count() != 1 {
+		t.Errorf("skipped = %d, want 1", skips.Count())
+	}
+
+	// Outputs written elsewhere never collide with inputs
+	opts.OutputDir = "/archive"
+	if got := excludeBatchOutputs(files, opts, &skipTracker{}); !reflect.DeepEqual(got, files) {
+		t.Errorf("excludeBatchOutputs() with output dir = %v, want %v", got, files)
+	}
+}
@@ -0,0 +1,505 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"github.com/vupham90/containers/internal/bytesize"
+)
+
+// pdfHeader is the magic every PDF file starts with
+const pdfHeader = "%PDF-"
+
+// validQualities lists the Ghostscript PDFSETTINGS presets
+var validQualities = map[string]bool{
+	"ebook":    true,
+	"screen":   true,
+	"printer":  true,
+	"prepress": true,
+	"default":  true,
+}
+
+// pdfOutputMountDir is where --output-dir is mounted inside the container
+const pdfOutputMountDir = "/output"
+
+// validCompatLevels lists the PDF versions Ghostscript's pdfwrite can target
+var validCompatLevels = map[string]bool{
+	"1.4": true,
+	"1.5": true,
+	"1.6": true,
+	"1.7": true,
+}
+
+// minDPI and maxDPI bound --dpi: below 30 images are unreadable, above 600 nothing is downsampled in practice
+const (
+	minDPI = 30
+	maxDPI = 600
+)
+
+// pdfCompressOptions holds the Ghostscript settings shared by single-file and watch modes
+type pdfCompressOptions struct {
+	Quality       string
+	CompatLevel   string
+	PDFA          string // PDF/A conformance level (1b, 2b, 3b); empty for regular PDF output
+	NameTemplate  string // Output filename template; empty uses defaultNameTemplate
+	OutputDir     string // Absolute directory the output is written to; empty writes next to the input
+	OutputName    string // Explicit output filename from --output; overrides NameTemplate
+	Limits        ResourceLimits
+	User          string // uid:gid Ghostscript runs as; empty keeps the image's user
+	StripMetadata bool   // Blank the document info fields and omit XMP metadata, dates and document ID
+	DPI           int    // Color and gray image resolution overriding the preset's; 0 keeps the preset
+
+	WaitStable  time.Duration // Wait until the input is unchanged for this long before compressing (0 skips)
+	WaitTimeout time.Duration // Give up waiting for a stable input after this long
+}
+
+// pdfCompressOptionsFromFlags reads and validates the Ghostscript settings flags
+func pdfCompressOptionsFromFlags(c *cli.Context) (pdfCompressOptions, error) {
+	opts := pdfCompressOptions{
+		Quality:       c.String("quality"),
+		CompatLevel:   c.String("compat-level"),
+		PDFA:          c.String("pdfa"),
+		NameTemplate:  c.String("name-template"),
+		StripMetadata: c.Bool("strip-metadata"),
+		DPI:           c.Int("dpi"),
+		WaitStable:    c.Duration("wait-for-file"),
+		WaitTimeout:   c.Duration("wait-timeout"),
+	}
+	if !validQualities[opts.Quality] {
+		return opts, fmt.Errorf("invalid quality: %s", opts.Quality)
+	}
+	if c.IsSet("dpi") && (opts.DPI < minDPI || opts.DPI > maxDPI) {
+		return opts, fmt.Errorf("invalid dpi: %d (must be between %d and %d)", opts.DPI, minDPI, maxDPI)
+	}
+	if !validCompatLevels[opts.CompatLevel] {
+		return opts, fmt.Errorf("invalid compatibility level: %s (must be 1.4, 1.5, 1.6 or 1.7)", opts.CompatLevel)
+	}
+	if opts.PDFA != "" {
+		if err := validatePDFALevel(opts.PDFA, opts.CompatLevel); err != nil {
+			return opts, err
+		}
+		// PDF/A requires the document info and matching XMP metadata
+		if opts.StripMetadata {
+			return opts, fmt.Errorf("--strip-metadata cannot be combined with --pdfa")
+		}
+	}
+	limits, err := resourceLimitsFromFlags(c)
+	if err != nil {
+		return opts, err
+	}
+	opts.Limits = limits
+	if opts.User, err = containerUserFromFlags(c); err != nil {
+		return opts, err
+	}
+	if outputDir := c.String("output-dir"); outputDir != "" {
+		if c.IsSet("watch") || c.Bool("in-place") {
+			return opts, fmt.Errorf("--output-dir cannot be combined with --watch (use --watch-output) or --in-place")
+		}
+		absOutputDir, err := resolveExistingDir(outputDir)
+		if err != nil {
+			return opts, fmt.Errorf("invalid --output-dir: %w", err)
+		}
+		opts.OutputDir = absOutputDir
+	}
+	if output := c.String("output"); output != "" {
+		if c.IsSet("watch") || c.Bool("in-place") || c.IsSet("output-dir") || c.IsSet("name-template") {
+			return opts, fmt.Errorf("--output cannot be combined with --watch, --in-place, --output-dir or --name-template")
+		}
+		if err := resolveOutputFile(&opts, output, c.Bool("force")); err != nil {
+			return opts, err
+		}
+	}
+	if opts.WaitStable < 0 || opts.WaitTimeout < 0 {
+		return opts, fmt.Errorf("--wait-for-file and --wait-timeout must not be negative")
+	}
+	// Catch template mistakes up front rather than per file in watch mode
+	if _, err := outputFilename("/input.pdf", opts); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// resolveOutputFile points opts at the --output file, whose directory must already exist.
+// An existing output file is only overwritten with force.
+func resolveOutputFile(opts *pdfCompressOptions, output string, force bool) error {
+	output, err := expandHome(output)
+	if err != nil {
+		return err
+	}
+	absOutput, err := filepath.Abs(output)
+	if err != nil {
+		return fmt.Errorf("failed to resolve --output: %w", err)
+	}
+	outputDir, err := resolveExistingDir(filepath.Dir(absOutput))
+	if err != nil {
+		return fmt.Errorf("invalid --output: %w", err)
+	}
+	if info, err := os.Stat(absOutput); err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("--output is a directory: %s (use --output-dir)", absOutput)
+		}
+		if !force {
+			return fmt.Errorf("output file already exists: %s (use --force to overwrite)", absOutput)
+		}
+	}
+	opts.OutputDir = outputDir
+	opts.OutputName = filepath.Base(absOutput)
+	return nil
+}
+
+// resolveExistingDir expands ~ and returns the absolute path of an existing directory
+func resolveExistingDir(path string) (string, error) {
+	path, err := expandHome(path)
+	if err != nil {
+		return "", err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return "", fmt.Errorf("directory does not exist: %s", absPath)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("not a directory: %s", absPath)
+	}
+	return absPath, nil
+}
+
+// runPdfCompress executes the pdf-compress command
+func runPdfCompress(c *cli.Context) error {
+	if c.IsSet("watch") {
+		return runPdfWatch(c)
+	}
+
+	if c.NArg() != 1 {
+		return fmt.Errorf("expected 1 argument: file-path")
+	}
+
+	filePath := c.Args().Get(0)
+	opts, err := pdfCompressOptionsFromFlags(c)
+	if err != nil {
+		return err
+	}
+
+	// Resolve absolute path
+	absFilePath, err := filepath.Abs(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve file path: %w", err)
+	}
+
+	// Verify file exists
+	info, err := os.Stat(absFilePath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("file does not exist: %s", absFilePath)
+	}
+	isDir := err == nil && info.IsDir()
+
+	var state *compressState
+	if statePath := c.String("state-file"); statePath != "" {
+		if state, err = loadCompressState(statePath); err != nil {
+			return err
+		}
+	}
+
+	// A directory compresses every PDF in it
+	if isDir && opts.OutputName != "" {
+		return fmt.Errorf("--output requires a single input file, not a directory")
+	}
+	if isDir {
+		return runPdfBatch(c, absFilePath, opts, state)
+	}
+	if c.Bool("recursive") {
+		return fmt.Errorf("--recursive requires a directory argument")
+	}
+	if opts.OutputName != "" && filepath.Join(opts.OutputDir, opts.OutputName) == absFilePath {
+		return fmt.Errorf("--output must differ from the input file (use --in-place to replace it)")
+	}
+
+	skipped, err := compressOne(c, absFilePath, opts, state, nil)
+	if err != nil {
+		return err
+	}
+	if skipped {
+		var skips skipTracker
+		skips.Add(absFilePath, skipUnchanged)
+		infof("Skipped %s (unchanged since last run)\n", absFilePath)
+		infof("%s\n", skips.Summary())
+	}
+	return nil
+}
+
+// compressOne compresses a single input as configured by the flags, prints its size change and records it
+// in state and totals (if any). It reports true without compressing when state shows the input unchanged
+// since the last run.
+func compressOne(c *cli.Context, absFilePath string, opts pdfCompressOptions, state *compressState, totals *sizeTotals) (bool, error) {
+	// Let an input that is still being written (e.g. synced or uploaded) settle first
+	if opts.WaitStable > 0 {
+		infof("Waiting for %s to stop changing...\n", filepath.Base(absFilePath))
+		if err := waitForStableFile(c.Context, absFilePath, opts.WaitStable, opts.WaitTimeout); err != nil {
+			return false, err
+		}
+	}
+
+	// Skip inputs unchanged since the last successful run
+	if state != nil {
+		unchanged, err := state.unchanged(absFilePath, compressSettings(opts))
+		if err != nil {
+			return false, err
+		}
+		if unchanged {
+			return true, nil
+		}
+	}
+
+	if c.Bool("in-place") {
+		change, err := compressInPlace(c.Context, absFilePath, opts, c.Bool("backup-original"), c.Bool("only-if-smaller"))
+		if err != nil {
+			return false, err
+		}
+		if runtimeSettings.DryRun {
+			return false, nil
+		}
+		if totals != nil {
+			totals.Add(change)
+		}
+	} else {
+		name, err := outputFilename(absFilePath, opts)
+		if err != nil {
+			return false, err
+		}
+		outputPath, err := compressPDF(c.Context, absFilePath, name, opts)
+		if err != nil {
+			return false, err
+		}
+		if runtimeSettings.DryRun {
+			return false, nil
+		}
+		infof("Compressed PDF written to: %s\n", outputPath)
+
+		change, err := statSizeChange(absFilePath, outputPath)
+		if err != nil {
+			return false, err
+		}
+		reportSizeChange("", change)
+		if totals != nil {
+			totals.Add(change)
+		}
+	}
+
+	if state != nil && !runtimeSettings.DryRun {
+		if err := state.record(absFilePath, compressSettings(opts)); err != nil {
+			return false, fmt.Errorf("failed to record state: %w", err)
+		}
+		return false, state.save()
+	}
+	return false, nil
+}
+
+// compressInPlace compresses absFilePath to a temporary file next to it and atomically
+// renames it over the original, optionally keeping the original as <name>.pdf.bak.
+// The returned change is what ended up on disk: none when the original was kept.
+func compressInPlace(ctx context.Context, absFilePath string, opts pdfCompressOptions, backupOriginal, onlyIfSmaller bool) (sizeChange, error) {
+	tmpName := fmt.Sprintf(".%s.%s.tmp.pdf", strings.TrimSuffix(filepath.Base(absFilePath), ".pdf"), opts.Quality)
+	tmpPath, err := compressPDF(ctx, absFilePath, tmpName, opts)
+	if err != nil || runtimeSettings.DryRun {
+		return sizeChange{}, err
+	}
+	defer os.Remove(tmpPath)
+
+	valid, err := isPDFFile(tmpPath)
+	if err != nil {
+		return sizeChange{}, err
+	}
+	if !valid {
+		return sizeChange{}, fmt.Errorf("compressed output is not a valid PDF; original left untouched")
+	}
+
+	origInfo, err := os.Stat(absFilePath)
+	if err != nil {
+		return sizeChange{}, fmt.Errorf("failed to stat original: %w", err)
+	}
+	change, err := statSizeChange(absFilePath, tmpPath)
+	if err != nil {
+		return sizeChange{}, err
+	}
+	if onlyIfSmaller && change.Compressed >= change.Original {
+		fmt.Printf("Kept original %s (compressed output was not smaller: %s >= %s)\n",
+			absFilePath, bytesize.FormatSize(change.Compressed), bytesize.FormatSize(change.Original))
+		return sizeChange{Original: change.Original, Compressed: change.Original}, nil
+	}
+
+	if backupOriginal {
+		backupPath := absFilePath + ".bak"
+		if err := copyFile(absFilePath, backupPath); err != nil {
+			return sizeChange{}, fmt.Errorf("failed to back up original: %w", err)
+		}
+		infof("Original backed up to: %s\n", backupPath)
+	}
+
+	if err := os.Chmod(tmpPath, origInfo.Mode().Perm()); err != nil {
+		return sizeChange{}, fmt.Errorf("failed to set permissions on compressed output: %w", err)
+	}
+	if err := os.Rename(tmpPath, absFilePath); err != nil {
+		return sizeChange{}, fmt.Errorf("failed to replace original: %w", err)
+	}
+
+	infof("Compressed %s in place\n", absFilePath)
+	reportSizeChange("", change)
+	return change, nil
+}
+
+// isPDFFile reports whether path starts with the PDF header
+func isPDFFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	header := make([]byte, len(pdfHeader))
+	if _, err := io.ReadFull(f, header); err != nil {
+		return false, nil
+	}
+	return string(header) == pdfHeader, nil
+}
+
+// copyFile copies src to dst, preserving the permission bits of src
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// stripMetadataPostScript runs after the input and blanks the document info fields it carried over.
+// The dates, XMP metadata and document ID are left out by the -dOmit* flags instead.
+const stripMetadataPostScript = "[ /Title () /Author () /Subject () /Keywords () /Creator () /Producer () /DOCINFO pdfmark"
+
+// ghostscriptArgs builds the gs command line that writes input to output within the container
+func ghostscriptArgs(opts pdfCompressOptions, output, input string) []string {
+	args := []string{
+		"-sDEVICE=pdfwrite",
+		"-dCompatibilityLevel=" + opts.CompatLevel,
+		fmt.Sprintf("-dPDFSETTINGS=/%s", opts.Quality),
+	}
+	if opts.PDFA != "" {
+		args = append(args, pdfaArgs(opts.PDFA)...)
+	}
+	// Placed after -dPDFSETTINGS so they override the preset's resolution while keeping its other settings
+	if opts.DPI > 0 {
+		dpi := strconv.Itoa(opts.DPI)
+		args = append(args,
+			"-dDownsampleColorImages=true", "-dColorImageResolution="+dpi,
+			"-dDownsampleGrayImages=true", "-dGrayImageResolution="+dpi,
+		)
+	}
+	if opts.StripMetadata {
+		args = append(args, "-dOmitXMP=true", "-dOmitInfoDate=true", "-dOmitID=true")
+	}
+
+	args = append(args, "-o", output)
+	// The PDF/A definition file must come before the input
+	if opts.PDFA != "" {
+		args = append(args, pdfaDefMountDir+"/PDFA_def.ps")
+	}
+	args = append(args, input)
+	if opts.StripMetadata {
+		args = append(args, "-c", stripMetadataPostScript)
+	}
+	return args
+}
+
+// compressPDF runs Ghostscript on absFilePath, writing outputFilename next to it,
+// and returns the absolute path of the confirmed output file
+func compressPDF(ctx context.Context, absFilePath, outputFilename string, opts pdfCompressOptions) (string, error) {
+	/*
+		docker run \
+		  --rm \
+		  -v ~/Downloads:/workspace \
+		  -w /workspace \
+		  --entrypoint sh \
+		  ghcr.io/vupham90/containers-pdf-compress:latest \
+		  -c "gs -sDEVICE=pdfwrite -dCompatibilityLevel=1.4 -dPDFSETTINGS=/ebook -o /workspace/out.pdf /workspace/ALPINE.pdf && ls -la /workspace/out.pdf"
+	*/
+
+	// Generate output path; a separate output directory gets its own mount
+	dir := filepath.Dir(absFilePath)
+	outputPath := filepath.Join(dir, outputFilename)
+	containerOutput := "/workspace/" + outputFilename
+	// Ghostscript only reads and writes the mounted files, so the container gets no network at all
+	containerOpts := ContainerOptions{Remove: true, Limits: opts.Limits, Network: "none", User: opts.User, Labels: toolLabels("pdf-compress")}
+	if opts.User != "" {
+		containerOpts.UserNS = hostUserNS(detectEngine())
+	}
+	if opts.OutputDir != "" && opts.OutputDir != dir {
+		outputPath = filepath.Join(opts.OutputDir, outputFilename)
+		containerOutput = pdfOutputMountDir + "/" + outputFilename
+		containerOpts.Volumes = append(containerOpts.Volumes, opts.OutputDir+":"+pdfOutputMountDir)
+	}
+
+	// PDF/A needs a definition file, and gs's output to report violations
+	image := pdfCompressImage
+	workDir := dir
+	var gsOutput bytes.Buffer
+	if opts.PDFA != "" {
+		defDir, err := writePDFADefinition()
+		if err != nil {
+			return "", err
+		}
+		defer os.RemoveAll(defDir)
+		containerOpts.Volumes = append(containerOpts.Volumes, defDir+":"+pdfaDefMountDir+":ro")
+		containerOpts.Capture = &gsOutput
+	}
+	args := ghostscriptArgs(opts, containerOutput, "/workspace/"+filepath.Base(absFilePath))
+
+	// Ghostscript would overwrite it anyway; removing it first keeps a stale file from passing the check below
+	if !runtimeSettings.DryRun {
+		if err := os.Remove(outputPath); err != nil && !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to remove existing output file: %w", err)
+		}
+	}
+	if err := RunContainer(ctx, image, workDir, args, containerOpts); err != nil {
+		return "", err
+	}
+	if runtimeSettings.DryRun {
+		return outputPath, nil
+	}
+
+	// Ghostscript can exit 0 without writing anything for some malformed inputs
+	if _, err := os.Stat(outputPath); err != nil {
+		return "", fmt.Errorf("container exited successfully but output file was not created: %s", outputPath)
+	}
+
+	if opts.PDFA != "" {
+		if err := reportPDFAConversion(outputPath, opts.PDFA, gsOutput.Bytes()); err != nil {
+			return "", err
+		}
+	}
+
+	return outputPath, nil
+}
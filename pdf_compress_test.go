@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestResolveOutputFile(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.pdf")
+	if err := os.WriteFile(existing, []byte("%PDF-1.4"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		output    string
+		force     bool
+		expectErr bool
+	}{
+		{name: "new file", output: filepath.Join(dir, "out.pdf")},
+		{name: "existing file", output: existing, expectErr: true},
+		{name: "existing file with force", output: existing, force: true},
+		{name: "missing directory", output: filepath.Join(dir, "missing", "out.pdf"), expectErr: true},
+		{name: "directory", output: dir, force: true, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts pdfCompressOptions
+			err := resolveOutputFile(&opts, tt.output, tt.force)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("resolveOutputFile() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if tt.expectErr {
+				return
+			}
+			if got := filepath.Join(opts.OutputDir, opts.OutputName); got != tt.output {
+				t.Errorf("resolveOutputFile() resolved %s, want %s", got, tt.output)
+			}
+			if name, _ := outputFilename("/input.pdf", opts); name != filepath.Base(tt.output) {
+				t.Errorf("outputFilename() = %s, want %s", name, filepath.Base(tt.output))
+			}
+		})
+	}
+}
+
+func TestGhostscriptArgs(t *testing.T) {
+	base := pdfCompressOptions{Quality: "ebook", CompatLevel: "1.4"}
+	stripFlags := []string{"-dOmitXMP=true", "-dOmitInfoDate=true", "-dOmitID=true"}
+
+	tests := []struct {
+		name     string
+		opts     func(o *pdfCompressOptions)
+		expected []string
+	}{
+		{
+			name:     "plain",
+			opts:     func(o *pdfCompressOptions) {},
+			expected: []string{"-sDEVICE=pdfwrite", "-dCompatibilityLevel=1.4", "-dPDFSETTINGS=/ebook", "-o", "/workspace/out.pdf", "/workspace/in.pdf"},
+		},
+		{
+			name: "strip metadata",
+			opts: func(o *pdfCompressOptions) { o.StripMetadata = true },
+			expected: append(append([]string{"-sDEVICE=pdfwrite", "-dCompatibilityLevel=1.4", "-dPDFSETTINGS=/ebook"}, stripFlags...),
+				"-o", "/workspace/out.pdf", "/workspace/in.pdf", "-c", stripMetadataPostScript),
+		},
+		{
+			name: "dpi augments the preset",
+			opts: func(o *pdfCompressOptions) { o.DPI = 200 },
+			expected: []string{"-sDEVICE=pdfwrite", "-dCompatibilityLevel=1.4", "-dPDFSETTINGS=/ebook",
+				"-dDownsampleColorImages=true", "-dColorImageResolution=200", "-dDownsampleGrayImages=true", "-dGrayImageResolution=200",
+				"-o", "/workspace/out.pdf", "/workspace/in.pdf"},
+		},
+		{
+			name: "pdfa definition before the input",
+			opts: func(o *pdfCompressOptions) { o.PDFA = "2b" },
+			expected: append(append([]string{"-sDEVICE=pdfwrite", "-dCompatibilityLevel=1.4", "-dPDFSETTINGS=/ebook"}, pdfaArgs("2b")...),
+				"-o", "/workspace/out.pdf", pdfaDefMountDir+"/PDFA_def.ps", "/workspace/in.pdf"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := base
+			tt.opts(&opts)
+			if got := ghostscriptArgs(opts, "/workspace/out.pdf", "/workspace/in.pdf"); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("ghostscriptArgs() =\n%v\nexpected\n%v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCompressPDFRejectsStaleOutput(t *testing.T) {
+	stubEngineProbe(t, "")
+	input := filepath.Join(t.TempDir(), "scan.pdf")
+	if err := os.WriteFile(input, []byte("%PDF-1.4 original input"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outputDir := t.TempDir()
+	stale := filepath.Join(outputDir, "scan_ebook.pdf")
+	opts := pdfCompressOptions{Quality: "ebook", CompatLevel: "1.4", OutputDir: outputDir}
+
+	tests := []struct {
+		name      string
+		script    string
+		expectErr bool
+	}{
+		{name: "no output written", script: "#!/bin/sh\nexit 0\n", expectErr: true},
+		{name: "output written", script: fakeGhostscriptScript},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeDocker(t, tt.script)
+			if err := os.WriteFile(stale, []byte("%PDF-1.4 stale"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := compressPDF(context.Background(), input, "scan_ebook.pdf", opts)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("compressPDF() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if data, _ := os.ReadFile(stale); string(data) == "%PDF-1.4 stale" {
+				t.Error("stale output was left in place")
+			}
+		})
+	}
+}
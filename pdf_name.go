@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultNameTemplate reproduces the historical <base>_<quality>.pdf naming
+const defaultNameTemplate = "{base}_{quality}"
+
+// presetDPI is the image resolution each Ghostscript PDFSETTINGS preset downsamples to
+var presetDPI = map[string]int{
+	"screen":   72,
+	"ebook":    150,
+	"printer":  300,
+	"prepress": 300,
+	"default":  72,
+}
+
+// effectiveDPI is the color and gray image resolution the output is downsampled to: --dpi, or the preset's
+func effectiveDPI(opts pdfCompressOptions) int {
+	if opts.DPI > 0 {
+		return opts.DPI
+	}
+	return presetDPI[opts.Quality]
+}
+
+// placeholderPattern matches a {name} placeholder in a name template
+var placeholderPattern = regexp.MustCompile(`\{[^{}]*\}`)
+
+// renderNameTemplate expands a --name-template for absFilePath. Supported placeholders:
+// {base} (input name without .pdf), {quality}, {date} (YYYY-MM-DD) and {dpi}.
+// A missing .pdf extension is appended; the result must be a plain filename that, when written next to
+// the input, does not replace it.
+func renderNameTemplate(template, absFilePath string, opts pdfCompressOptions, now time.Time) (string, error) {
+	base := filepath.Base(absFilePath)
+	values := map[string]string{
+		"{base}":    strings.TrimSuffix(base, filepath.Ext(base)),
+		"{quality}": opts.Quality,
+		"{date}":    now.Format("2006-01-02"),
+		"{dpi}":     strconv.Itoa(effectiveDPI(opts)),
+	}
+
+	var unknown string
+	name := placeholderPattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		value, ok := values[placeholder]
+		if !ok && unknown == "" {
+			unknown = placeholder
+		}
+		return value
+	})
+	if unknown != "" {
+		return "", fmt.Errorf("invalid name template %q: unknown placeholder %s", template, unknown)
+	}
+
+	if !strings.EqualFold(filepath.Ext(name), ".pdf") {
+		name += ".pdf"
+	}
+
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	switch {
+	case strings.TrimSpace(stem) == "":
+		return "", fmt.Errorf("invalid name template %q: produces an empty filename", template)
+	case strings.ContainsAny(name, `/\`) || strings.ContainsRune(name, 0) || stem == "." || stem == "..":
+		return "", fmt.Errorf("invalid name template %q: %q is not a plain filename in the output directory", template, name)
+	// Case-insensitive filesystems (macOS, Windows) treat report.PDF as report.pdf
+	case strings.EqualFold(name, base) && sameOutputDir(absFilePath, opts):
+		return "", fmt.Errorf("invalid name template %q: output would overwrite the input %s", template, base)
+	}
+	return name, nil
+}
+
+// sameOutputDir reports whether the output is written to absFilePath's own directory
+func sameOutputDir(absFilePath string, opts pdfCompressOptions) bool {
+	return opts.OutputDir == "" || filepath.Clean(opts.OutputDir) == filepath.Dir(absFilePath)
+}
+
+// outputFilename returns the compressed file's name for absFilePath: opts.OutputName if set, else opts.NameTemplate rendered
+func outputFilename(absFilePath string, opts pdfCompressOptions) (string, error) {
+	if opts.OutputName != "" {
+		return opts.OutputName, nil
+	}
+	template := opts.NameTemplate
+	if template == "" {
+		template = defaultNameTemplate
+	}
+	return renderNameTemplate(template, absFilePath, opts, time.Now())
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenderNameTemplate(t *testing.T) {
+	now := time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC)
+	opts := pdfCompressOptions{Quality: "ebook"}
+
+	tests := []struct {
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{defaultNameTemplate, "report_ebook.pdf", false},
+		{"{base}-2024-{quality}", "report-2024-ebook.pdf", false},
+		{"{base}.min.pdf", "report.min.pdf", false},
+		{"{base}_{date}_{dpi}dpi", "report_2024-03-09_150dpi.pdf", false},
+		{"{base}", "", true},     // would overwrite the input
+		{"{base}.PDF", "", true}, // overwrites the input on case-insensitive filesystems
+		{"REPORT", "", true},
+		{"{quality}/{base}", "", true}, // escapes the output directory
+		{"../{base}", "", true},
+		{"{nope}", "", true},
+		{"{date}", "2024-03-09.pdf", false},
+		{" ", "", true},
+		{"..", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := renderNameTemplate(tt.template, "/docs/report.pdf", opts, now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("renderNameTemplate(%q) error = %v, wantErr %v", tt.template, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("renderNameTemplate(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+	// Keeping the input's name is fine once the output goes to another directory
+	for _, tt := range []struct {
+		outputDir string
+		wantErr   bool
+	}{
+		{"/archive", false},
+		{"/docs/", true},
+	} {
+		dirOpts := pdfCompressOptions{Quality: "ebook", OutputDir: tt.outputDir}
+		got, err := renderNameTemplate("{base}", "/docs/report.pdf", dirOpts, now)
+		if (err != nil) != tt.wantErr || (!tt.wantErr && got != "report.pdf") {
+			t.Errorf("renderNameTemplate({base}) with output dir %s = %q, %v; wantErr %v", tt.outputDir, got, err, tt.wantErr)
+		}
+	}
+	opts.DPI = 200
+	if got, _ := renderNameTemplate("{base}_{dpi}dpi", "/docs/report.pdf", opts, now); got != "report_200dpi.pdf" {
+		t.Errorf("renderNameTemplate() with --dpi = %q, want report_200dpi.pdf", got)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// validPDFALevels maps a --pdfa conformance level to Ghostscript's -dPDFA part number
+var validPDFALevels = map[string]string{
+	"1b": "1",
+	"2b": "2",
+	"3b": "3",
+}
+
+// pdfaDefMountDir is where the PDF/A definition file is mounted inside the container
+const pdfaDefMountDir = "/pdfa"
+
+// pdfaDefinition is the PostScript prefix Ghostscript needs to embed an sRGB output intent.
+// The profile is read from Ghostscript's built-in ROM file system so no ICC file has to be mounted.
+const pdfaDefinition = `%!
+% PDF/A definition file generated by containers pdf-compress --pdfa
+/ICCProfile (%rom%iccprofiles/srgb.icc) def
+
+[/_objdef {icc_PDFA} /type /stream /OBJ pdfmark
+[{icc_PDFA} << /N 3 >> /PUT pdfmark
+[{icc_PDFA} ICCProfile (r) file /PUT pdfmark
+
+[/_objdef {OutputIntent_PDFA} /type /dict /OBJ pdfmark
+[{OutputIntent_PDFA} <<
+  /Type /OutputIntent
+  /S /GTS_PDFA1
+  /DestOutputProfile {icc_PDFA}
+  /OutputConditionIdentifier (sRGB)
+>> /PUT pdfmark
+[{Catalog} << /OutputIntents [ {OutputIntent_PDFA} ] >> /PUT pdfmark
+`
+
+// pdfaPartPattern matches the PDF/A identification in XMP metadata (element or attribute form)
+var pdfaPartPattern = regexp.MustCompile(`pdfaid:part(?:>|=["'])(\d)`)
+
+// validatePDFALevel checks the conformance level and its compatibility with the PDF version
+func validatePDFALevel(level, compatLevel string) error {
+	if _, ok := validPDFALevels[level]; !ok {
+		return fmt.Errorf("invalid PDF/A level: %s (must be 1b, 2b or 3b)", level)
+	}
+	if level == "1b" && compatLevel != "1.4" {
+		return fmt.Errorf("PDF/A-1b requires --compat-level 1.4")
+	}
+	return nil
+}
+
+// writePDFADefinition writes the definition file to a new temp dir and returns the dir
+func writePDFADefinition() (string, error) {
+	dir, err := os.MkdirTemp("", "containers-pdfa-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create PDF/A definition directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "PDFA_def.ps"), []byte(pdfaDefinition), 0644); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to write PDF/A definition file: %w", err)
+	}
+	return dir, nil
+}
+
+// pdfaArgs returns the Ghostscript flags that switch pdfwrite to PDF/A output
+func pdfaArgs(level string) []string {
+	return []string{
+		"-dPDFA=" + validPDFALevels[level],
+		"-dPDFACompatibilityPolicy=1", // Drop offending content and keep going rather than abort
+		"-sColorConversionStrategy=RGB",
+		"-sProcessColorModel=DeviceRGB",
+	}
+}
+
+// pdfaOutputPart returns the PDF/A part number declared by the file's metadata, or "" if none
+func pdfaOutputPart(data []byte) string {
+	if m := pdfaPartPattern.FindSubmatch(data); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
+
+// pdfaWarnings extracts the Ghostscript messages about PDF/A violations from its output
+func pdfaWarnings(output []byte) []string {
+	var warnings []string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		upper := strings.ToUpper(line)
+		if strings.Contains(upper, "PDFA") || strings.Contains(upper, "PDF/A") {
+			warnings = append(warnings, line)
+		}
+	}
+	return warnings
+}
+
+// reportPDFAConversion prints gs's PDF/A warnings and fails unless the output declares the requested part
+func reportPDFAConversion(outputPath, level string, gsOutput []byte) error {
+	for _, warning := range pdfaWarnings(gsOutput) {
+		fmt.Fprintf(os.Stderr, "PDF/A warning: %s\n", warning)
+	}
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to read output: %w", err)
+	}
+	if part := pdfaOutputPart(data); part != validPDFALevels[level] {
+		return fmt.Errorf("PDF/A-%s conversion failed: output is not marked as PDF/A-%s (written as regular PDF: %s)",
+			level, validPDFALevels[level], outputPath)
+	}
+
+	fmt.Printf("PDF/A-%s conversion succeeded\n", level)
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidatePDFALevel(t *testing.T) {
+	tests := []struct {
+		level, compat string
+		wantErr       bool
+	}{
+		{"1b", "1.4", false},
+		{"2b", "1.7", false},
+		{"3b", "1.4", false},
+		{"1b", "1.7", true},
+		{"2a", "1.4", true},
+	}
+	for _, tt := range tests {
+		if err := validatePDFALevel(tt.level, tt.compat); (err != nil) != tt.wantErr {
+			t.Errorf("validatePDFALevel(%q, %q) error = %v, wantErr %v", tt.level, tt.compat, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPDFAOutputPart(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{`<pdfaid:part>2</pdfaid:part><pdfaid:conformance>B</pdfaid:conformance>`, "2"},
+		{`<rdf:Description pdfaid:part="1" pdfaid:conformance="B"/>`, "1"},
+		{`%PDF-1.7 no metadata`, ""},
+	}
+	for _, tt := range tests {
+		if got := pdfaOutputPart([]byte(tt.data)); got != tt.want {
+			t.Errorf("pdfaOutputPart(%q) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestPDFAWarnings(t *testing.T) {
+	output := "GPL Ghostscript 10.03.1\nProcessing pages 1 through 1.\n" +
+		"   **** WARNING: PDFA doesn't allow transparency, reverting to normal PDF output\n"
+	want := []string{"**** WARNING: PDFA doesn't allow transparency, reverting to normal PDF output"}
+	if got := pdfaWarnings([]byte(output)); !reflect.DeepEqual(got, want) {
+		t.Errorf("pdfaWarnings() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/vupham90/containers/internal/bytesize"
+)
+
+// sizeChange is the size of a PDF before and after compression
+type sizeChange struct {
+	Original   int64
+	Compressed int64
+}
+
+// statSizeChange reads the sizes of the input and the compressed output
+func statSizeChange(inputPath, outputPath string) (sizeChange, error) {
+	input, err := os.Stat(inputPath)
+	if err != nil {
+		return sizeChange{}, fmt.Errorf("failed to stat input: %w", err)
+	}
+	output, err := os.Stat(outputPath)
+	if err != nil {
+		return sizeChange{}, fmt.Errorf("failed to stat compressed output: %w", err)
+	}
+	return sizeChange{Original: input.Size(), Compressed: output.Size()}, nil
+}
+
+// Reduction returns the percentage the size went down by; negative when the output grew
+func (s sizeChange) Reduction() float64 {
+	if s.Original == 0 {
+		return 0
+	}
+	return float64(s.Original-s.Compressed) / float64(s.Original) * 100
+}
+
+// Grew reports whether compression made the file larger, as it can for already optimized PDFs
+func (s sizeChange) Grew() bool {
+	return s.Compressed > s.Original
+}
+
+// String formats the change as "1.2 MiB -> 800.0 KiB (33.3% smaller)"
+func (s sizeChange) String() string {
+	direction := "smaller"
+	reduction := s.Reduction()
+	if s.Grew() {
+		direction, reduction = "larger", -reduction
+	}
+	return fmt.Sprintf("%s -> %s (%.1f%% %s)", bytesize.FormatSize(s.Original), bytesize.FormatSize(s.Compressed), reduction, direction)
+}
+
+// reportSizeChange prints the size line for a compressed file, warning when the output grew
+func reportSizeChange(indent string, change sizeChange) {
+	if change.Grew() {
+		fmt.Printf("%s%s Output is larger than the input: %s; the input may already be optimized\n", indent, colorWarning("!"), change)
+		return
+	}
+	infof("%sSize: %s\n", indent, change)
+}
+
+// sizeTotals adds up the size changes of a batch
+type sizeTotals struct {
+	Files int
+	sizeChange
+}
+
+// Add records one compressed file
+func (t *sizeTotals) Add(change sizeChange) {
+	t.Files++
+	t.Original += change.Original
+	t.Compressed += change.Compressed
+}
+
+// Summary formats the batch total, e.g. "12.0 MiB -> 8.0 MiB, 4.0 MiB saved (33.3%) across 3 file(s)"
+func (t sizeTotals) Summary() string {
+	saved := t.Original - t.Compressed
+	if saved < 0 {
+		return fmt.Sprintf("%s -> %s, %s larger (%.1f%%) across %d file(s)",
+			bytesize.FormatSize(t.Original), bytesize.FormatSize(t.Compressed), bytesize.FormatSize(-saved), -t.Reduction(), t.Files)
+	}
+	return fmt.Sprintf("%s -> %s, %s saved (%.1f%%) across %d file(s)",
+		bytesize.FormatSize(t.Original), bytesize.FormatSize(t.Compressed), bytesize.FormatSize(saved), t.Reduction(), t.Files)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSizeChange(t *testing.T) {
+	tests := []struct {
+		change   sizeChange
+		expected string
+		grew     bool
+	}{
+		{change: sizeChange{Original: 3 * 1024 * 1024, Compressed: 1024 * 1024}, expected: "3.0 MiB -> 1.0 MiB (66.7% smaller)"},
+		{change: sizeChange{Original: 1000, Compressed: 1000}, expected: "1000 B -> 1000 B (0.0% smaller)"},
+		{change: sizeChange{Original: 1000, Compressed: 1250}, expected: "1000 B -> 1.2 KiB (25.0% larger)", grew: true},
+		{change: sizeChange{}, expected: "0 B -> 0 B (0.0% smaller)"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.change.String(); got != tt.expected {
+			t.Errorf("%+v.String() = %q, expected %q", tt.change, got, tt.expected)
+		}
+		if got := tt.change.Grew(); got != tt.grew {
+			t.Errorf("%+v.Grew() = %v, expected %v", tt.change, got, tt.grew)
+		}
+	}
+}
+
+func TestSizeTotals(t *testing.T) {
+	var totals sizeTotals
+	totals.Add(sizeChange{Original: 3000, Compressed: 1000})
+	totals.Add(sizeChange{Original: 1000, Compressed: 1000})
+	if got, expected := totals.Summary(), "3.9 KiB -> 2.0 KiB, 2.0 KiB saved (50.0%) across 2 file(s)"; got != expected {
+		t.Errorf("Summary() = %q, expected %q", got, expected)
+	}
+
+	var grown sizeTotals
+	grown.Add(sizeChange{Original: 1000, Compressed: 1500})
+	if got, expected := grown.Summary(), "1000 B -> 1.5 KiB, 500 B larger (50.0%) across 1 file(s)"; got != expected {
+		t.Errorf("Summary() = %q, expected %q", got, expected)
+	}
+}
+
+func TestStatSizeChange(t *testing.T) {
+	dir := t.TempDir()
+	input, output := filepath.Join(dir, "in.pdf"), filepath.Join(dir, "out.pdf")
+	if err := os.WriteFile(input, make([]byte, 400), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(output, make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	change, err := statSizeChange(input, output)
+	if err != nil {
+		t.Fatalf("statSizeChange() error = %v", err)
+	}
+	if change != (sizeChange{Original: 400, Compressed: 100}) || change.Reduction() != 75 {
+		t.Errorf("statSizeChange() = %+v (%.1f%%), expected 400 -> 100 (75%%)", change, change.Reduction())
+	}
+	if _, err := statSizeChange(input, filepath.Join(dir, "missing.pdf")); err == nil {
+		t.Error("statSizeChange() expected an error for a missing output")
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// compressStateEntry records an input file as it was when last compressed successfully
+type compressStateEntry struct {
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mod_time"`
+	SHA256   string    `json:"sha256"`
+	Settings string    `json:"settings"` // compressSettings fingerprint of the options used
+}
+
+// compressState is the --state-file used to skip unchanged inputs on incremental runs
+type compressState struct {
+	path  string
+	Files map[string]compressStateEntry `json:"files"`
+}
+
+// loadCompressState reads the state file, starting empty if it does not exist yet
+func loadCompressState(path string) (*compressState, error) {
+	state := &compressState{path: path, Files: make(map[string]compressStateEntry)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+	if state.Files == nil {
+		state.Files = make(map[string]compressStateEntry)
+	}
+
+	return state, nil
+}
+
+// compressSettings fingerprints the options that affect the output, so changing any of them
+// (e.g. --pdfa or --output-dir) compresses the input again
+func compressSettings(opts pdfCompressOptions) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%q %q %q %q %q %q %t %d", opts.Quality, opts.CompatLevel, opts.PDFA, opts.NameTemplate,
+		opts.OutputDir, opts.OutputName, opts.StripMetadata, opts.DPI)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// unchanged reports whether absPath was already compressed with these settings and has not changed since.
+// Size and modtime are checked first; the hash is only computed when the modtime moved.
+func (s *compressState) unchanged(absPath, settings string) (bool, error) {
+	entry, ok := s.Files[absPath]
+	if !ok || entry.Settings != settings {
+		return false, nil
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return false, err
+	}
+	if info.Size() != entry.Size {
+		return false, nil
+	}
+	if info.ModTime().Equal(entry.ModTime) {
+		return true, nil
+	}
+
+	sum, err := fileSHA256(absPath)
+	if err != nil {
+		return false, err
+	}
+	return sum == entry.SHA256, nil
+}
+
+// record stores the current size, modtime and hash of absPath after a successful compression
+func (s *compressState) record(absPath, settings string) error {
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return err
+	}
+	sum, err := fileSHA256(absPath)
+	if err != nil {
+		return err
+	}
+
+	s.Files[absPath] = compressStateEntry{
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+		SHA256:   sum,
+		Settings: settings,
+	}
+	return nil
+}
+
+// save writes the state file atomically
+func (s *compressState) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state file: %w", err)
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	return nil
+}
+
+// fileSHA256 returns the hex-encoded SHA-256 of a file's contents
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
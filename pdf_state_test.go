@@ -0,0 +1,172 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompressStateRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "state.json")
+
+	state, err := loadCompressState(statePath)
+	if err != nil {
+		t.Fatalf("loadCompressState() missing file error = %v", err)
+	}
+	if len(state.Files) != 0 {
+		t.Fatalf("missing state file loaded %d entries", len(state.Files))
+	}
+
+	input := filepath.Join(dir, "scan.pdf")
+	if err := os.WriteFile(input, []byte("%PDF-1.4 scan"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	settings := compressSettings(pdfCompressOptions{Quality: "ebook"})
+	if err := state.record(input, settings); err != nil {
+		t.Fatalf("record() error = %v", err)
+	}
+	if err := state.save(); err != nil {
+		t.Fatalf("save() error = %v", err)
+	}
+	if _, err := os.Stat(statePath + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary state file left behind (stat error %v)", err)
+	}
+
+	loaded, err := loadCompressState(statePath)
+	if err != nil {
+		t.Fatalf("loadCompressState() error = %v", err)
+	}
+	entry, ok := loaded.Files[input]
+	if !ok {
+		t.Fatalf("loaded state has no entry for %s", input)
+	}
+	if want, _ := fileSHA256(input); entry.SHA256 != want || entry.Size != 13 || entry.Settings != settings {
+		t.Errorf("loaded entry = %+v", entry)
+	}
+}
+
+func TestCompressStateUnchanged(t *testing.T) {
+	recorded := pdfCompressOptions{Quality: "ebook", CompatLevel: "1.4"}
+	tests := []struct {
+		name     string
+		opts     pdfCompressOptions
+		modify   func(t *testing.T, path string) string // returns the path to check
+		expected bool
+	}{
+		{name: "untouched", opts: recorded, expected: true},
+		{name: "wait settings do not matter", opts: pdfCompressOptions{Quality: "ebook", CompatLevel: "1.4", WaitStable: time.Second}, expected: true},
+		{name: "other quality", opts: pdfCompressOptions{Quality: "screen", CompatLevel: "1.4"}},
+		{name: "other compat level", opts: pdfCompressOptions{Quality: "ebook", CompatLevel: "1.7"}},
+		{name: "pdfa", opts: pdfCompressOptions{Quality: "ebook", CompatLevel: "1.4", PDFA: "2b"}},
+		{name: "name template", opts: pdfCompressOptions{Quality: "ebook", CompatLevel: "1.4", NameTemplate: "{base}-small"}},
+		{name: "output dir", opts: pdfCompressOptions{Quality: "ebook", CompatLevel: "1.4", OutputDir: "/archive"}},
+		{name: "strip metadata", opts: pdfCompressOptions{Quality: "ebook", CompatLevel: "1.4", StripMetadata: true}},
+		{name: "dpi", opts: pdfCompressOptions{Quality: "ebook", CompatLevel: "1.4", DPI: 150}},
+		{
+			name: "touched but same content",
+			opts: recorded,
+			modify: func(t *testing.T, path string) string {
+				later := time.Now().Add(time.Hour)
+				if err := os.Chtimes(path, later, later); err != nil {
+					t.Fatal(err)
+				}
+				return path
+			},
+			expected: true,
+		},
+		{
+			name: "same size, new content",
+			opts: recorded,
+			modify: func(t *testing.T, path string) string {
+				if err := os.WriteFile(path, []byte("%PDF-1.4 SCAN"), 0644); err != nil {
+					t.Fatal(err)
+				}
+				later := time.Now().Add(time.Hour)
+				if err := os.Chtimes(path, later, later); err != nil {
+					t.Fatal(err)
+				}
+				return path
+			},
+		},
+		{
+			name: "grown",
+			opts: recorded,
+			modify: func(t *testing.T, path string) string {
+				if err := os.WriteFile(path, []byte("%PDF-1.4 scan, page 2"), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return path
+			},
+		},
+		{
+			name: "renamed",
+			opts: recorded,
+			modify: func(t *testing.T, path string) string {
+				renamed := filepath.Join(filepath.Dir(path), "renamed.pdf")
+				if err := os.Rename(path, renamed); err != nil {
+					t.Fatal(err)
+				}
+				return renamed
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			input := filepath.Join(dir, "scan.pdf")
+			if err := os.WriteFile(input, []byte("%PDF-1.4 scan"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			state, err := loadCompressState(filepath.Join(dir, "state.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := state.record(input, compressSettings(recorded)); err != nil {
+				t.Fatal(err)
+			}
+
+			check := input
+			if tt.modify != nil {
+				check = tt.modify(t, input)
+			}
+			got, err := state.unchanged(check, compressSettings(tt.opts))
+			if err != nil {
+				t.Fatalf("unchanged() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("unchanged() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoadCompressStateCorrupt(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(statePath, []byte(`{"files": {"/a.pdf": `), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadCompressState(statePath); err == nil || !strings.Contains(err.Error(), "failed to parse state file") {
+		t.Errorf("loadCompressState() error = %v, expected a parse error", err)
+	}
+}
+
+func TestFileSHA256(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hello.txt")
+	if err := os.WriteFile(path, []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := fileSHA256(path)
+	if err != nil {
+		t.Fatalf("fileSHA256() error = %v", err)
+	}
+	if want := "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"; sum != want {
+		t.Errorf("fileSHA256() = %s, want %s", sum, want)
+	}
+	if _, err := fileSHA256(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("fileSHA256() expected an error for a missing file")
+	}
+}
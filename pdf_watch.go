@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	// watchPollInterval is how often a file's size and mtime are re-checked while waiting for it to settle
+	watchPollInterval = 500 * time.Millisecond
+	// defaultWatchStableFor is how long a newly detected file must stay unchanged in watch mode
+	defaultWatchStableFor = 1500 * time.Millisecond
+)
+
+// runPdfWatch compresses PDFs as they land in a directory, writing the results to an output directory
+func runPdfWatch(c *cli.Context) error {
+	opts, err := pdfCompressOptionsFromFlags(c)
+	if err != nil {
+		return err
+	}
+	if !c.IsSet("wait-for-file") {
+		opts.WaitStable = defaultWatchStableFor
+	}
+
+	watchDir, err := filepath.Abs(c.String("watch"))
+	if err != nil {
+		return fmt.Errorf("failed to resolve watch directory: %w", err)
+	}
+	if info, err := os.Stat(watchDir); err != nil || !info.IsDir() {
+		return fmt.Errorf("watch directory does not exist: %s", watchDir)
+	}
+
+	if !c.IsSet("watch-output") {
+		return fmt.Errorf("--watch requires --watch-output so compressed files are not picked up again")
+	}
+	outputDir, err := filepath.Abs(c.String("watch-output"))
+	if err != nil {
+		return fmt.Errorf("failed to resolve watch output directory: %w", err)
+	}
+	if outputDir == watchDir {
+		return fmt.Errorf("--watch-output must differ from the watched directory")
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create watch output directory: %w", err)
+	}
+	// Ghostscript writes straight into the output directory so results never land in the watched one
+	opts.OutputDir = outputDir
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return watchPDFs(ctx, watchDir, opts)
+}
+
+// watchPDFs compresses PDFs created in watchDir into opts.OutputDir until ctx is cancelled
+func watchPDFs(ctx context.Context, watchDir string, opts pdfCompressOptions) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(watchDir); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", watchDir, err)
+	}
+
+	// Files are processed one at a time; pending de-duplicates bursts of events for the same file
+	queue := make(chan string, 64)
+	pending := make(map[string]bool)
+	done := make(chan string)
+	workerStopped := make(chan struct{})
+
+	go func() {
+		defer close(workerStopped)
+		for path := range queue {
+			if ctx.Err() != nil {
+				continue
+			}
+			processWatchedPDF(ctx, path, opts)
+			select {
+			case done <- path:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	// On shutdown, let the file currently being compressed finish before returning
+	defer func() {
+		close(queue)
+		<-workerStopped
+	}()
+
+	fmt.Printf("Watching %s for new PDFs (outputs go to %s, Ctrl-C to stop)...\n", watchDir, opts.OutputDir)
+	events.Emit(eventStarted, "pdf-compress", watchDir, "")
+	defer events.Emit(eventStopped, "pdf-compress", watchDir, "")
+
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Println("Stopping watch, waiting for in-flight compression...")
+			return nil
+		case path := <-done:
+			delete(pending, path)
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+				continue
+			}
+			if !strings.EqualFold(filepath.Ext(event.Name), ".pdf") || pending[event.Name] {
+				continue
+			}
+			select {
+			case queue <- event.Name:
+				pending[event.Name] = true
+			default:
+				fmt.Fprintf(os.Stderr, "Watch queue full, skipping %s\n", filepath.Base(event.Name))
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Fprintf(os.Stderr, "Watch error: %v\n", err)
+			events.Emit(eventError, "pdf-compress", watchDir, err.Error())
+		}
+	}
+}
+
+// processWatchedPDF waits for the file to finish being written and compresses it into opts.OutputDir
+func processWatchedPDF(ctx context.Context, path string, opts pdfCompressOptions) {
+	if err := waitForStableFile(ctx, path, opts.WaitStable, opts.WaitTimeout); err != nil {
+		if ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "  %s %s: %v\n", markFail(), filepath.Base(path), err)
+			events.Emit(eventError, "pdf-compress", path, err.Error())
+		}
+		return
+	}
+
+	name, err := outputFilename(path, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "  %s %s: %v\n", markFail(), filepath.Base(path), err)
+		events.Emit(eventError, "pdf-compress", path, err.Error())
+		return
+	}
+
+	outputPath, err := compressPDF(ctx, path, name, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "  %s %s: %v\n", markFail(), filepath.Base(path), err)
+		events.Emit(eventError, "pdf-compress", path, err.Error())
+		return
+	}
+	if runtimeSettings.DryRun {
+		return
+	}
+	change, err := statSizeChange(path, outputPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "  %s %s: %v\n", markFail(), filepath.Base(path), err)
+		events.Emit(eventError, "pdf-compress", path, err.Error())
+		return
+	}
+
+	fmt.Printf("  %s %s → %s\n", markOK(), filepath.Base(path), outputPath)
+	reportSizeChange("    ", change)
+	events.Emit(eventCompressed, "pdf-compress", path, outputPath)
+}
+
+// waitForStableFile polls until the file's size and mtime have not changed for stableFor,
+// debouncing partially-written files. It fails after timeout (0 waits indefinitely).
+func waitForStableFile(ctx context.Context, path string, stableFor, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var lastSize int64 = -1
+	var lastModTime time.Time
+	var stableSince time.Time
+	for {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if info.Size() != lastSize || !info.ModTime().Equal(lastModTime) || info.Size() == 0 {
+			lastSize, lastModTime, stableSince = info.Size(), info.ModTime(), now
+		} else if now.Sub(stableSince) >= stableFor {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("file did not stop changing within %s: %s", timeout, path)
+			}
+			return ctx.Err()
+		case <-time.After(min(watchPollInterval, max(stableFor/3, 10*time.Millisecond))):
+		}
+	}
+}
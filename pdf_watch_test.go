@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitForStableFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.pdf")
+	if err := os.WriteFile(path, []byte("%PDF-1.4"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := waitForStableFile(context.Background(), path, 50*time.Millisecond, time.Second); err != nil {
+		t.Errorf("waitForStableFile() unexpected error for a settled file: %v", err)
+	}
+
+	// A file that keeps growing never settles and must time out
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(10 * time.Millisecond):
+				f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+				if err != nil {
+					return
+				}
+				f.WriteString("x")
+				f.Close()
+			}
+		}
+	}()
+	err := waitForStableFile(context.Background(), path, 200*time.Millisecond, 300*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "did not stop changing") {
+		t.Errorf("waitForStableFile() error = %v, expected a timeout", err)
+	}
+
+	if err := waitForStableFile(context.Background(), filepath.Join(t.TempDir(), "missing.pdf"), time.Millisecond, time.Second); err == nil {
+		t.Error("waitForStableFile() expected an error for a missing file")
+	}
+}
+
+// fakeGhostscriptScript records its arguments and, for `run`, writes the -o file through the /output mount
+const fakeGhostscriptScript = `#!/bin/sh
+echo "$@" >> "$FAKE_DOCKER_LOG"
+[ "$1" = "run" ] || exit 0
+while [ $# -gt 0 ]; do
+	case "$1" in
+	-v) case "$2" in *:/output) mount="${2%:/output}" ;; esac ;;
+	-o) out="$2" ;;
+	esac
+	shift
+done
+echo "%PDF-1.4 compressed" > "$mount/${out#/output/}"
+`
+
+func TestWatchPDFsCompressesEachInputOnce(t *testing.T) {
+	stubEngineProbe(t, "")
+	logPath := fakeDocker(t, fakeGhostscriptScript)
+
+	reader, writer := io.Pipe()
+	savedEvents := events
+	events = newEventEmitter(writer)
+	defer func() {
+		events = savedEvents
+		writer.Close()
+	}()
+	received := make(chan Event)
+	go func() {
+		decoder := json.NewDecoder(reader)
+		for {
+			var event Event
+			if decoder.Decode(&event) != nil {
+				return
+			}
+			received <- event
+		}
+	}()
+
+	watchDir, outputDir := t.TempDir(), t.TempDir()
+	opts := pdfCompressOptions{
+		Quality:     "ebook",
+		CompatLevel: "1.4",
+		OutputDir:   outputDir,
+		WaitStable:  50 * time.Millisecond,
+		WaitTimeout: 5 * time.Second,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() { stopped <- watchPDFs(ctx, watchDir, opts) }()
+
+	// next returns the next event, or false if none arrives within timeout
+	next := func(timeout time.Duration) (Event, bool) {
+		select {
+		case event := <-received:
+			return event, true
+		case <-time.After(timeout):
+			return Event{}, false
+		}
+	}
+	if event, ok := next(5 * time.Second); !ok || event.Type != eventStarted {
+		t.Fatalf("first event = %+v, expected %q", event, eventStarted)
+	}
+
+	for _, name := range []string{"a.pdf", "b.pdf"} {
+		if err := os.WriteFile(filepath.Join(watchDir, name), []byte("%PDF-1.4 original input"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	compressed := 0
+	for compressed < 2 {
+		event, ok := next(10 * time.Second)
+		if !ok {
+			t.Fatalf("timed out after %d compressed events", compressed)
+		}
+		switch event.Type {
+		case eventCompressed:
+			compressed++
+		case eventError:
+			t.Errorf("unexpected error event: %+v", event)
+		}
+	}
+	// Give any event caused by the outputs time to surface
+	for {
+		event, ok := next(500 * time.Millisecond)
+		if !ok {
+			break
+		}
+		t.Errorf("unexpected event after both inputs were compressed: %+v", event)
+	}
+
+	cancel()
+	go func() {
+		for range received {
+		}
+	}()
+	if err := <-stopped; err != nil {
+		t.Fatalf("watchPDFs() error = %v", err)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runs := strings.Count(string(data), "run "); runs != 2 {
+		t.Errorf("docker run called %d times, expected 2; engine calls:\n%s", runs, data)
+	}
+	for _, name := range []string{"a_ebook.pdf", "b_ebook.pdf"} {
+		if _, err := os.Stat(filepath.Join(outputDir, name)); err != nil {
+			t.Errorf("output %s missing: %v", name, err)
+		}
+	}
+	if entries, _ := os.ReadDir(watchDir); len(entries) != 2 {
+		t.Errorf("watched directory has %d entries, expected only the 2 inputs", len(entries))
+	}
+}
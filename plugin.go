@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// pluginPrefix is prepended to an unknown command name to find an external plugin on PATH
+const pluginPrefix = "containers-"
+
+// lookupPlugin resolves `containers <name>` to a containers-<name> executable on PATH
+func lookupPlugin(name string) (string, error) {
+	if name == "" || strings.HasPrefix(name, "-") || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("unknown command: %s", name)
+	}
+	path, err := exec.LookPath(pluginPrefix + name)
+	if err != nil {
+		return "", fmt.Errorf("unknown command: %s (no built-in command or %s%s plugin on PATH)", name, pluginPrefix, name)
+	}
+	return path, nil
+}
+
+// runPlugin is the CommandNotFound hook: it runs containers-<name> with the remaining arguments
+// and exits with the plugin's status
+func runPlugin(c *cli.Context, name string) {
+	// `containers help foo` reaches this hook too (with "help" first) and should not execute anything
+	if c.Args().First() != name {
+		exitWithError(fmt.Errorf("no help topic for '%s'", name))
+	}
+
+	path, err := lookupPlugin(name)
+	if err != nil {
+		exitWithError(err)
+	}
+
+	cmd := exec.Command(path, c.Args().Tail()...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		// The plugin reports its own errors; only propagate the status
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		exitWithError(fmt.Errorf("failed to run plugin %s: %w", path, err))
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLookupPlugin(t *testing.T) {
+	dir := t.TempDir()
+	plugin := filepath.Join(dir, "containers-hello")
+	if err := os.WriteFile(plugin, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "containers-noexec"), []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	tests := []struct {
+		name      string
+		expected  string
+		expectErr bool
+	}{
+		{name: "hello", expected: plugin},
+		{name: "missing", expectErr: true},
+		{name: "noexec", expectErr: true},
+		{name: "../hello", expectErr: true},
+		{name: "-v", expectErr: true},
+		{name: "", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := lookupPlugin(tt.name)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("lookupPlugin(%q) expected error, got %q", tt.name, path)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("lookupPlugin(%q) unexpected error: %v", tt.name, err)
+			}
+			if path != tt.expected {
+				t.Errorf("lookupPlugin(%q) = %q, expected %q", tt.name, path, tt.expected)
+			}
+		})
+	}
+}
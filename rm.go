@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// runRm force-removes managed containers whose names match the given names or globs
+func runRm(c *cli.Context) error {
+	if c.NArg() == 0 {
+		return fmt.Errorf("expected at least 1 argument: container name or pattern")
+	}
+	patterns := c.Args().Slice()
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	output, err := dockerCommand("ps", "-a", "--filter", "label="+managedLabel, "--format", "{{.Names}}").Output()
+	if err != nil {
+		return fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	matches := matchContainerNames(strings.Split(strings.TrimSpace(string(output)), "\n"), patterns)
+	if len(matches) == 0 {
+		fmt.Println("No managed containers match")
+		return nil
+	}
+
+	fmt.Println("Containers to remove:")
+	for _, name := range matches {
+		fmt.Printf("  %s\n", name)
+	}
+	if runtimeSettings.DryRun {
+		return nil
+	}
+	if !c.Bool("yes") {
+		confirmed, err := confirm(fmt.Sprintf("Remove %d container(s)?", len(matches)))
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Aborted")
+			return nil
+		}
+	}
+
+	var failed int
+	for _, name := range matches {
+		if output, err := dockerCommand("rm", "-f", name).CombinedOutput(); err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "  %s %s: %s\n", markFail(), name, strings.TrimSpace(string(output)))
+			continue
+		}
+		fmt.Printf("  %s removed %s\n", markOK(), name)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to remove %d container(s)", failed)
+	}
+	return nil
+}
+
+// matchContainerNames returns the names matching any pattern, in listing order
+func matchContainerNames(names, patterns []string) []string {
+	var matches []string
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, name); ok {
+				matches = append(matches, name)
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// confirm asks a yes/no question on stdin, defaulting to no
+func confirm(question string) (bool, error) {
+	fmt.Printf("%s [y/N]: ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return false, fmt.Errorf("failed to read confirmation: %w", err)
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
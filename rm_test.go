@@ -0,0 +1,27 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchContainerNames(t *testing.T) {
+	names := []string{"ibgateway", "ibgateway-live", "pdf-1", ""}
+
+	tests := []struct {
+		patterns []string
+		want     []string
+	}{
+		{[]string{"ibgateway"}, []string{"ibgateway"}},
+		{[]string{"ibgateway*"}, []string{"ibgateway", "ibgateway-live"}},
+		{[]string{"pdf-?", "ibgateway"}, []string{"ibgateway", "pdf-1"}},
+		{[]string{"nope"}, nil},
+	}
+
+	for _, tt := range tests {
+		got := matchContainerNames(names, tt.patterns)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("matchContainerNames(%v) = %v, want %v", tt.patterns, got, tt.want)
+		}
+	}
+}
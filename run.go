@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+// runRun runs an arbitrary image through RunContainer, with the working directory mounted at /workspace.
+// Everything after the flags (conventionally after --) is passed to the container as its arguments.
+func runRun(c *cli.Context) error {
+	image := c.String("image")
+	if image == "" {
+		return fmt.Errorf("--image is required")
+	}
+
+	// --env overrides values from env files
+	env, err := envFromFiles(c)
+	if err != nil {
+		return err
+	}
+	flagEnv, err := parseEnvAssignments(c.StringSlice("env"))
+	if err != nil {
+		return err
+	}
+	for key, envVar := range flagEnv {
+		env[key] = envVar
+	}
+	for _, mount := range c.StringSlice("tmpfs") {
+		if mount == "" || mount[0] != '/' {
+			return fmt.Errorf("invalid tmpfs mount %q (expected /path[:options])", mount)
+		}
+	}
+	limits, err := resourceLimitsFromFlags(c)
+	if err != nil {
+		return err
+	}
+
+	workDir, err := expandHome(c.String("workdir"))
+	if err != nil {
+		return err
+	}
+
+	return RunContainer(context.Background(), image, workDir, c.Args().Slice(), ContainerOptions{
+		Env:        env,
+		Tmpfs:      c.StringSlice("tmpfs"),
+		Volumes:    c.StringSlice("volume"),
+		Remove:     true,
+		User:       c.String("user"),
+		Limits:     limits,
+		Entrypoint: c.String("entrypoint"),
+		ReadOnly:   c.Bool("read-only"),
+		NoNewPrivs: c.Bool("no-new-privileges"),
+		CapDrop:    c.StringSlice("cap-drop"),
+		CapAdd:     c.StringSlice("cap-add"),
+		Network:    c.String("network"),
+		Labels:     toolLabels("run"),
+	})
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRunKeepsCommasInValues(t *testing.T) {
+	stubEngineProbe(t, "")
+	logPath := fakeDocker(t, "#!/bin/sh\necho \"$@\" >> \"$FAKE_DOCKER_LOG\"\n")
+
+	err := runAppCommand(t, "run", []string{"run",
+		"--image", "ghcr.io/example/tool:latest",
+		"--workdir", t.TempDir(),
+		"--tmpfs", "/tmp:rw,noexec,nosuid,size=64m",
+		"-e", "A=b,c",
+		"--", "echo", "hi",
+	}, nil)
+	if err != nil {
+		t.Fatalf("run error = %v", err)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{" --tmpfs /tmp:rw,noexec,nosuid,size=64m ", " -e A=b,c ", " echo hi\n"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("docker run missing %q; engine calls:\n%s", want, data)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/urfave/cli/v2"
+)
+
+// selfTestStep is a single stage of the end-to-end self-test
+type selfTestStep struct {
+	Name string
+	Run  func() error
+}
+
+// runSelfTest exercises the whole pipeline (engine, pull, run, mount) with the pdf-compress image,
+// which renders a blank page into the mounted workspace that is then read back on the host
+func runSelfTest(c *cli.Context) error {
+	if runtimeSettings.DryRun {
+		return fmt.Errorf("self-test exercises the container engine and cannot run with --dry-run")
+	}
+	workDir, err := os.MkdirTemp("", "containers-self-test-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	// Let the container user write to the mount regardless of uid mapping
+	if err := os.Chmod(workDir, 0777); err != nil {
+		return fmt.Errorf("failed to prepare temporary directory: %w", err)
+	}
+
+	outputName := "self-test.pdf"
+	steps := []selfTestStep{
+		{
+			Name: "container engine installed",
+			Run: func() error {
+				_, err := exec.LookPath("docker")
+				return err
+			},
+		},
+		{
+			Name: "container engine responding",
+			Run: func() error {
+				output, err := dockerCommand("info", "--format", "{{.ServerVersion}}").CombinedOutput()
+				if err != nil {
+					return fmt.Errorf("%w: %s", err, bytes.TrimSpace(output))
+				}
+				return nil
+			},
+		},
+		{
+			Name: "image available (" + pdfCompressImage + ")",
+			Run: func() error {
+				if err := checkImageAllowed(pdfCompressImage, runtimeSettings.AllowedRegistries); err != nil {
+					return err
+				}
+				if dockerCommand("image", "inspect", pdfCompressImage).Run() == nil {
+					return nil
+				}
+				output, err := dockerCommand("pull", "--quiet", pdfCompressImage).CombinedOutput()
+				if err != nil {
+					return fmt.Errorf("%w: %s", err, bytes.TrimSpace(output))
+				}
+				return nil
+			},
+		},
+		{
+			Name: "container runs with workspace mount",
+			Run: func() error {
+				args := []string{"-q", "-sDEVICE=pdfwrite", "-o", "/workspace/" + outputName, "-c", "showpage"}
+				return RunContainer(c.Context, pdfCompressImage, workDir, args, ContainerOptions{Remove: true, Network: "none", Labels: toolLabels("self-test")})
+			},
+		},
+		{
+			Name: "output visible on host",
+			Run: func() error {
+				valid, err := isPDFFile(filepath.Join(workDir, outputName))
+				if err != nil {
+					return err
+				}
+				if !valid {
+					return fmt.Errorf("output is not a PDF file")
+				}
+				return nil
+			},
+		},
+	}
+
+	fmt.Println("Running self-test...")
+	for i, step := range steps {
+		if err := step.Run(); err != nil {
+			fmt.Printf("  %s %s: %v\n", markFail(), step.Name, err)
+			for _, skipped := range steps[i+1:] {
+				fmt.Printf("  - %s %s\n", skipped.Name, colorWarning("(skipped)"))
+			}
+			return fmt.Errorf("self-test failed at step: %s", step.Name)
+		}
+		fmt.Printf("  %s %s\n", markOK(), step.Name)
+	}
+
+	fmt.Println("Self-test passed")
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// skipReason categorizes why an item was not processed
+type skipReason string
+
+const (
+	skipUnchanged skipReason = "unchanged"
+	skipIgnored   skipReason = "ignored"
+)
+
+// skipReasonLabels holds the singular and plural human-readable label for each reason
+var skipReasonLabels = map[skipReason][2]string{
+	skipUnchanged: {"unchanged", "unchanged"},
+	skipIgnored:   {"ignored", "ignored"},
+}
+
+// skippedItem is a single skipped input and the reason it was skipped
+type skippedItem struct {
+	Item   string
+	Reason skipReason
+}
+
+// skipTracker records skipped items across a batch run; safe for concurrent use
+type skipTracker struct {
+	mu    sync.Mutex
+	items []skippedItem
+}
+
+// Add records that item was skipped for reason
+func (t *skipTracker) Add(item string, reason skipReason) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.items = append(t.items, skippedItem{Item: item, Reason: reason})
+}
+
+// Count returns the total number of skipped items
+func (t *skipTracker) Count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.items)
+}
+
+// counts returns the number of skipped items per reason
+func (t *skipTracker) counts() map[skipReason]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	counts := make(map[skipReason]int)
+	for _, item := range t.items {
+		counts[item.Reason]++
+	}
+	return counts
+}
+
+// Summary renders a categorized line like "3 skipped (2 ignored, 1 unchanged)"
+func (t *skipTracker) Summary() string {
+	counts := t.counts()
+	total := 0
+	reasons := make([]skipReason, 0, len(counts))
+	for reason, n := range counts {
+		reasons = append(reasons, reason)
+		total += n
+	}
+	if total == 0 {
+		return "0 skipped"
+	}
+
+	// Most common reason first, ties broken alphabetically for stable output
+	sort.Slice(reasons, func(i, j int) bool {
+		if counts[reasons[i]] != counts[reasons[j]] {
+			return counts[reasons[i]] > counts[reasons[j]]
+		}
+		return reasons[i] < reasons[j]
+	})
+
+	parts := make([]string, 0, len(reasons))
+	for _, reason := range reasons {
+		label := string(reason)
+		if labels, ok := skipReasonLabels[reason]; ok {
+			label = labels[0]
+			if counts[reason] > 1 {
+				label = labels[1]
+			}
+		}
+		parts = append(parts, fmt.Sprintf("%d %s", counts[reason], label))
+	}
+
+	return fmt.Sprintf("%d skipped (%s)", total, strings.Join(parts, ", "))
+}
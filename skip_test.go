@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestSkipTrackerSummary(t *testing.T) {
+	var skips skipTracker
+	if summary := skips.Summary(); summary != "0 skipped" {
+		t.Errorf("Summary() = %q, expected %q", summary, "0 skipped")
+	}
+
+	skips.Add("a.pdf", skipIgnored)
+	skips.Add("b.pdf", skipUnchanged)
+	skips.Add("c.pdf", skipIgnored)
+
+	expected := "3 skipped (2 ignored, 1 unchanged)"
+	if summary := skips.Summary(); summary != expected {
+		t.Errorf("Summary() = %q, expected %q", summary, expected)
+	}
+	if count := skips.Count(); count != 3 {
+		t.Errorf("Count() = %d, expected 3", count)
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestPrintVersion(t *testing.T) {
+	savedVersion, savedCommit, savedDate := version, commit, buildDate
+	defer func() { version, commit, buildDate = savedVersion, savedCommit, savedDate }()
+	version, commit, buildDate = "v1.2.3", "abc1234", "2026-01-02T03:04:05Z"
+
+	var out bytes.Buffer
+	printVersion(&out)
+	for _, want := range []string{"containers v1.2.3\n", "commit:     abc1234\n", "built:      2026-01-02T03:04:05Z\n", runtime.Version()} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("printVersion() = %q, missing %q", out.String(), want)
+		}
+	}
+}